@@ -0,0 +1,272 @@
+/*
+Package boltqueue - a wahoo.WebhookQueue kept in a bbolt database
+
+It is a separate package so the client itself keeps to the standard library, only programs that import this one pull
+in go.etcd.io/bbolt.  Pending and failed webhooks are JSON values keyed by their ID and completed IDs are kept with
+when they completed for the dedup window.  Every change is one bbolt transaction (synced before it returns) so a crash
+never leaves a webhook both pending and completed.  Which webhooks are being processed is only kept in memory, so
+they are pending again when the database is opened after a restart.  bbolt locks the file, only one process can
+have it open.
+*/
+package boltqueue
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+	bolt "go.etcd.io/bbolt"
+)
+
+//Buckets of the database
+var (
+	pendingBucket   = []byte("pending")
+	completedBucket = []byte("completed")
+	failedBucket    = []byte("failed")
+)
+
+//errNotDequeued - Retry, Complete and Fail are only for webhooks that were dequeued
+var errNotDequeued = errors.New("webhook was not dequeued")
+
+//Queue - see the package doc
+type Queue struct {
+	mutex       sync.Mutex
+	db          *bolt.DB
+	dedupWindow time.Duration
+	lastPrune   time.Time
+	inFlight    map[string]bool
+}
+
+//Open - opens (or creates) the database at path.  A dedupWindow of 0 uses wahoo.DefaultWebhookDedupWindow
+func Open(path string, dedupWindow time.Duration) (*Queue, error) {
+	if path == "" {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	if dedupWindow <= 0 {
+		dedupWindow = wahoo.DefaultWebhookDedupWindow
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{pendingBucket, completedBucket, failedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Queue{db: db, dedupWindow: dedupWindow, inFlight: map[string]bool{}}, nil
+}
+
+//Close - closes the database.  Webhooks that are being processed are pending again when it is opened
+func (v *Queue) Close() error {
+	return v.db.Close()
+}
+
+//encodeTime - completed times are stored as big endian unix nanoseconds
+func encodeTime(value time.Time) []byte {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, uint64(value.UnixNano()))
+	return encoded
+}
+
+//decodeTime - see encodeTime, a value that is not 8 bytes is the zero time
+func decodeTime(encoded []byte) time.Time {
+	if len(encoded) != 8 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(encoded)))
+}
+
+//pruneCompleted - removes the completed IDs that are older than the dedup window.  Runs at most once a minute
+func (v *Queue) pruneCompleted(tx *bolt.Tx, now time.Time) error {
+	if now.Sub(v.lastPrune) < time.Minute {
+		return nil
+	}
+	v.lastPrune = now
+	bucket := tx.Bucket(completedBucket)
+	expired := [][]byte{}
+	err := bucket.ForEach(func(key, value []byte) error {
+		if now.Sub(decodeTime(value)) > v.dedupWindow {
+			expired = append(expired, append([]byte{}, key...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	//Deleting while iterating can skip keys so they are deleted afterwards
+	for _, key := range expired {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//put - stores the webhook in the bucket under its ID
+func put(tx *bolt.Tx, bucket []byte, item *wahoo.QueuedWebhook) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucket).Put([]byte(item.ID), data)
+}
+
+//Enqueue - see wahoo.WebhookQueue.  The webhook is in the database before this returns
+func (v *Queue) Enqueue(item *wahoo.QueuedWebhook) (bool, error) {
+	if item == nil || item.ID == "" {
+		return false, errors.New("Missing Mandatory Value")
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	added := false
+	err := v.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		if err := v.pruneCompleted(tx, now); err != nil {
+			return err
+		}
+		if tx.Bucket(pendingBucket).Get([]byte(item.ID)) != nil {
+			return nil
+		}
+		if completedAt := tx.Bucket(completedBucket).Get([]byte(item.ID)); completedAt != nil && now.Sub(decodeTime(completedAt)) <= v.dedupWindow {
+			return nil
+		}
+		added = true
+		return put(tx, pendingBucket, item)
+	})
+	if err != nil {
+		return false, err
+	}
+	return added, nil
+}
+
+//Dequeue - see wahoo.WebhookQueue
+func (v *Queue) Dequeue(now time.Time) (*wahoo.QueuedWebhook, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	var next *wahoo.QueuedWebhook
+	err := v.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(pendingBucket).ForEach(func(key, data []byte) error {
+			if v.inFlight[string(key)] {
+				return nil
+			}
+			item := &wahoo.QueuedWebhook{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return err
+			}
+			if item.NextAttempt.After(now) {
+				return nil
+			}
+			if next == nil || item.NextAttempt.Before(next.NextAttempt) ||
+				(item.NextAttempt.Equal(next.NextAttempt) && item.ReceivedAt.Before(next.ReceivedAt)) {
+				next = item
+			}
+			return nil
+		})
+	})
+	if err != nil || next == nil {
+		return nil, err
+	}
+	v.inFlight[next.ID] = true
+	return next, nil
+}
+
+//Retry - see wahoo.WebhookQueue.  The attempts and next attempt are saved so they carry over a restart
+func (v *Queue) Retry(item *wahoo.QueuedWebhook) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if item == nil || !v.inFlight[item.ID] {
+		return errNotDequeued
+	}
+	err := v.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, pendingBucket, item)
+	})
+	if err != nil {
+		return err
+	}
+	delete(v.inFlight, item.ID)
+	return nil
+}
+
+//Complete - see wahoo.WebhookQueue
+func (v *Queue) Complete(item *wahoo.QueuedWebhook) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if item == nil || !v.inFlight[item.ID] {
+		return errNotDequeued
+	}
+	err := v.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(pendingBucket).Delete([]byte(item.ID)); err != nil {
+			return err
+		}
+		return tx.Bucket(completedBucket).Put([]byte(item.ID), encodeTime(time.Now()))
+	})
+	if err != nil {
+		return err
+	}
+	delete(v.inFlight, item.ID)
+	return nil
+}
+
+//Fail - see wahoo.WebhookQueue.  The webhook is kept and returned by Failed
+func (v *Queue) Fail(item *wahoo.QueuedWebhook) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if item == nil || !v.inFlight[item.ID] {
+		return errNotDequeued
+	}
+	err := v.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(pendingBucket).Delete([]byte(item.ID)); err != nil {
+			return err
+		}
+		return put(tx, failedBucket, item)
+	})
+	if err != nil {
+		return err
+	}
+	delete(v.inFlight, item.ID)
+	return nil
+}
+
+//Len - the number of webhooks waiting, including the ones being processed
+func (v *Queue) Len() (int, error) {
+	count := 0
+	err := v.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(pendingBucket).Stats().KeyN
+		return nil
+	})
+	return count, err
+}
+
+//Failed - the webhooks that ran out of attempts, oldest first
+func (v *Queue) Failed() ([]*wahoo.QueuedWebhook, error) {
+	failed := []*wahoo.QueuedWebhook{}
+	err := v.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(failedBucket).ForEach(func(key, data []byte) error {
+			item := &wahoo.QueuedWebhook{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return err
+			}
+			failed = append(failed, item)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].ReceivedAt.Before(failed[j].ReceivedAt) })
+	return failed, nil
+}
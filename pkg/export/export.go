@@ -0,0 +1,114 @@
+/*
+Package export - renders a Wahoo workout and the track from its FIT file into formats other tools understand
+
+The workout supplies the metadata (name, start and type) and the decoded FIT file supplies the track.
+*/
+package export
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
+)
+
+//Creator - written into the creator attribute of exported files
+var Creator = "wahoo_cloud_client"
+
+//timeFormat - both GPX and TCX use UTC RFC3339 timestamps
+const timeFormat = "2006-01-02T15:04:05Z"
+
+//errMissing - matches the error the client returns for missing values
+var errMissing = errors.New("Missing Mandatory Value")
+
+//workoutName - the workout name or a generic one
+func workoutName(workout *wahoo.Workout) string {
+	if workout != nil && workout.Name != nil && *workout.Name != "" {
+		return *workout.Name
+	}
+	return "Wahoo Workout"
+}
+
+//workoutStart - the start of the workout, falling back to the first record
+func workoutStart(workout *wahoo.Workout, file *fit.File) *time.Time {
+	if workout != nil && workout.Starts != nil && !workout.Starts.IsZero() {
+		return workout.Starts
+	}
+	for _, record := range file.Records {
+		if record.Timestamp != nil {
+			return record.Timestamp
+		}
+	}
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(timeFormat)
+}
+
+func formatFloat(value float64, precision int) string {
+	return strconv.FormatFloat(value, 'f', precision, 64)
+}
+
+//floatString - nil safe formatting for optional values
+func floatString(value *float64, precision int) string {
+	if value == nil {
+		return ""
+	}
+	return formatFloat(*value, precision)
+}
+
+func intString(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+//timerStops - the times the timer was stopped so tracks can be split into segments
+func timerStops(file *fit.File) []time.Time {
+	var stops []time.Time
+	for _, event := range file.Events {
+		if event.Timestamp == nil || event.Event == nil || event.EventType == nil || *event.Event != fit.EventTimer {
+			continue
+		}
+		if *event.EventType == fit.EventTypeStop || *event.EventType == fit.EventTypeStopAll {
+			stops = append(stops, *event.Timestamp)
+		}
+	}
+	return stops
+}
+
+//stoppedBetween - true when the timer stopped after previous and before current
+func stoppedBetween(stops []time.Time, previous, current *time.Time) bool {
+	if previous == nil || current == nil {
+		return false
+	}
+	for _, stop := range stops {
+		if !stop.Before(*previous) && stop.Before(*current) {
+			return true
+		}
+	}
+	return false
+}
+
+//writeXML - writes the xml header and the indented document
+func writeXML(w io.Writer, document interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,136 @@
+package export
+
+import (
+	"encoding/xml"
+	"io"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
+)
+
+type gpxDocument struct {
+	XMLName        xml.Name    `xml:"gpx"`
+	Version        string      `xml:"version,attr"`
+	Creator        string      `xml:"creator,attr"`
+	Xmlns          string      `xml:"xmlns,attr"`
+	XmlnsXsi       string      `xml:"xmlns:xsi,attr"`
+	XmlnsGpxtpx    string      `xml:"xmlns:gpxtpx,attr"`
+	SchemaLocation string      `xml:"xsi:schemaLocation,attr"`
+	Metadata       gpxMetadata `xml:"metadata"`
+	Track          gpxTrack    `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name string `xml:"name"`
+	Time string `xml:"time,omitempty"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name"`
+	Type     string       `xml:"type,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat        string         `xml:"lat,attr"`
+	Lon        string         `xml:"lon,attr"`
+	Elevation  string         `xml:"ele,omitempty"`
+	Time       string         `xml:"time,omitempty"`
+	Extensions *gpxExtensions `xml:"extensions,omitempty"`
+}
+
+type gpxExtensions struct {
+	Power          string                  `xml:"power,omitempty"`
+	TrackPointExts *gpxTrackPointExtension `xml:"gpxtpx:TrackPointExtension,omitempty"`
+}
+
+type gpxTrackPointExtension struct {
+	Temperature string `xml:"gpxtpx:atemp,omitempty"`
+	HeartRate   string `xml:"gpxtpx:hr,omitempty"`
+	Cadence     string `xml:"gpxtpx:cad,omitempty"`
+}
+
+/*
+WriteGPX - writes the workout as GPX 1.1
+
+Heart rate, cadence and temperature use the Garmin TrackPointExtension and power is written as a <power> extension
+(the way Strava reads it).  Records without a position are left out because GPX needs lat/lon on every point, and
+the track is split into a new segment each time the timer was stopped.
+*/
+func WriteGPX(w io.Writer, workout *wahoo.Workout, file *fit.File) error {
+	if w == nil || file == nil {
+		return errMissing
+	}
+
+	document := gpxDocument{
+		Version:        "1.1",
+		Creator:        Creator,
+		Xmlns:          "http://www.topografix.com/GPX/1/1",
+		XmlnsXsi:       "http://www.w3.org/2001/XMLSchema-instance",
+		XmlnsGpxtpx:    "http://www.garmin.com/xmlschemas/TrackPointExtension/v1",
+		SchemaLocation: "http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd http://www.garmin.com/xmlschemas/TrackPointExtension/v1 http://www.garmin.com/xmlschemas/TrackPointExtensionv1.xsd",
+		Metadata: gpxMetadata{
+			Name: workoutName(workout),
+			Time: formatTime(workoutStart(workout, file)),
+		},
+		Track: gpxTrack{
+			Name: workoutName(workout),
+			Type: gpxType(workout),
+		},
+	}
+
+	stops := timerStops(file)
+	segment := gpxSegment{}
+	var previous *fit.Record
+	for _, record := range file.Records {
+		if record.Lat == nil || record.Long == nil {
+			continue
+		}
+		if previous != nil && stoppedBetween(stops, previous.Timestamp, record.Timestamp) && len(segment.Points) > 0 {
+			document.Track.Segments = append(document.Track.Segments, segment)
+			segment = gpxSegment{}
+		}
+		segment.Points = append(segment.Points, gpxPointFromRecord(record))
+		previous = record
+	}
+	if len(segment.Points) > 0 {
+		document.Track.Segments = append(document.Track.Segments, segment)
+	}
+
+	return writeXML(w, document)
+}
+
+func gpxPointFromRecord(record *fit.Record) gpxPoint {
+	point := gpxPoint{
+		Lat:       formatFloat(*record.Lat, 7),
+		Lon:       formatFloat(*record.Long, 7),
+		Elevation: floatString(record.Altitude, 1),
+		Time:      formatTime(record.Timestamp),
+	}
+	extension := &gpxTrackPointExtension{
+		Temperature: intString(record.Temperature),
+		HeartRate:   intString(record.HeartRate),
+		Cadence:     intString(record.Cadence),
+	}
+	extensions := &gpxExtensions{Power: intString(record.Power)}
+	if *extension != (gpxTrackPointExtension{}) {
+		extensions.TrackPointExts = extension
+	}
+	if *extensions != (gpxExtensions{}) {
+		point.Extensions = extensions
+	}
+	return point
+}
+
+//gpxType - GPX has no fixed list of types so the TCX sport names are reused
+func gpxType(workout *wahoo.Workout) string {
+	sport := tcxSport(workout)
+	if sport == "Other" {
+		return ""
+	}
+	return sport
+}
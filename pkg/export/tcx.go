@@ -0,0 +1,268 @@
+package export
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
+)
+
+type tcxDocument struct {
+	XMLName        xml.Name      `xml:"TrainingCenterDatabase"`
+	Xmlns          string        `xml:"xmlns,attr"`
+	XmlnsXsi       string        `xml:"xmlns:xsi,attr"`
+	XmlnsNs3       string        `xml:"xmlns:ns3,attr"`
+	SchemaLocation string        `xml:"xsi:schemaLocation,attr"`
+	Activities     tcxActivities `xml:"Activities"`
+}
+
+type tcxActivities struct {
+	Activity tcxActivity `xml:"Activity"`
+}
+
+type tcxActivity struct {
+	Sport   string     `xml:"Sport,attr"`
+	ID      string     `xml:"Id"`
+	Laps    []tcxLap   `xml:"Lap"`
+	Notes   string     `xml:"Notes,omitempty"`
+	Creator tcxCreator `xml:"Creator"`
+}
+
+//tcxCreator - a Device_t, which the schema says has to have a unit ID, product ID and version (zero here)
+type tcxCreator struct {
+	Type      string     `xml:"xsi:type,attr"`
+	Name      string     `xml:"Name"`
+	UnitID    int        `xml:"UnitId"`
+	ProductID int        `xml:"ProductID"`
+	Version   tcxVersion `xml:"Version"`
+}
+
+type tcxVersion struct {
+	VersionMajor int `xml:"VersionMajor"`
+	VersionMinor int `xml:"VersionMinor"`
+}
+
+type tcxLap struct {
+	StartTime        string      `xml:"StartTime,attr"`
+	TotalTimeSeconds string      `xml:"TotalTimeSeconds"`
+	DistanceMeters   string      `xml:"DistanceMeters"`
+	MaximumSpeed     string      `xml:"MaximumSpeed,omitempty"`
+	Calories         string      `xml:"Calories"`
+	AverageHeartRate *tcxValue   `xml:"AverageHeartRateBpm,omitempty"`
+	MaximumHeartRate *tcxValue   `xml:"MaximumHeartRateBpm,omitempty"`
+	Intensity        string      `xml:"Intensity"`
+	Cadence          string      `xml:"Cadence,omitempty"`
+	TriggerMethod    string      `xml:"TriggerMethod"`
+	Track            *tcxTrack   `xml:"Track,omitempty"`
+	Extensions       *tcxLapExts `xml:"Extensions,omitempty"`
+}
+
+type tcxLapExts struct {
+	LX tcxLapExtension `xml:"ns3:LX"`
+}
+
+type tcxLapExtension struct {
+	AvgSpeed string `xml:"ns3:AvgSpeed,omitempty"`
+	AvgWatts string `xml:"ns3:AvgWatts,omitempty"`
+	MaxWatts string `xml:"ns3:MaxWatts,omitempty"`
+}
+
+type tcxValue struct {
+	Value string `xml:"Value"`
+}
+
+type tcxTrack struct {
+	Points []tcxTrackpoint `xml:"Trackpoint"`
+}
+
+type tcxTrackpoint struct {
+	Time           string         `xml:"Time"`
+	Position       *tcxPosition   `xml:"Position,omitempty"`
+	AltitudeMeters string         `xml:"AltitudeMeters,omitempty"`
+	DistanceMeters string         `xml:"DistanceMeters,omitempty"`
+	HeartRate      *tcxValue      `xml:"HeartRateBpm,omitempty"`
+	Cadence        string         `xml:"Cadence,omitempty"`
+	Extensions     *tcxPointsExts `xml:"Extensions,omitempty"`
+}
+
+type tcxPosition struct {
+	Lat  string `xml:"LatitudeDegrees"`
+	Long string `xml:"LongitudeDegrees"`
+}
+
+type tcxPointsExts struct {
+	TPX tcxPointExtension `xml:"ns3:TPX"`
+}
+
+type tcxPointExtension struct {
+	Speed string `xml:"ns3:Speed,omitempty"`
+	Watts string `xml:"ns3:Watts,omitempty"`
+}
+
+/*
+WriteTCX - writes the workout as a Garmin Training Center (TCX) activity
+
+Each FIT lap becomes a TCX lap.  When the file has no laps a single lap is built from the workout summary.
+Speed and power are written with the ActivityExtension v2 schema.
+*/
+func WriteTCX(w io.Writer, workout *wahoo.Workout, file *fit.File) error {
+	if w == nil || file == nil {
+		return errMissing
+	}
+
+	start := workoutStart(workout, file)
+	document := tcxDocument{
+		Xmlns:          "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2",
+		XmlnsXsi:       "http://www.w3.org/2001/XMLSchema-instance",
+		XmlnsNs3:       "http://www.garmin.com/xmlschemas/ActivityExtension/v2",
+		SchemaLocation: "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2 http://www.garmin.com/xmlschemas/TrainingCenterDatabasev2.xsd",
+		Activities: tcxActivities{
+			Activity: tcxActivity{
+				Sport:   tcxSport(workout),
+				ID:      formatTime(start),
+				Notes:   workoutName(workout),
+				Creator: tcxCreator{Type: "Device_t", Name: Creator},
+			},
+		},
+	}
+
+	laps := file.Laps
+	if len(laps) == 0 {
+		laps = []*fit.Lap{lapFromSummary(workout, start)}
+	}
+	for i, lap := range laps {
+		var lapEnd *time.Time
+		if i+1 < len(laps) {
+			lapEnd = laps[i+1].StartTime
+		}
+		document.Activities.Activity.Laps = append(document.Activities.Activity.Laps, tcxLapFromFit(lap, file.Records, lapEnd))
+	}
+
+	return writeXML(w, document)
+}
+
+//lapFromSummary - builds a lap covering the whole workout from the summary totals
+func lapFromSummary(workout *wahoo.Workout, start *time.Time) *fit.Lap {
+	lap := &fit.Lap{StartTime: start}
+	if workout == nil || workout.WorkoutSummary == nil {
+		return lap
+	}
+	summary := workout.WorkoutSummary
+	lap.TotalTimerTime = summary.DurationActiveAccum
+	lap.TotalDistance = summary.DistanceAccum
+	lap.TotalCalories = summary.CaloriesAccum
+	lap.AvgSpeed = summary.SpeedAvg
+	if summary.HeartRateAvg != nil {
+		heartRate := int(*summary.HeartRateAvg + 0.5)
+		lap.AvgHeartRate = &heartRate
+	}
+	if summary.PowerAvg != nil {
+		power := int(*summary.PowerAvg + 0.5)
+		lap.AvgPower = &power
+	}
+	if summary.CadenceAvg != nil {
+		cadence := int(*summary.CadenceAvg + 0.5)
+		lap.AvgCadence = &cadence
+	}
+	return lap
+}
+
+func tcxLapFromFit(lap *fit.Lap, records []*fit.Record, lapEnd *time.Time) tcxLap {
+	zero := 0.0
+	valueOrZero := func(value *float64) *float64 {
+		if value == nil {
+			return &zero
+		}
+		return value
+	}
+
+	tcx := tcxLap{
+		StartTime:        formatTime(lap.StartTime),
+		TotalTimeSeconds: floatString(valueOrZero(lap.TotalTimerTime), 1),
+		DistanceMeters:   floatString(valueOrZero(lap.TotalDistance), 1),
+		MaximumSpeed:     floatString(lap.MaxSpeed, 3),
+		Calories:         floatString(valueOrZero(lap.TotalCalories), 0),
+		AverageHeartRate: tcxIntValue(lap.AvgHeartRate),
+		MaximumHeartRate: tcxIntValue(lap.MaxHeartRate),
+		Intensity:        "Active",
+		Cadence:          intString(lap.AvgCadence),
+		TriggerMethod:    "Manual",
+	}
+	extension := tcxLapExtension{
+		AvgSpeed: floatString(lap.AvgSpeed, 3),
+		AvgWatts: intString(lap.AvgPower),
+		MaxWatts: intString(lap.MaxPower),
+	}
+	if extension != (tcxLapExtension{}) {
+		tcx.Extensions = &tcxLapExts{LX: extension}
+	}
+
+	track := &tcxTrack{}
+	for _, record := range records {
+		if record.Timestamp == nil {
+			continue
+		}
+		if lap.StartTime != nil && record.Timestamp.Before(*lap.StartTime) {
+			continue
+		}
+		if lapEnd != nil && !record.Timestamp.Before(*lapEnd) {
+			continue
+		}
+		track.Points = append(track.Points, tcxTrackpointFromRecord(record))
+	}
+	if len(track.Points) > 0 {
+		tcx.Track = track
+	}
+	return tcx
+}
+
+func tcxTrackpointFromRecord(record *fit.Record) tcxTrackpoint {
+	point := tcxTrackpoint{
+		Time:           formatTime(record.Timestamp),
+		AltitudeMeters: floatString(record.Altitude, 1),
+		DistanceMeters: floatString(record.Distance, 1),
+		HeartRate:      tcxIntValue(record.HeartRate),
+		Cadence:        intString(record.Cadence),
+	}
+	if record.Lat != nil && record.Long != nil {
+		point.Position = &tcxPosition{
+			Lat:  formatFloat(*record.Lat, 7),
+			Long: formatFloat(*record.Long, 7),
+		}
+	}
+	extension := tcxPointExtension{
+		Speed: floatString(record.Speed, 3),
+		Watts: intString(record.Power),
+	}
+	if extension != (tcxPointExtension{}) {
+		point.Extensions = &tcxPointsExts{TPX: extension}
+	}
+	return point
+}
+
+func tcxIntValue(value *int) *tcxValue {
+	if value == nil {
+		return nil
+	}
+	return &tcxValue{Value: intString(value)}
+}
+
+//tcxSport - TCX only knows Biking, Running and Other
+func tcxSport(workout *wahoo.Workout) string {
+	if workout == nil {
+		return "Other"
+	}
+	workoutType, ok := workout.Type()
+	if !ok {
+		return "Other"
+	}
+	switch {
+	case workoutType.IsCycling():
+		return "Biking"
+	case workoutType.IsRunning():
+		return "Running"
+	}
+	return "Other"
+}
@@ -0,0 +1,383 @@
+/*
+Package fit - a small pure Go decoder for the FIT files Wahoo stores against a workout summary
+
+Only the messages needed to work with recorded workouts are decoded (file_id, session, lap, record and event).
+Every other message, and any developer field, is read and skipped so files from any head unit can be parsed.
+*/
+package fit
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"time"
+)
+
+//Errors returned while decoding
+var (
+	ErrNotFitFile        = errors.New("fit: not a FIT file")
+	ErrTruncated         = errors.New("fit: file is truncated")
+	ErrBadCRC            = errors.New("fit: crc does not match")
+	ErrMissingDefinition = errors.New("fit: data message without a definition")
+)
+
+//fitEpoch - FIT timestamps are seconds since 1989-12-31 00:00:00 UTC
+var fitEpoch = time.Date(1989, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+//Header - the FIT file header
+type Header struct {
+	Size            uint8
+	ProtocolVersion uint8
+	ProfileVersion  uint16
+	DataSize        uint32
+	DataType        string
+	CRC             uint16
+}
+
+//File - everything decoded from a FIT file
+type File struct {
+	Header   Header
+	FileID   *FileID
+	Sessions []*Session
+	Laps     []*Lap
+	Records  []*Record
+	Events   []*Event
+	//SkippedMessages - the number of data messages that were not one of the decoded types
+	SkippedMessages int
+}
+
+//Decode - reads the whole reader and decodes it.  Chained FIT files are merged into a single File
+func Decode(r io.Reader) (*File, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeBytes(data)
+}
+
+//DecodeBytes - decodes a FIT file that is already in memory
+func DecodeBytes(data []byte) (*File, error) {
+	file := &File{}
+	first := true
+	for len(data) > 0 {
+		consumed, err := decodeOne(data, file, first)
+		if err != nil {
+			return file, err
+		}
+		data = data[consumed:]
+		first = false
+	}
+	if first {
+		return nil, ErrNotFitFile
+	}
+	return file, nil
+}
+
+//IsFitFile - quick check of the header so callers can reject the wrong kind of file early
+func IsFitFile(data []byte) bool {
+	_, err := parseHeader(data)
+	return err == nil
+}
+
+func parseHeader(data []byte) (Header, error) {
+	header := Header{}
+	if len(data) < 12 {
+		return header, ErrNotFitFile
+	}
+	header.Size = data[0]
+	if (header.Size != 12 && header.Size != 14) || len(data) < int(header.Size) {
+		return header, ErrNotFitFile
+	}
+	header.ProtocolVersion = data[1]
+	header.ProfileVersion = binary.LittleEndian.Uint16(data[2:4])
+	header.DataSize = binary.LittleEndian.Uint32(data[4:8])
+	header.DataType = string(data[8:12])
+	if header.DataType != ".FIT" {
+		return header, ErrNotFitFile
+	}
+	if header.Size == 14 {
+		header.CRC = binary.LittleEndian.Uint16(data[12:14])
+		//A zero header crc means it was not calculated
+		if header.CRC != 0 && header.CRC != CRC16(data[:12]) {
+			return header, ErrBadCRC
+		}
+	}
+	return header, nil
+}
+
+//decodeOne - decodes a single (possibly chained) file and returns how many bytes it used
+func decodeOne(data []byte, file *File, first bool) (int, error) {
+	header, err := parseHeader(data)
+	if err != nil {
+		return 0, err
+	}
+	if first {
+		file.Header = header
+	}
+	end := int(header.Size) + int(header.DataSize)
+	if end+2 > len(data) {
+		return 0, ErrTruncated
+	}
+	if binary.LittleEndian.Uint16(data[end:end+2]) != CRC16(data[:end]) {
+		return 0, ErrBadCRC
+	}
+
+	decoder := &decoder{
+		data: data[header.Size:end],
+		file: file,
+	}
+	if err := decoder.run(); err != nil {
+		return 0, err
+	}
+	return end + 2, nil
+}
+
+//fieldDefinition - a single field of a definition message
+type fieldDefinition struct {
+	num      uint8
+	size     uint8
+	baseType uint8
+}
+
+//messageDefinition - what a local message type looks like
+type messageDefinition struct {
+	bigEndian     bool
+	globalNum     uint16
+	fields        []fieldDefinition
+	devFieldsSize int
+}
+
+type decoder struct {
+	data          []byte
+	pos           int
+	file          *File
+	definitions   [16]*messageDefinition
+	lastTimestamp uint32
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, ErrTruncated
+	}
+	value := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return value, nil
+}
+
+func (d *decoder) run() error {
+	for d.pos < len(d.data) {
+		headerByte, err := d.read(1)
+		if err != nil {
+			return err
+		}
+		recordHeader := headerByte[0]
+
+		//Compressed timestamp header
+		if recordHeader&0x80 != 0 {
+			local := (recordHeader >> 5) & 0x03
+			offset := uint32(recordHeader & 0x1F)
+			timestamp := (d.lastTimestamp &^ 0x1F) + offset
+			if offset < d.lastTimestamp&0x1F {
+				timestamp += 0x20
+			}
+			if err := d.readData(local, &timestamp); err != nil {
+				return err
+			}
+			continue
+		}
+
+		local := recordHeader & 0x0F
+		if recordHeader&0x40 != 0 {
+			if err := d.readDefinition(local, recordHeader&0x20 != 0); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.readData(local, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decoder) readDefinition(local uint8, hasDevFields bool) error {
+	fixed, err := d.read(5)
+	if err != nil {
+		return err
+	}
+	definition := &messageDefinition{bigEndian: fixed[1] == 1}
+	if definition.bigEndian {
+		definition.globalNum = binary.BigEndian.Uint16(fixed[2:4])
+	} else {
+		definition.globalNum = binary.LittleEndian.Uint16(fixed[2:4])
+	}
+	fieldBytes, err := d.read(int(fixed[4]) * 3)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(fieldBytes); i += 3 {
+		definition.fields = append(definition.fields, fieldDefinition{
+			num:      fieldBytes[i],
+			size:     fieldBytes[i+1],
+			baseType: fieldBytes[i+2],
+		})
+	}
+	if hasDevFields {
+		count, err := d.read(1)
+		if err != nil {
+			return err
+		}
+		devBytes, err := d.read(int(count[0]) * 3)
+		if err != nil {
+			return err
+		}
+		//Developer fields are not decoded, only their size is needed to skip them
+		for i := 0; i < len(devBytes); i += 3 {
+			definition.devFieldsSize += int(devBytes[i+1])
+		}
+	}
+	d.definitions[local] = definition
+	return nil
+}
+
+func (d *decoder) readData(local uint8, compressedTimestamp *uint32) error {
+	definition := d.definitions[local]
+	if definition == nil {
+		return ErrMissingDefinition
+	}
+	message := message{}
+	for _, field := range definition.fields {
+		raw, err := d.read(int(field.size))
+		if err != nil {
+			return err
+		}
+		if value, ok := decodeValue(raw, field.baseType, definition.bigEndian); ok {
+			message[field.num] = value
+		}
+	}
+	if _, err := d.read(definition.devFieldsSize); err != nil {
+		return err
+	}
+
+	if compressedTimestamp != nil {
+		message[fieldTimestamp] = float64(*compressedTimestamp)
+		d.lastTimestamp = *compressedTimestamp
+	} else if timestamp, ok := message[fieldTimestamp]; ok {
+		d.lastTimestamp = uint32(timestamp)
+	}
+
+	d.file.addMessage(definition.globalNum, message)
+	return nil
+}
+
+//message - the decoded numeric values of a data message keyed by field number.  Invalid values are left out
+type message map[uint8]float64
+
+//decodeValue - converts the raw bytes into a number.  Only the first element of an array is used
+func decodeValue(raw []byte, baseType uint8, bigEndian bool) (float64, bool) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	size := baseTypeSize(baseType)
+	if size == 0 || len(raw) < size {
+		return 0, false
+	}
+	switch baseType {
+	case 0x00, 0x02, 0x0D: //enum, uint8, byte
+		return float64(raw[0]), raw[0] != 0xFF
+	case 0x0A: //uint8z
+		return float64(raw[0]), raw[0] != 0x00
+	case 0x01: //sint8
+		return float64(int8(raw[0])), raw[0] != 0x7F
+	case 0x83: //sint16
+		value := order.Uint16(raw)
+		return float64(int16(value)), value != 0x7FFF
+	case 0x84: //uint16
+		value := order.Uint16(raw)
+		return float64(value), value != 0xFFFF
+	case 0x8B: //uint16z
+		value := order.Uint16(raw)
+		return float64(value), value != 0
+	case 0x85: //sint32
+		value := order.Uint32(raw)
+		return float64(int32(value)), value != 0x7FFFFFFF
+	case 0x86: //uint32
+		value := order.Uint32(raw)
+		return float64(value), value != 0xFFFFFFFF
+	case 0x8C: //uint32z
+		value := order.Uint32(raw)
+		return float64(value), value != 0
+	case 0x88: //float32
+		value := order.Uint32(raw)
+		return float64(math.Float32frombits(value)), value != 0xFFFFFFFF
+	case 0x89: //float64
+		value := order.Uint64(raw)
+		return math.Float64frombits(value), value != 0xFFFFFFFFFFFFFFFF
+	case 0x8E: //sint64
+		value := order.Uint64(raw)
+		return float64(int64(value)), value != 0x7FFFFFFFFFFFFFFF
+	case 0x8F: //uint64
+		value := order.Uint64(raw)
+		return float64(value), value != 0xFFFFFFFFFFFFFFFF
+	case 0x90: //uint64z
+		value := order.Uint64(raw)
+		return float64(value), value != 0
+	}
+	return 0, false
+}
+
+func baseTypeSize(baseType uint8) int {
+	switch baseType {
+	case 0x00, 0x01, 0x02, 0x0A, 0x0D:
+		return 1
+	case 0x83, 0x84, 0x8B:
+		return 2
+	case 0x85, 0x86, 0x88, 0x8C:
+		return 4
+	case 0x89, 0x8E, 0x8F, 0x90:
+		return 8
+	}
+	//Strings and anything unknown are skipped
+	return 0
+}
+
+//ToTime - converts a FIT timestamp into a time.Time
+func ToTime(timestamp uint32) time.Time {
+	return fitEpoch.Add(time.Duration(timestamp) * time.Second)
+}
+
+//FromTime - converts a time.Time into a FIT timestamp
+func FromTime(t time.Time) uint32 {
+	return uint32(t.Sub(fitEpoch) / time.Second)
+}
+
+//SemicirclesToDegrees - FIT stores positions as semicircles
+func SemicirclesToDegrees(semicircles int32) float64 {
+	return float64(semicircles) * (180.0 / math.Pow(2, 31))
+}
+
+//DegreesToSemicircles - the inverse of SemicirclesToDegrees
+func DegreesToSemicircles(degrees float64) int32 {
+	return int32(math.Round(degrees * (math.Pow(2, 31) / 180.0)))
+}
+
+//CRC16 - the FIT crc over the data
+func CRC16(data []byte) uint16 {
+	crcTable := [16]uint16{
+		0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+		0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+	}
+	var crc uint16
+	for _, b := range data {
+		tmp := crcTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ crcTable[b&0xF]
+		tmp = crcTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ crcTable[(b>>4)&0xF]
+	}
+	return crc
+}
@@ -0,0 +1,254 @@
+package fit
+
+import "time"
+
+//Global message numbers that are decoded
+const (
+	MesgNumFileID  = 0
+	MesgNumSession = 18
+	MesgNumLap     = 19
+	MesgNumRecord  = 20
+	MesgNumEvent   = 21
+)
+
+//fieldTimestamp - the timestamp field number shared by every message
+const fieldTimestamp = 253
+
+//FileID - the file_id message
+type FileID struct {
+	Type         *int
+	Manufacturer *int
+	Product      *int
+	SerialNumber *int64
+	TimeCreated  *time.Time
+}
+
+//Session - the session message.  Times are in seconds, distances in meters, speeds in m/s and work in joules
+type Session struct {
+	Timestamp           *time.Time
+	StartTime           *time.Time
+	StartPositionLat    *float64
+	StartPositionLong   *float64
+	Sport               *int
+	SubSport            *int
+	TotalElapsedTime    *float64
+	TotalTimerTime      *float64
+	TotalDistance       *float64
+	TotalCalories       *float64
+	TotalWork           *float64
+	AvgSpeed            *float64
+	MaxSpeed            *float64
+	AvgHeartRate        *int
+	MaxHeartRate        *int
+	AvgCadence          *int
+	MaxCadence          *int
+	AvgPower            *int
+	MaxPower            *int
+	NormalizedPower     *int
+	TrainingStressScore *float64
+	TotalAscent         *int
+	TotalDescent        *int
+}
+
+//Lap - the lap message.  Same units as Session
+type Lap struct {
+	Timestamp         *time.Time
+	StartTime         *time.Time
+	StartPositionLat  *float64
+	StartPositionLong *float64
+	Sport             *int
+	SubSport          *int
+	TotalElapsedTime  *float64
+	TotalTimerTime    *float64
+	TotalDistance     *float64
+	TotalCalories     *float64
+	TotalWork         *float64
+	AvgSpeed          *float64
+	MaxSpeed          *float64
+	AvgHeartRate      *int
+	MaxHeartRate      *int
+	AvgCadence        *int
+	MaxCadence        *int
+	AvgPower          *int
+	MaxPower          *int
+	NormalizedPower   *int
+	TotalAscent       *int
+	TotalDescent      *int
+}
+
+//Record - a single sample.  Position is in degrees, altitude in meters, distance in meters and speed in m/s
+type Record struct {
+	Timestamp   *time.Time
+	Lat         *float64
+	Long        *float64
+	Altitude    *float64
+	HeartRate   *int
+	Cadence     *int
+	Distance    *float64
+	Speed       *float64
+	Power       *int
+	Temperature *int
+}
+
+//Event types and events that are useful when working out paused time
+const (
+	EventTimer     = 0
+	EventTypeStart = 0
+	EventTypeStop  = 1
+	//EventTypeStopAll - the timer was stopped (auto pause or the user pressed stop)
+	EventTypeStopAll = 4
+)
+
+//Event - the event message
+type Event struct {
+	Timestamp  *time.Time
+	Event      *int
+	EventType  *int
+	Data       *int64
+	EventGroup *int
+}
+
+func (f *File) addMessage(globalNum uint16, m message) {
+	switch globalNum {
+	case MesgNumFileID:
+		f.FileID = &FileID{
+			Type:         m.int(0),
+			Manufacturer: m.int(1),
+			Product:      m.int(2),
+			SerialNumber: m.int64(3),
+			TimeCreated:  m.time(4),
+		}
+	case MesgNumSession:
+		f.Sessions = append(f.Sessions, &Session{
+			Timestamp:           m.time(fieldTimestamp),
+			StartTime:           m.time(2),
+			StartPositionLat:    m.position(3),
+			StartPositionLong:   m.position(4),
+			Sport:               m.int(5),
+			SubSport:            m.int(6),
+			TotalElapsedTime:    m.scaled(7, 1000, 0),
+			TotalTimerTime:      m.scaled(8, 1000, 0),
+			TotalDistance:       m.scaled(9, 100, 0),
+			TotalCalories:       m.scaled(11, 1, 0),
+			AvgSpeed:            m.firstScaled(124, 14, 1000),
+			MaxSpeed:            m.firstScaled(125, 15, 1000),
+			AvgHeartRate:        m.int(16),
+			MaxHeartRate:        m.int(17),
+			AvgCadence:          m.int(18),
+			MaxCadence:          m.int(19),
+			AvgPower:            m.int(20),
+			MaxPower:            m.int(21),
+			TotalAscent:         m.int(22),
+			TotalDescent:        m.int(23),
+			NormalizedPower:     m.int(34),
+			TrainingStressScore: m.scaled(35, 10, 0),
+			TotalWork:           m.scaled(48, 1, 0),
+		})
+	case MesgNumLap:
+		f.Laps = append(f.Laps, &Lap{
+			Timestamp:         m.time(fieldTimestamp),
+			StartTime:         m.time(2),
+			StartPositionLat:  m.position(3),
+			StartPositionLong: m.position(4),
+			TotalElapsedTime:  m.scaled(7, 1000, 0),
+			TotalTimerTime:    m.scaled(8, 1000, 0),
+			TotalDistance:     m.scaled(9, 100, 0),
+			TotalCalories:     m.scaled(11, 1, 0),
+			AvgSpeed:          m.firstScaled(110, 13, 1000),
+			MaxSpeed:          m.firstScaled(111, 14, 1000),
+			AvgHeartRate:      m.int(15),
+			MaxHeartRate:      m.int(16),
+			AvgCadence:        m.int(17),
+			MaxCadence:        m.int(18),
+			AvgPower:          m.int(19),
+			MaxPower:          m.int(20),
+			TotalAscent:       m.int(21),
+			TotalDescent:      m.int(22),
+			Sport:             m.int(25),
+			NormalizedPower:   m.int(33),
+			SubSport:          m.int(39),
+			TotalWork:         m.scaled(41, 1, 0),
+		})
+	case MesgNumRecord:
+		altitude := m.scaled(78, 5, 500)
+		if altitude == nil {
+			altitude = m.scaled(2, 5, 500)
+		}
+		f.Records = append(f.Records, &Record{
+			Timestamp:   m.time(fieldTimestamp),
+			Lat:         m.position(0),
+			Long:        m.position(1),
+			Altitude:    altitude,
+			HeartRate:   m.int(3),
+			Cadence:     m.int(4),
+			Distance:    m.scaled(5, 100, 0),
+			Speed:       m.firstScaled(73, 6, 1000),
+			Power:       m.int(7),
+			Temperature: m.int(13),
+		})
+	case MesgNumEvent:
+		f.Events = append(f.Events, &Event{
+			Timestamp:  m.time(fieldTimestamp),
+			Event:      m.int(0),
+			EventType:  m.int(1),
+			Data:       m.int64(3),
+			EventGroup: m.int(4),
+		})
+	default:
+		f.SkippedMessages++
+	}
+}
+
+func (m message) int(field uint8) *int {
+	value, ok := m[field]
+	if !ok {
+		return nil
+	}
+	intValue := int(value)
+	return &intValue
+}
+
+func (m message) int64(field uint8) *int64 {
+	value, ok := m[field]
+	if !ok {
+		return nil
+	}
+	intValue := int64(value)
+	return &intValue
+}
+
+func (m message) time(field uint8) *time.Time {
+	value, ok := m[field]
+	if !ok {
+		return nil
+	}
+	t := ToTime(uint32(value))
+	return &t
+}
+
+func (m message) position(field uint8) *float64 {
+	value, ok := m[field]
+	if !ok {
+		return nil
+	}
+	degrees := SemicirclesToDegrees(int32(value))
+	return &degrees
+}
+
+//scaled - applies the FIT scale and offset (value / scale - offset)
+func (m message) scaled(field uint8, scale, offset float64) *float64 {
+	value, ok := m[field]
+	if !ok {
+		return nil
+	}
+	scaledValue := value/scale - offset
+	return &scaledValue
+}
+
+//firstScaled - prefers the enhanced field and falls back to the original one
+func (m message) firstScaled(enhanced, original uint8, scale float64) *float64 {
+	if value := m.scaled(enhanced, scale, 0); value != nil {
+		return value
+	}
+	return m.scaled(original, scale, 0)
+}
@@ -0,0 +1,60 @@
+package fit
+
+//Sport values from the FIT profile (the sport field of the session and lap messages)
+const (
+	SportGeneric               = 0
+	SportRunning               = 1
+	SportCycling               = 2
+	SportTransition            = 3
+	SportFitnessEquipment      = 4
+	SportSwimming              = 5
+	SportTraining              = 10
+	SportWalking               = 11
+	SportCrossCountrySkiing    = 12
+	SportAlpineSkiing          = 13
+	SportSnowboarding          = 14
+	SportRowing                = 15
+	SportMountaineering        = 16
+	SportHiking                = 17
+	SportMultisport            = 18
+	SportPaddling              = 19
+	SportEBiking               = 21
+	SportMotorcycling          = 22
+	SportGolf                  = 25
+	SportInlineSkating         = 30
+	SportSailing               = 32
+	SportIceSkating            = 33
+	SportStandUpPaddleboarding = 37
+	SportKayaking              = 41
+	SportWindsurfing           = 43
+	SportKitesurfing           = 44
+)
+
+//SubSport values from the FIT profile (the sub_sport field of the session and lap messages)
+const (
+	SubSportGeneric          = 0
+	SubSportTreadmill        = 1
+	SubSportStreet           = 2
+	SubSportTrail            = 3
+	SubSportTrack            = 4
+	SubSportSpin             = 5
+	SubSportIndoorCycling    = 6
+	SubSportRoad             = 7
+	SubSportMountain         = 8
+	SubSportDownhill         = 9
+	SubSportRecumbent        = 10
+	SubSportCyclocross       = 11
+	SubSportTrackCycling     = 13
+	SubSportIndoorRowing     = 14
+	SubSportElliptical       = 15
+	SubSportStairClimbing    = 16
+	SubSportLapSwimming      = 17
+	SubSportOpenWater        = 18
+	SubSportStrengthTraining = 20
+	SubSportCardioTraining   = 26
+	SubSportIndoorWalking    = 27
+	SubSportSpeedWalking     = 31
+	SubSportIndoorRunning    = 45
+	SubSportGravelCycling    = 46
+	SubSportVirtualActivity  = 58
+)
@@ -0,0 +1,182 @@
+package plan
+
+import "time"
+
+/*
+Builder - builds a plan file step by step
+
+	file, err := plan.NewBuilder("Sweet Spot 3x10").
+		FTP(250).
+		Warmup(10*time.Minute, plan.PercentFTP(0.5, 0.65)).
+		Repeat(3, func(r *plan.Builder) {
+			r.Steady("Sweet Spot", 10*time.Minute, plan.PercentFTP(0.88, 0.93))
+			r.Recover(5*time.Minute, plan.PercentFTP(0.5, 0.55))
+		}).
+		Cooldown(10*time.Minute, plan.PercentFTP(0.4, 0.5)).
+		Build()
+*/
+type Builder struct {
+	file      *File
+	intervals *[]*Interval
+}
+
+//NewBuilder - starts a plan with the given name
+func NewBuilder(name string) *Builder {
+	file := &File{Header: Header{Name: name, Version: Version}}
+	return &Builder{file: file, intervals: &file.Intervals}
+}
+
+//Description - sets the plan description
+func (b *Builder) Description(description string) *Builder {
+	b.file.Header.Description = description
+	return b
+}
+
+//WorkoutType - sets the workout type family and location (e.g. biking and indoor)
+func (b *Builder) WorkoutType(family, location int) *Builder {
+	b.file.Header.WorkoutTypeFamily = &family
+	b.file.Header.WorkoutTypeLocation = &location
+	return b
+}
+
+//FTP - sets the FTP (watts) that ftp targets are relative to
+func (b *Builder) FTP(watts int) *Builder {
+	b.file.Header.FTP = &watts
+	return b
+}
+
+//ThresholdHR - sets the threshold heart rate that threshold_hr targets are relative to
+func (b *Builder) ThresholdHR(bpm int) *Builder {
+	b.file.Header.ThresholdHR = &bpm
+	return b
+}
+
+//MaxHR - sets the max heart rate that max_hr targets are relative to
+func (b *Builder) MaxHR(bpm int) *Builder {
+	b.file.Header.MaxHR = &bpm
+	return b
+}
+
+//ThresholdSpeed - sets the threshold speed (m/s) that threshold_speed targets are relative to
+func (b *Builder) ThresholdSpeed(metersPerSecond float64) *Builder {
+	b.file.Header.ThresholdSpeed = &metersPerSecond
+	return b
+}
+
+//Interval - adds an interval as is
+func (b *Builder) Interval(interval *Interval) *Builder {
+	*b.intervals = append(*b.intervals, interval)
+	return b
+}
+
+//Timed - adds a time based interval
+func (b *Builder) Timed(name string, intensity IntensityType, duration time.Duration, targets ...Target) *Builder {
+	return b.Interval(&Interval{
+		Name:             name,
+		ExitTriggerType:  TriggerTime,
+		ExitTriggerValue: duration.Seconds(),
+		IntensityType:    intensity,
+		Targets:          targets,
+	})
+}
+
+//Distance - adds an interval that ends after the distance in meters
+func (b *Builder) Distance(name string, intensity IntensityType, meters float64, targets ...Target) *Builder {
+	return b.Interval(&Interval{
+		Name:             name,
+		ExitTriggerType:  TriggerDistance,
+		ExitTriggerValue: meters,
+		IntensityType:    intensity,
+		Targets:          targets,
+	})
+}
+
+//Work - adds an interval that ends after the work in kilojoules
+func (b *Builder) Work(name string, intensity IntensityType, kilojoules float64, targets ...Target) *Builder {
+	return b.Interval(&Interval{
+		Name:             name,
+		ExitTriggerType:  TriggerKJ,
+		ExitTriggerValue: kilojoules,
+		IntensityType:    intensity,
+		Targets:          targets,
+	})
+}
+
+//Warmup - adds a timed warm up
+func (b *Builder) Warmup(duration time.Duration, targets ...Target) *Builder {
+	return b.Timed("Warm Up", IntensityWarmup, duration, targets...)
+}
+
+//Steady - adds a timed active interval
+func (b *Builder) Steady(name string, duration time.Duration, targets ...Target) *Builder {
+	return b.Timed(name, IntensityActive, duration, targets...)
+}
+
+//Recover - adds a timed recovery
+func (b *Builder) Recover(duration time.Duration, targets ...Target) *Builder {
+	return b.Timed("Recover", IntensityRecover, duration, targets...)
+}
+
+//Cooldown - adds a timed cool down
+func (b *Builder) Cooldown(duration time.Duration, targets ...Target) *Builder {
+	return b.Timed("Cool Down", IntensityCooldown, duration, targets...)
+}
+
+//Repeat - adds a repeat.  The intervals added to the builder passed to steps are the ones repeated
+func (b *Builder) Repeat(times int, steps func(*Builder)) *Builder {
+	repeat := &Interval{
+		ExitTriggerType:  TriggerRepeat,
+		ExitTriggerValue: float64(times),
+	}
+	if steps != nil {
+		steps(&Builder{file: b.file, intervals: &repeat.Intervals})
+	}
+	return b.Interval(repeat)
+}
+
+//Build - validates and returns the plan file
+func (b *Builder) Build() (*File, error) {
+	if err := b.file.Validate(); err != nil {
+		return nil, err
+	}
+	return b.file, nil
+}
+
+func newTarget(targetType TargetType, low, high float64) Target {
+	return Target{Type: targetType, Low: &low, High: &high}
+}
+
+//PercentFTP - a target between low and high fractions of FTP (0.9 is 90%)
+func PercentFTP(low, high float64) Target {
+	return newTarget(TargetFTP, low, high)
+}
+
+//Watts - an absolute power target
+func Watts(low, high float64) Target {
+	return newTarget(TargetWatts, low, high)
+}
+
+//HeartRate - an absolute heart rate target in bpm
+func HeartRate(low, high float64) Target {
+	return newTarget(TargetHeartRate, low, high)
+}
+
+//PercentThresholdHR - a target between low and high fractions of threshold heart rate
+func PercentThresholdHR(low, high float64) Target {
+	return newTarget(TargetThresholdHR, low, high)
+}
+
+//PercentMaxHR - a target between low and high fractions of max heart rate
+func PercentMaxHR(low, high float64) Target {
+	return newTarget(TargetMaxHR, low, high)
+}
+
+//RPE - a rate of perceived exertion target from 1 to 10
+func RPE(value float64) Target {
+	return newTarget(TargetRPE, value, value)
+}
+
+//Cadence - a cadence target in rpm
+func Cadence(low, high float64) Target {
+	return newTarget(TargetCadence, low, high)
+}
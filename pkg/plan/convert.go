@@ -0,0 +1,80 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+//ConversionReport - what could not be converted exactly when importing or exporting another format
+type ConversionReport struct {
+	Warnings []string
+}
+
+func (r *ConversionReport) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+//Exact - true when nothing was skipped or approximated
+func (r *ConversionReport) Exact() bool {
+	return len(r.Warnings) == 0
+}
+
+//RampStepSeconds - ramps are not supported by plan files so they are split into steps of this length
+var RampStepSeconds = 60.0
+
+//errNoFTP - relative power targets need an FTP in the header
+var errNoFTP = errors.New("plan: an FTP is needed to convert relative power")
+
+//intensityForPower - a best guess intensity type from a fraction of FTP
+func intensityForPower(fraction float64) IntensityType {
+	switch {
+	case fraction < 0.56:
+		return IntensityRecover
+	case fraction < 0.76:
+		return IntensityActive
+	case fraction < 0.88:
+		return IntensityTempo
+	case fraction < 1.06:
+		return IntensityLT
+	case fraction < 1.21:
+		return IntensityMAP
+	}
+	return IntensityAC
+}
+
+/*
+rampSteps - splits a ramp from low to high into steps of RampStepSeconds
+
+Each step holds the value from the middle of the step so the average over the ramp is the same.
+*/
+func rampSteps(name string, intensity IntensityType, targetType TargetType, seconds, low, high float64) []*Interval {
+	count := int(math.Ceil(seconds / RampStepSeconds))
+	if count < 1 {
+		count = 1
+	}
+	stepLength := seconds / float64(count)
+	steps := make([]*Interval, 0, count)
+	for i := 0; i < count; i++ {
+		middle := (float64(i) + 0.5) / float64(count)
+		value := roundTo(low+(high-low)*middle, 3)
+		steps = append(steps, &Interval{
+			Name:             name,
+			ExitTriggerType:  TriggerTime,
+			ExitTriggerValue: roundTo(stepLength, 3),
+			IntensityType:    intensity,
+			Targets:          []Target{newTarget(targetType, value, value)},
+		})
+	}
+	return steps
+}
+
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
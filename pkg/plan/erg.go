@@ -0,0 +1,225 @@
+package plan
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//ergPoint - a point on the course: minutes from the start and watts (ERG) or percent of FTP (MRC)
+type ergPoint struct {
+	minutes float64
+	value   float64
+}
+
+//ergCourse - a parsed .erg or .mrc file
+type ergCourse struct {
+	header  map[string]string
+	units   string
+	points  []ergPoint
+	hasText bool
+}
+
+/*
+ImportERG - converts a TrainerRoad style .erg workout (minutes and watts) into a plan
+
+The targets stay as absolute watts.  ftp is written to the header when the file does not have an FTP line so the
+plan can still be scaled later; pass 0 to leave it out.
+*/
+func ImportERG(r io.Reader, ftp int) (*File, *ConversionReport, error) {
+	course, err := parseErgCourse(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if course.units != "WATTS" {
+		return nil, nil, errors.New("plan: erg files must be in MINUTES WATTS, use ImportMRC for percent")
+	}
+	if fileFTP, ok := course.ftp(); ok {
+		ftp = fileFTP
+	}
+	return course.toPlan(TargetWatts, 1, ftp)
+}
+
+/*
+ImportMRC - converts a .mrc workout (minutes and percent of FTP) into a plan
+
+The targets are ftp targets.  The FTP line in the file is used for the header and ftp is the fallback when it does
+not have one.
+*/
+func ImportMRC(r io.Reader, ftp int) (*File, *ConversionReport, error) {
+	course, err := parseErgCourse(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if course.units != "PERCENT" {
+		return nil, nil, errors.New("plan: mrc files must be in MINUTES PERCENT, use ImportERG for watts")
+	}
+	if fileFTP, ok := course.ftp(); ok {
+		ftp = fileFTP
+	}
+	if ftp <= 0 {
+		return nil, nil, errNoFTP
+	}
+	return course.toPlan(TargetFTP, 0.01, ftp)
+}
+
+func parseErgCourse(r io.Reader) (*ergCourse, error) {
+	course := &ergCourse{header: map[string]string{}}
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		upper := strings.ToUpper(line)
+		if strings.HasPrefix(upper, "[") {
+			switch upper {
+			case "[COURSE HEADER]", "[COURSE DATA]", "[COURSE TEXT]":
+				section = upper
+				if upper == "[COURSE TEXT]" {
+					course.hasText = true
+				}
+			default:
+				section = ""
+			}
+			continue
+		}
+
+		switch section {
+		case "[COURSE HEADER]":
+			if strings.HasPrefix(upper, "MINUTES") {
+				fields := strings.Fields(upper)
+				if len(fields) == 2 {
+					course.units = fields[1]
+				}
+				continue
+			}
+			if index := strings.Index(line, "="); index > 0 {
+				course.header[strings.ToUpper(strings.TrimSpace(line[:index]))] = strings.TrimSpace(line[index+1:])
+			}
+		case "[COURSE DATA]":
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				return nil, errors.New("plan: bad course data line: " + line)
+			}
+			minutes, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				return nil, err
+			}
+			value, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return nil, err
+			}
+			course.points = append(course.points, ergPoint{minutes: minutes, value: value})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(course.points) < 2 {
+		return nil, errors.New("plan: the course needs at least two data points")
+	}
+	return course, nil
+}
+
+func (c *ergCourse) ftp() (int, bool) {
+	value, ok := c.header["FTP"]
+	if !ok {
+		return 0, false
+	}
+	ftp, err := strconv.ParseFloat(value, 64)
+	if err != nil || ftp <= 0 {
+		return 0, false
+	}
+	return int(ftp + 0.5), true
+}
+
+/*
+toPlan - turns the course points into intervals
+
+Two points at the same minute are a step.  A flat line between two points is a steady interval and a sloped line is
+a ramp which is split into steps.  Back to back steady intervals at the same value are merged.
+*/
+func (c *ergCourse) toPlan(targetType TargetType, scale float64, ftp int) (*File, *ConversionReport, error) {
+	report := &ConversionReport{}
+
+	name := c.header["FILE NAME"]
+	if name == "" {
+		name = "Imported Workout"
+	}
+	builder := NewBuilder(name).Description(c.header["DESCRIPTION"])
+	if ftp > 0 {
+		builder.FTP(ftp)
+	}
+	if c.hasText {
+		report.warn("course text was dropped")
+	}
+	keys := make([]string, 0, len(c.header))
+	for key := range c.header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch key {
+		case "VERSION", "UNITS", "DESCRIPTION", "FILE NAME", "FTP":
+		default:
+			report.warn("header %s was dropped", key)
+		}
+	}
+
+	var last *Interval
+	for i := 0; i+1 < len(c.points); i++ {
+		start, end := c.points[i], c.points[i+1]
+		if end.minutes < start.minutes {
+			return nil, report, errors.New("plan: course data must be in time order")
+		}
+		seconds := roundTo((end.minutes-start.minutes)*60, 3)
+		if seconds == 0 {
+			continue
+		}
+		low := roundTo(start.value*scale, 3)
+		high := roundTo(end.value*scale, 3)
+		if low == high {
+			if last != nil && last.Targets[0].Type == targetType && *last.Targets[0].Low == low {
+				last.ExitTriggerValue += seconds
+				continue
+			}
+			last = &Interval{
+				Name:             "Steady",
+				ExitTriggerType:  TriggerTime,
+				ExitTriggerValue: seconds,
+				IntensityType:    ergIntensity(targetType, low, ftp),
+				Targets:          []Target{newTarget(targetType, low, low)},
+			}
+			builder.Interval(last)
+			continue
+		}
+		steps := rampSteps("Ramp", ergIntensity(targetType, (low+high)/2, ftp), targetType, seconds, low, high)
+		report.warn("ramp at minute %g from %g to %g split into %d steps", start.minutes, start.value, end.value, len(steps))
+		for _, step := range steps {
+			builder.Interval(step)
+		}
+		last = nil
+	}
+
+	file, err := builder.Build()
+	if err != nil {
+		return nil, report, err
+	}
+	return file, report, nil
+}
+
+//ergIntensity - intensity from the target, watts need the FTP to be worked out
+func ergIntensity(targetType TargetType, value float64, ftp int) IntensityType {
+	if targetType == TargetFTP {
+		return intensityForPower(value)
+	}
+	if ftp > 0 {
+		return intensityForPower(value / float64(ftp))
+	}
+	return IntensityActive
+}
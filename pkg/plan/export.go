@@ -0,0 +1,330 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+//Fetch - downloads and parses the plan file of a plan returned by GetPlan or ListPlans
+func Fetch(ctx context.Context, client *wahoo.Client, wahooPlan *wahoo.Plan) (*File, error) {
+	if client == nil {
+		return nil, errMissing
+	}
+	buffer := &bytes.Buffer{}
+	if _, err := client.DownloadPlanFile(ctx, wahooPlan, buffer); err != nil {
+		return nil, err
+	}
+	return Parse(buffer.Bytes())
+}
+
+//errMissing - matches the error the client returns for missing values
+var errMissing = errors.New("Missing Mandatory Value")
+
+//step - a single timed interval once repeats are unrolled.  Power is a fraction of FTP, nil when there is no target
+type step struct {
+	path      string
+	seconds   float64
+	power     *float64
+	cadence   *float64
+	intensity IntensityType
+}
+
+//resolveFTP - the user's FTP wins over the FTP the plan was written with
+func resolveFTP(file *File, powerZone *wahoo.PowerZone) int {
+	if powerZone != nil && powerZone.Ftp != nil && *powerZone.Ftp > 0 {
+		return *powerZone.Ftp
+	}
+	if file.Header.FTP != nil && *file.Header.FTP > 0 {
+		return *file.Header.FTP
+	}
+	return 0
+}
+
+//flattenSteps - every interval in the plan as timed steps, see intervalSteps
+func flattenSteps(file *File, ftp int, report *ConversionReport) []step {
+	steps := []step{}
+	for i, interval := range file.Intervals {
+		steps = append(steps, intervalSteps(file, ftp, report, "intervals["+strconv.Itoa(i)+"]", interval)...)
+	}
+	return steps
+}
+
+/*
+intervalSteps - unrolls repeats and turns an interval into timed steps with power as a fraction of FTP
+
+Watts targets are divided by the FTP and other relative power targets (map, ac, nm) use their header value.
+Distance intervals can not be timed so they are skipped.  kJ intervals are timed from their power target.
+*/
+func intervalSteps(file *File, ftp int, report *ConversionReport, path string, interval *Interval) []step {
+	if interval == nil {
+		return nil
+	}
+	if interval.ExitTriggerType == TriggerRepeat {
+		steps := []step{}
+		for r := 0; r < int(interval.ExitTriggerValue); r++ {
+			for i, child := range interval.Intervals {
+				childPath := path + ".intervals[" + strconv.Itoa(i) + "]"
+				steps = append(steps, intervalSteps(file, ftp, report, childPath, child)...)
+			}
+		}
+		return steps
+	}
+
+	current := step{path: path, intensity: interval.IntensityType}
+	current.power = targetPower(file, interval, ftp, report, path)
+	for _, target := range interval.Targets {
+		if target.Type == TargetCadence {
+			current.cadence = targetMiddle(target)
+		}
+	}
+	switch interval.ExitTriggerType {
+	case TriggerTime:
+		current.seconds = interval.ExitTriggerValue
+	case TriggerKJ:
+		if current.power == nil || *current.power <= 0 || ftp <= 0 {
+			report.warn("%s: kJ interval without a power target skipped", path)
+			return nil
+		}
+		current.seconds = roundTo(interval.ExitTriggerValue*1000/(*current.power*float64(ftp)), 0)
+		report.warn("%s: kJ interval converted to %g seconds", path, current.seconds)
+	default:
+		report.warn("%s: %s intervals can not be timed and were skipped", path, interval.ExitTriggerType)
+		return nil
+	}
+	return []step{current}
+}
+
+//targetPower - the power target of an interval as a fraction of FTP
+func targetPower(file *File, interval *Interval, ftp int, report *ConversionReport, path string) *float64 {
+	for _, target := range interval.Targets {
+		middle := targetMiddle(target)
+		if middle == nil {
+			continue
+		}
+		if target.Low != nil && target.High != nil && *target.Low != *target.High {
+			report.warn("%s: %s range %g-%g written as %g", path, target.Type, *target.Low, *target.High, *middle)
+		}
+		var reference *int
+		switch target.Type {
+		case TargetFTP:
+			return middle
+		case TargetWatts:
+			if ftp <= 0 {
+				report.warn("%s: watts target can not be converted without an FTP", path)
+				return nil
+			}
+			fraction := roundTo(*middle/float64(ftp), 3)
+			return &fraction
+		case TargetMAP:
+			reference = file.Header.MAP
+		case TargetAC:
+			reference = file.Header.AC
+		case TargetNM:
+			reference = file.Header.NM
+		default:
+			continue
+		}
+		if reference == nil || ftp <= 0 {
+			report.warn("%s: %s target can not be converted", path, target.Type)
+			return nil
+		}
+		fraction := roundTo(*middle*float64(*reference)/float64(ftp), 3)
+		return &fraction
+	}
+	return nil
+}
+
+func targetMiddle(target Target) *float64 {
+	switch {
+	case target.Low != nil && target.High != nil:
+		middle := (*target.Low + *target.High) / 2
+		return &middle
+	case target.Low != nil:
+		return target.Low
+	case target.High != nil:
+		return target.High
+	}
+	return nil
+}
+
+/*
+ExportZWO - writes the plan as a Zwift .zwo workout
+
+ZWO power is a fraction of FTP so percent targets are kept as they are and watts are converted with the user's FTP
+(from powerZone, or the plan header when powerZone has none).  Repeats of two timed intervals become IntervalsT and
+other repeats are unrolled.  Intervals without a power target become FreeRide segments.
+*/
+func ExportZWO(w io.Writer, file *File, powerZone *wahoo.PowerZone) (*ConversionReport, error) {
+	if w == nil || file == nil {
+		return nil, errMissing
+	}
+	report := &ConversionReport{}
+	ftp := resolveFTP(file, powerZone)
+
+	document := zwoFile{
+		Author:      "Wahoo",
+		Name:        file.Header.Name,
+		Description: file.Header.Description,
+		SportType:   "bike",
+	}
+	for i, interval := range file.Intervals {
+		path := "intervals[" + strconv.Itoa(i) + "]"
+		if interval != nil && interval.ExitTriggerType == TriggerRepeat && isSimpleRepeat(interval) {
+			on := intervalSteps(file, ftp, report, path+".intervals[0]", interval.Intervals[0])
+			off := intervalSteps(file, ftp, report, path+".intervals[1]", interval.Intervals[1])
+			if len(on) == 1 && len(off) == 1 && on[0].power != nil && off[0].power != nil {
+				document.Workout.Segments = append(document.Workout.Segments, zwoSegment{
+					XMLName:        xml.Name{Local: "IntervalsT"},
+					Repeat:         int(interval.ExitTriggerValue),
+					OnDuration:     on[0].seconds,
+					OffDuration:    off[0].seconds,
+					OnPower:        on[0].power,
+					OffPower:       off[0].power,
+					Cadence:        on[0].cadence,
+					CadenceResting: off[0].cadence,
+				})
+				continue
+			}
+			for r := 0; r < int(interval.ExitTriggerValue); r++ {
+				for _, s := range append(on, off...) {
+					document.Workout.Segments = append(document.Workout.Segments, zwoSegmentFromStep(s))
+				}
+			}
+			continue
+		}
+		for _, s := range intervalSteps(file, ftp, report, path, interval) {
+			document.Workout.Segments = append(document.Workout.Segments, zwoSegmentFromStep(s))
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return report, err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "    ")
+	if err := encoder.Encode(document); err != nil {
+		return report, err
+	}
+	_, err := io.WriteString(w, "\n")
+	return report, err
+}
+
+//isSimpleRepeat - a repeat of exactly two timed intervals
+func isSimpleRepeat(interval *Interval) bool {
+	if len(interval.Intervals) != 2 {
+		return false
+	}
+	for _, child := range interval.Intervals {
+		if child == nil || child.ExitTriggerType != TriggerTime {
+			return false
+		}
+	}
+	return true
+}
+
+func zwoSegmentFromStep(s step) zwoSegment {
+	if s.power == nil {
+		return zwoSegment{XMLName: xml.Name{Local: "FreeRide"}, Duration: s.seconds, Cadence: s.cadence}
+	}
+	name := "SteadyState"
+	switch s.intensity {
+	case IntensityWarmup:
+		name = "Warmup"
+	case IntensityCooldown:
+		name = "Cooldown"
+	}
+	if name != "SteadyState" {
+		return zwoSegment{XMLName: xml.Name{Local: name}, Duration: s.seconds, PowerLow: s.power, PowerHigh: s.power, Cadence: s.cadence}
+	}
+	return zwoSegment{XMLName: xml.Name{Local: name}, Duration: s.seconds, Power: s.power, Cadence: s.cadence}
+}
+
+/*
+ExportERG - writes the plan as an .erg workout (minutes and absolute watts)
+
+Percent of FTP targets are resolved against the user's FTP (from powerZone, or the plan header when powerZone has
+none) so an FTP is required.  Intervals without a power target are held at FreeRidePower.
+*/
+func ExportERG(w io.Writer, file *File, powerZone *wahoo.PowerZone) (*ConversionReport, error) {
+	ftp := resolveFTP(file, powerZone)
+	if ftp <= 0 {
+		return nil, errNoFTP
+	}
+	return writeErgCourse(w, file, ftp, "WATTS", func(fraction float64) float64 {
+		return roundTo(fraction*float64(ftp), 0)
+	})
+}
+
+//ExportMRC - writes the plan as an .mrc workout (minutes and percent of FTP).  Watts targets need the FTP to convert
+func ExportMRC(w io.Writer, file *File, powerZone *wahoo.PowerZone) (*ConversionReport, error) {
+	ftp := resolveFTP(file, powerZone)
+	return writeErgCourse(w, file, ftp, "PERCENT", func(fraction float64) float64 {
+		return roundTo(fraction*100, 1)
+	})
+}
+
+//FreeRidePower - the fraction of FTP used by ERG and MRC for intervals that have no power target
+var FreeRidePower = 0.5
+
+func writeErgCourse(w io.Writer, file *File, ftp int, units string, value func(float64) float64) (*ConversionReport, error) {
+	if w == nil || file == nil {
+		return nil, errMissing
+	}
+	report := &ConversionReport{}
+	steps := flattenSteps(file, ftp, report)
+	if len(steps) == 0 {
+		return report, errNoIntervals
+	}
+
+	buffer := &bytes.Buffer{}
+	buffer.WriteString("[COURSE HEADER]\n")
+	buffer.WriteString("VERSION = 2\n")
+	buffer.WriteString("UNITS = ENGLISH\n")
+	if file.Header.Description != "" {
+		buffer.WriteString("DESCRIPTION = " + strings.Replace(file.Header.Description, "\n", " ", -1) + "\n")
+	}
+	buffer.WriteString("FILE NAME = " + file.Header.Name + "\n")
+	if ftp > 0 {
+		buffer.WriteString("FTP = " + strconv.Itoa(ftp) + "\n")
+	}
+	buffer.WriteString("MINUTES " + units + "\n")
+	buffer.WriteString("[END COURSE HEADER]\n")
+	buffer.WriteString("[COURSE DATA]\n")
+
+	minutes := 0.0
+	for _, s := range steps {
+		power := FreeRidePower
+		if s.power != nil {
+			power = *s.power
+		} else {
+			report.warn("%s: no power target, held at %g of FTP", s.path, FreeRidePower)
+		}
+		if s.cadence != nil {
+			report.warn("%s: cadence target dropped", s.path)
+		}
+		end := minutes + s.seconds/60
+		fmt.Fprintf(buffer, "%s\t%s\n", formatMinutes(minutes), formatCourseValue(value(power)))
+		fmt.Fprintf(buffer, "%s\t%s\n", formatMinutes(end), formatCourseValue(value(power)))
+		minutes = end
+	}
+	buffer.WriteString("[END COURSE DATA]\n")
+
+	_, err := w.Write(buffer.Bytes())
+	return report, err
+}
+
+func formatMinutes(minutes float64) string {
+	return strconv.FormatFloat(roundTo(minutes, 4), 'f', 2, 64)
+}
+
+func formatCourseValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,178 @@
+/*
+Package plan - a typed model of the Wahoo plan file (the JSON document uploaded with CreatePlan)
+
+A plan has a header with the athlete references (FTP, threshold heart rate etc.) that relative targets are based on,
+and a list of intervals.  Each interval ends on an exit trigger (time, distance, kJ or repeat) and has targets.
+Repeat intervals hold the intervals that are repeated.
+*/
+package plan
+
+import (
+	"encoding/json"
+	"errors"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+//Version - the plan file version written by this package
+const Version = "1.0.0"
+
+//TriggerType - what ends an interval
+type TriggerType string
+
+//Exit triggers.  Time is seconds, distance is meters and kj is kilojoules of work
+const (
+	TriggerTime     TriggerType = "time"
+	TriggerDistance TriggerType = "distance"
+	TriggerKJ       TriggerType = "kj"
+	TriggerRepeat   TriggerType = "repeat"
+)
+
+//IntensityType - what kind of effort an interval is
+type IntensityType string
+
+//Intensity types
+const (
+	IntensityActive   IntensityType = "active"
+	IntensityWarmup   IntensityType = "wu"
+	IntensityCooldown IntensityType = "cd"
+	IntensityRecover  IntensityType = "recover"
+	IntensityRest     IntensityType = "rest"
+	IntensityTempo    IntensityType = "tempo"
+	IntensityLT       IntensityType = "lt"
+	IntensityMAP      IntensityType = "map"
+	IntensityAC       IntensityType = "ac"
+	IntensityNM       IntensityType = "nm"
+	IntensityFTP      IntensityType = "ftp"
+)
+
+//TargetType - what a target is measured in
+type TargetType string
+
+/*
+Target types.  The relative ones (ftp, map, ac, nm, threshold_hr, max_hr and threshold_speed) are fractions of the
+matching header value, e.g. {"type":"ftp","low":0.9,"high":0.95} is 90-95% of FTP
+*/
+const (
+	TargetRPE            TargetType = "rpe"
+	TargetWatts          TargetType = "watts"
+	TargetHeartRate      TargetType = "hr"
+	TargetSpeed          TargetType = "speed"
+	TargetCadence        TargetType = "rpm"
+	TargetFTP            TargetType = "ftp"
+	TargetMAP            TargetType = "map"
+	TargetAC             TargetType = "ac"
+	TargetNM             TargetType = "nm"
+	TargetThresholdHR    TargetType = "threshold_hr"
+	TargetMaxHR          TargetType = "max_hr"
+	TargetThresholdSpeed TargetType = "threshold_speed"
+)
+
+//File - the plan document
+type File struct {
+	Header    Header      `json:"header"`
+	Intervals []*Interval `json:"intervals"`
+}
+
+//Header - the plan details and the athlete references relative targets use
+type Header struct {
+	Name                string   `json:"name"`
+	Version             string   `json:"version"`
+	Description         string   `json:"description,omitempty"`
+	WorkoutTypeFamily   *int     `json:"workout_type_family,omitempty"`
+	WorkoutTypeLocation *int     `json:"workout_type_location,omitempty"`
+	FTP                 *int     `json:"ftp,omitempty"`
+	MAP                 *int     `json:"map,omitempty"`
+	AC                  *int     `json:"ac,omitempty"`
+	NM                  *int     `json:"nm,omitempty"`
+	ThresholdHR         *int     `json:"threshold_hr,omitempty"`
+	MaxHR               *int     `json:"max_hr,omitempty"`
+	ThresholdSpeed      *float64 `json:"threshold_speed,omitempty"`
+}
+
+//Interval - a step of the plan, or a group of steps when the exit trigger is repeat
+type Interval struct {
+	Name             string        `json:"name,omitempty"`
+	ExitTriggerType  TriggerType   `json:"exit_trigger_type"`
+	ExitTriggerValue float64       `json:"exit_trigger_value"`
+	IntensityType    IntensityType `json:"intensity_type,omitempty"`
+	Targets          []Target      `json:"targets,omitempty"`
+	Intervals        []*Interval   `json:"intervals,omitempty"`
+}
+
+//Target - the range to hold during an interval.  Set Low and High to the same value for a single target
+type Target struct {
+	Type TargetType `json:"type"`
+	Low  *float64   `json:"low,omitempty"`
+	High *float64   `json:"high,omitempty"`
+}
+
+//Parse - reads a plan file
+func Parse(data []byte) (*File, error) {
+	file := &File{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+//Encode - validates the plan and returns the JSON document
+func (f *File) Encode() ([]byte, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if f.Header.Version == "" {
+		f.Header.Version = Version
+	}
+	return json.Marshal(f)
+}
+
+/*
+WahooPlan - a Plan ready for CreatePlan/UpdatePlan with the encoded plan file
+
+externalID and the provider updated at time should come from the system the plan was authored in so the plan can be
+synced without creating duplicates.  Leave them empty if there is nothing to sync with.
+*/
+func (f *File) WahooPlan(externalID string) (*wahoo.Plan, error) {
+	data, err := f.Encode()
+	if err != nil {
+		return nil, err
+	}
+	plan := &wahoo.Plan{PlanFile: data}
+	if f.Header.Name != "" {
+		name := f.Header.Name
+		plan.Name = &name
+	}
+	if f.Header.Description != "" {
+		description := f.Header.Description
+		plan.Description = &description
+	}
+	if externalID != "" {
+		plan.ExternalID = &externalID
+	}
+	return plan, nil
+}
+
+//Duration - the total time of the time based intervals in seconds (repeats included)
+func (f *File) Duration() float64 {
+	return intervalsDuration(f.Intervals)
+}
+
+func intervalsDuration(intervals []*Interval) float64 {
+	var total float64
+	for _, interval := range intervals {
+		if interval == nil {
+			continue
+		}
+		switch interval.ExitTriggerType {
+		case TriggerTime:
+			total += interval.ExitTriggerValue
+		case TriggerRepeat:
+			total += interval.ExitTriggerValue * intervalsDuration(interval.Intervals)
+		}
+	}
+	return total
+}
+
+//errNoIntervals - shared so Validate (and so Builder.Build) and the ERG/MRC export report the same thing
+var errNoIntervals = errors.New("plan: a plan needs at least one interval")
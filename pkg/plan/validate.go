@@ -0,0 +1,124 @@
+package plan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//ValidationError - everything wrong with a plan.  Each problem starts with the path of the interval it is on
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "plan: invalid plan file: " + strings.Join(e.Problems, "; ")
+}
+
+func (e *ValidationError) add(path, format string, args ...interface{}) {
+	e.Problems = append(e.Problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+/*
+Validate - checks the plan can be uploaded
+
+The header needs a name, every interval needs a valid positive exit trigger, repeats need intervals to repeat and
+only repeats can have them, targets need a known type with low <= high, and relative targets need the header value
+they are relative to.
+*/
+func (f *File) Validate() error {
+	problems := &ValidationError{}
+	if strings.TrimSpace(f.Header.Name) == "" {
+		problems.add("header", "name is required")
+	}
+	if len(f.Intervals) == 0 {
+		problems.Problems = append(problems.Problems, errNoIntervals.Error())
+	}
+	f.validateIntervals(problems, "intervals", f.Intervals)
+	if len(problems.Problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+func (f *File) validateIntervals(problems *ValidationError, path string, intervals []*Interval) {
+	for i, interval := range intervals {
+		intervalPath := path + "[" + strconv.Itoa(i) + "]"
+		if interval == nil {
+			problems.add(intervalPath, "interval is empty")
+			continue
+		}
+		switch interval.ExitTriggerType {
+		case TriggerTime, TriggerDistance, TriggerKJ:
+			if len(interval.Intervals) > 0 {
+				problems.add(intervalPath, "only repeat intervals can have intervals")
+			}
+		case TriggerRepeat:
+			if len(interval.Intervals) == 0 {
+				problems.add(intervalPath, "repeat has nothing to repeat")
+			}
+			if interval.ExitTriggerValue != float64(int(interval.ExitTriggerValue)) {
+				problems.add(intervalPath, "repeat count must be a whole number")
+			}
+		default:
+			problems.add(intervalPath, "unknown exit trigger type %q", interval.ExitTriggerType)
+		}
+		if interval.ExitTriggerValue <= 0 {
+			problems.add(intervalPath, "exit trigger value must be positive")
+		}
+		for j, target := range interval.Targets {
+			f.validateTarget(problems, intervalPath+".targets["+strconv.Itoa(j)+"]", target)
+		}
+		f.validateIntervals(problems, intervalPath+".intervals", interval.Intervals)
+	}
+}
+
+func (f *File) validateTarget(problems *ValidationError, path string, target Target) {
+	if target.Low == nil && target.High == nil {
+		problems.add(path, "low or high is required")
+		return
+	}
+	if target.Low != nil && target.High != nil && *target.Low > *target.High {
+		problems.add(path, "low is above high")
+	}
+	for _, value := range []*float64{target.Low, target.High} {
+		if value != nil && *value < 0 {
+			problems.add(path, "values can not be negative")
+		}
+	}
+
+	header := f.Header
+	var reference bool
+	switch target.Type {
+	case TargetRPE:
+		for _, value := range []*float64{target.Low, target.High} {
+			if value != nil && (*value < 1 || *value > 10) {
+				problems.add(path, "rpe must be between 1 and 10")
+				break
+			}
+		}
+		return
+	case TargetWatts, TargetHeartRate, TargetSpeed, TargetCadence:
+		return
+	case TargetFTP:
+		reference = header.FTP != nil
+	case TargetMAP:
+		reference = header.MAP != nil
+	case TargetAC:
+		reference = header.AC != nil
+	case TargetNM:
+		reference = header.NM != nil
+	case TargetThresholdHR:
+		reference = header.ThresholdHR != nil
+	case TargetMaxHR:
+		reference = header.MaxHR != nil
+	case TargetThresholdSpeed:
+		reference = header.ThresholdSpeed != nil
+	default:
+		problems.add(path, "unknown target type %q", target.Type)
+		return
+	}
+	if !reference {
+		problems.add(path, "%s targets need header.%s", target.Type, target.Type)
+	}
+}
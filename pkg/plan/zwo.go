@@ -0,0 +1,182 @@
+package plan
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+//zwoFile - the Zwift workout file
+type zwoFile struct {
+	XMLName     xml.Name   `xml:"workout_file"`
+	Author      string     `xml:"author,omitempty"`
+	Name        string     `xml:"name"`
+	Description string     `xml:"description,omitempty"`
+	SportType   string     `xml:"sportType"`
+	Workout     zwoWorkout `xml:"workout"`
+}
+
+type zwoWorkout struct {
+	Segments []zwoSegment `xml:",any"`
+}
+
+//zwoSegment - every segment type shares the same attributes so one struct covers them all
+type zwoSegment struct {
+	XMLName        xml.Name
+	Duration       float64  `xml:"Duration,attr,omitempty"`
+	Power          *float64 `xml:"Power,attr,omitempty"`
+	PowerLow       *float64 `xml:"PowerLow,attr,omitempty"`
+	PowerHigh      *float64 `xml:"PowerHigh,attr,omitempty"`
+	Cadence        *float64 `xml:"Cadence,attr,omitempty"`
+	CadenceResting *float64 `xml:"CadenceResting,attr,omitempty"`
+	Repeat         int      `xml:"Repeat,attr,omitempty"`
+	OnDuration     float64  `xml:"OnDuration,attr,omitempty"`
+	OffDuration    float64  `xml:"OffDuration,attr,omitempty"`
+	OnPower        *float64 `xml:"OnPower,attr,omitempty"`
+	OffPower       *float64 `xml:"OffPower,attr,omitempty"`
+	FlatRoad       *int     `xml:"FlatRoad,attr,omitempty"`
+	TextEvents     []byte   `xml:",innerxml"`
+}
+
+/*
+ImportZWO - converts a Zwift .zwo workout into a plan
+
+ZWO power is a fraction of FTP so the plan uses ftp targets with the given FTP in the header.  Warmup, Cooldown and
+Ramp segments are split into steps (see RampStepSeconds), IntervalsT becomes a repeat, FreeRide becomes an interval
+without targets and MaxEffort becomes an RPE 10 interval.  Text events and anything unknown are listed in the report.
+*/
+func ImportZWO(r io.Reader, ftp int) (*File, *ConversionReport, error) {
+	if ftp <= 0 {
+		return nil, nil, errNoFTP
+	}
+	source := &zwoFile{}
+	if err := xml.NewDecoder(r).Decode(source); err != nil {
+		return nil, nil, err
+	}
+	if len(source.Workout.Segments) == 0 {
+		return nil, nil, errEmptyZWO
+	}
+	report := &ConversionReport{}
+
+	name := strings.TrimSpace(source.Name)
+	if name == "" {
+		name = "Imported Workout"
+	}
+	builder := NewBuilder(name).Description(strings.TrimSpace(source.Description)).FTP(ftp)
+	if sport := strings.TrimSpace(source.SportType); sport != "" && sport != "bike" {
+		report.warn("sportType %q is not supported, power is treated as a fraction of FTP", sport)
+	}
+
+	for i, segment := range source.Workout.Segments {
+		label := segment.XMLName.Local
+		if strings.Contains(string(segment.TextEvents), "textevent") {
+			report.warn("segment %d (%s): text events were dropped", i, label)
+		}
+		switch label {
+		case "SteadyState", "SolidState":
+			power, ok := zwoPower(segment.Power, segment.PowerLow, segment.PowerHigh)
+			if !ok || segment.Duration <= 0 {
+				report.warn("segment %d (%s): missing duration or power, skipped", i, label)
+				continue
+			}
+			builder.Interval(zwoSteady("Steady", segment.Duration, power, segment.Cadence))
+		case "Warmup", "Cooldown", "Ramp":
+			if segment.PowerLow == nil || segment.PowerHigh == nil || segment.Duration <= 0 {
+				report.warn("segment %d (%s): missing duration or power, skipped", i, label)
+				continue
+			}
+			intensity := IntensityActive
+			stepName := "Ramp"
+			switch label {
+			case "Warmup":
+				intensity, stepName = IntensityWarmup, "Warm Up"
+			case "Cooldown":
+				intensity, stepName = IntensityCooldown, "Cool Down"
+			}
+			if *segment.PowerLow == *segment.PowerHigh {
+				interval := zwoSteady(stepName, segment.Duration, *segment.PowerLow, segment.Cadence)
+				interval.IntensityType = intensity
+				builder.Interval(interval)
+				continue
+			}
+			steps := rampSteps(stepName, intensity, TargetFTP, segment.Duration, *segment.PowerLow, *segment.PowerHigh)
+			report.warn("segment %d (%s): ramp from %g to %g split into %d steps", i, label, *segment.PowerLow, *segment.PowerHigh, len(steps))
+			for _, step := range steps {
+				if segment.Cadence != nil {
+					step.Targets = append(step.Targets, newTarget(TargetCadence, *segment.Cadence, *segment.Cadence))
+				}
+				builder.Interval(step)
+			}
+		case "IntervalsT":
+			if segment.Repeat <= 0 || segment.OnDuration <= 0 || segment.OnPower == nil {
+				report.warn("segment %d (%s): missing repeat, on duration or on power, skipped", i, label)
+				continue
+			}
+			segment := segment
+			builder.Repeat(segment.Repeat, func(repeat *Builder) {
+				repeat.Interval(zwoSteady("On", segment.OnDuration, *segment.OnPower, segment.Cadence))
+				if segment.OffDuration > 0 && segment.OffPower != nil {
+					off := zwoSteady("Off", segment.OffDuration, *segment.OffPower, segment.CadenceResting)
+					off.IntensityType = IntensityRecover
+					repeat.Interval(off)
+				}
+			})
+		case "FreeRide":
+			if segment.Duration <= 0 {
+				report.warn("segment %d (%s): missing duration, skipped", i, label)
+				continue
+			}
+			if segment.FlatRoad != nil {
+				report.warn("segment %d (%s): FlatRoad has no equivalent and was dropped", i, label)
+			}
+			builder.Timed("Free Ride", IntensityActive, secondsToDuration(segment.Duration))
+		case "MaxEffort":
+			if segment.Duration <= 0 {
+				report.warn("segment %d (%s): missing duration, skipped", i, label)
+				continue
+			}
+			builder.Timed("Max Effort", IntensityNM, secondsToDuration(segment.Duration), RPE(10))
+		default:
+			report.warn("segment %d (%s): unknown segment type, skipped", i, label)
+		}
+	}
+
+	file, err := builder.Build()
+	if err != nil {
+		return nil, report, err
+	}
+	return file, report, nil
+}
+
+//zwoPower - SteadyState normally uses Power but some editors write PowerLow/PowerHigh
+func zwoPower(power, low, high *float64) (float64, bool) {
+	switch {
+	case power != nil:
+		return *power, true
+	case low != nil && high != nil:
+		return (*low + *high) / 2, true
+	case low != nil:
+		return *low, true
+	case high != nil:
+		return *high, true
+	}
+	return 0, false
+}
+
+func zwoSteady(name string, seconds, power float64, cadence *float64) *Interval {
+	interval := &Interval{
+		Name:             name,
+		ExitTriggerType:  TriggerTime,
+		ExitTriggerValue: seconds,
+		IntensityType:    intensityForPower(power),
+		Targets:          []Target{newTarget(TargetFTP, power, power)},
+	}
+	if cadence != nil {
+		interval.Targets = append(interval.Targets, newTarget(TargetCadence, *cadence, *cadence))
+	}
+	return interval
+}
+
+//errEmptyZWO - returned when a zwo has no segments
+var errEmptyZWO = errors.New("plan: the zwo workout has no segments")
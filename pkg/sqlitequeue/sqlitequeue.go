@@ -0,0 +1,273 @@
+/*
+Package sqlitequeue - a wahoo.WebhookQueue kept in a SQLite database
+
+The queue takes a *sql.DB so the caller picks the driver (e.g. github.com/mattn/go-sqlite3 or modernc.org/sqlite) and
+the client itself keeps to the standard library.  Open creates three tables (prefixed wahoo_webhook_): pending and
+failed webhooks keyed by their ID and completed IDs with when they completed for the dedup window.  Complete and Fail
+move a webhook in one transaction so a crash never leaves it both pending and completed.  Which webhooks are being
+processed is only kept in memory, so they are pending again when the queue is opened after a restart and only one
+process should use the tables.
+*/
+package sqlitequeue
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+//schema - times are unix nanoseconds so they sort and compare as numbers
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS wahoo_webhook_pending (
+		id TEXT PRIMARY KEY,
+		payload BLOB,
+		received_at INTEGER NOT NULL,
+		attempts INTEGER NOT NULL,
+		next_attempt INTEGER NOT NULL,
+		last_error TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS wahoo_webhook_pending_due ON wahoo_webhook_pending (next_attempt, received_at)`,
+	`CREATE TABLE IF NOT EXISTS wahoo_webhook_completed (
+		id TEXT PRIMARY KEY,
+		completed_at INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS wahoo_webhook_failed (
+		id TEXT PRIMARY KEY,
+		payload BLOB,
+		received_at INTEGER NOT NULL,
+		attempts INTEGER NOT NULL,
+		next_attempt INTEGER NOT NULL,
+		last_error TEXT NOT NULL
+	)`,
+}
+
+//errNotDequeued - Retry, Complete and Fail are only for webhooks that were dequeued
+var errNotDequeued = errors.New("webhook was not dequeued")
+
+//Queue - see the package doc
+type Queue struct {
+	mutex       sync.Mutex
+	db          *sql.DB
+	dedupWindow time.Duration
+	lastPrune   time.Time
+	inFlight    map[string]bool
+}
+
+//Open - creates the tables if they are missing.  A dedupWindow of 0 uses wahoo.DefaultWebhookDedupWindow
+func Open(db *sql.DB, dedupWindow time.Duration) (*Queue, error) {
+	if db == nil {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	if dedupWindow <= 0 {
+		dedupWindow = wahoo.DefaultWebhookDedupWindow
+	}
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			return nil, err
+		}
+	}
+	return &Queue{db: db, dedupWindow: dedupWindow, inFlight: map[string]bool{}}, nil
+}
+
+//webhookColumns - the columns of the pending and failed tables in the order scanWebhook reads them
+const webhookColumns = "id, payload, received_at, attempts, next_attempt, last_error"
+
+//unixNano - the zero time is stored as 0 because UnixNano is undefined for it
+func unixNano(value time.Time) int64 {
+	if value.IsZero() {
+		return 0
+	}
+	return value.UnixNano()
+}
+
+//fromUnixNano - see unixNano
+func fromUnixNano(value int64) time.Time {
+	if value == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, value)
+}
+
+//scanner - a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+//scanWebhook - reads the webhookColumns
+func scanWebhook(row scanner) (*wahoo.QueuedWebhook, error) {
+	item := &wahoo.QueuedWebhook{}
+	var receivedAt, nextAttempt int64
+	if err := row.Scan(&item.ID, &item.Payload, &receivedAt, &item.Attempts, &nextAttempt, &item.LastError); err != nil {
+		return nil, err
+	}
+	item.ReceivedAt = fromUnixNano(receivedAt)
+	item.NextAttempt = fromUnixNano(nextAttempt)
+	return item, nil
+}
+
+//putWebhook - inserts the webhook into the table, replacing the row with the same ID
+func putWebhook(tx *sql.Tx, table string, item *wahoo.QueuedWebhook) error {
+	_, err := tx.Exec("INSERT OR REPLACE INTO "+table+" ("+webhookColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+		item.ID, item.Payload, unixNano(item.ReceivedAt), item.Attempts, unixNano(item.NextAttempt), item.LastError)
+	return err
+}
+
+//inTransaction - runs update in a transaction, committing it when update succeeds
+func (v *Queue) inTransaction(update func(tx *sql.Tx) error) error {
+	tx, err := v.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := update(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+//Enqueue - see wahoo.WebhookQueue.  The webhook is in the database before this returns
+func (v *Queue) Enqueue(item *wahoo.QueuedWebhook) (bool, error) {
+	if item == nil || item.ID == "" {
+		return false, errors.New("Missing Mandatory Value")
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	added := false
+	err := v.inTransaction(func(tx *sql.Tx) error {
+		now := time.Now()
+		//Remove the completed IDs older than the dedup window, at most once a minute
+		if now.Sub(v.lastPrune) >= time.Minute {
+			if _, err := tx.Exec("DELETE FROM wahoo_webhook_completed WHERE completed_at < ?", now.Add(-v.dedupWindow).UnixNano()); err != nil {
+				return err
+			}
+			v.lastPrune = now
+		}
+		var exists int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM wahoo_webhook_pending WHERE id = ?`, item.ID).Scan(&exists)
+		if err != nil || exists > 0 {
+			return err
+		}
+		err = tx.QueryRow(`SELECT COUNT(*) FROM wahoo_webhook_completed WHERE id = ? AND completed_at >= ?`,
+			item.ID, now.Add(-v.dedupWindow).UnixNano()).Scan(&exists)
+		if err != nil || exists > 0 {
+			return err
+		}
+		added = true
+		return putWebhook(tx, "wahoo_webhook_pending", item)
+	})
+	if err != nil {
+		return false, err
+	}
+	return added, nil
+}
+
+//Dequeue - see wahoo.WebhookQueue
+func (v *Queue) Dequeue(now time.Time) (*wahoo.QueuedWebhook, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	rows, err := v.db.Query("SELECT "+webhookColumns+" FROM wahoo_webhook_pending WHERE next_attempt <= ? ORDER BY next_attempt, received_at", now.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		if v.inFlight[item.ID] {
+			continue
+		}
+		v.inFlight[item.ID] = true
+		return item, nil
+	}
+	return nil, rows.Err()
+}
+
+//Retry - see wahoo.WebhookQueue.  The attempts and next attempt are saved so they carry over a restart
+func (v *Queue) Retry(item *wahoo.QueuedWebhook) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if item == nil || !v.inFlight[item.ID] {
+		return errNotDequeued
+	}
+	err := v.inTransaction(func(tx *sql.Tx) error {
+		return putWebhook(tx, "wahoo_webhook_pending", item)
+	})
+	if err != nil {
+		return err
+	}
+	delete(v.inFlight, item.ID)
+	return nil
+}
+
+//Complete - see wahoo.WebhookQueue
+func (v *Queue) Complete(item *wahoo.QueuedWebhook) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if item == nil || !v.inFlight[item.ID] {
+		return errNotDequeued
+	}
+	err := v.inTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM wahoo_webhook_pending WHERE id = ?", item.ID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("INSERT OR REPLACE INTO wahoo_webhook_completed (id, completed_at) VALUES (?, ?)", item.ID, time.Now().UnixNano())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	delete(v.inFlight, item.ID)
+	return nil
+}
+
+//Fail - see wahoo.WebhookQueue.  The webhook is kept and returned by Failed
+func (v *Queue) Fail(item *wahoo.QueuedWebhook) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if item == nil || !v.inFlight[item.ID] {
+		return errNotDequeued
+	}
+	err := v.inTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM wahoo_webhook_pending WHERE id = ?", item.ID); err != nil {
+			return err
+		}
+		return putWebhook(tx, "wahoo_webhook_failed", item)
+	})
+	if err != nil {
+		return err
+	}
+	delete(v.inFlight, item.ID)
+	return nil
+}
+
+//Len - the number of webhooks waiting, including the ones being processed
+func (v *Queue) Len() (int, error) {
+	count := 0
+	err := v.db.QueryRow("SELECT COUNT(*) FROM wahoo_webhook_pending").Scan(&count)
+	return count, err
+}
+
+//Failed - the webhooks that ran out of attempts, oldest first
+func (v *Queue) Failed() ([]*wahoo.QueuedWebhook, error) {
+	rows, err := v.db.Query("SELECT " + webhookColumns + " FROM wahoo_webhook_failed ORDER BY received_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	failed := []*wahoo.QueuedWebhook{}
+	for rows.Next() {
+		item, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		failed = append(failed, item)
+	}
+	return failed, rows.Err()
+}
@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"io"
 	"io/ioutil"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
 )
 
 //Client - the client object that makes the calls
@@ -16,12 +21,21 @@ type Client struct {
 	clientSecret string
 	redirectURI  string
 	clientID     string
+	httpClient   *http.Client
+	maxRetries   int
+	retryWait    time.Duration
+	maxFileSize  int64
 }
 
+//defaults used by ConstructClient
+const (
+	defaultMaxRetries  = 3
+	defaultRetryWait   = time.Second
+	defaultMaxFileSize = 64 << 20
+)
+
 /*
 ConstructClient -
-
-
 */
 func ConstructClient(wahooClientSecret, wahooClientID, redirectURI string, useProduction bool) (*Client, error) {
 
@@ -29,6 +43,10 @@ func ConstructClient(wahooClientSecret, wahooClientID, redirectURI string, usePr
 		clientSecret: wahooClientSecret,
 		redirectURI:  redirectURI,
 		clientID:     wahooClientID,
+		httpClient:   &http.Client{},
+		maxRetries:   defaultMaxRetries,
+		retryWait:    defaultRetryWait,
+		maxFileSize:  defaultMaxFileSize,
 	}
 
 	//Toggle off of production or sandbox
@@ -41,6 +59,42 @@ func ConstructClient(wahooClientSecret, wahooClientID, redirectURI string, usePr
 	return clientToReturn, nil
 }
 
+//SetBaseURL - points the client at a different host (e.g. a proxy or a local test server).  Calls are always made over https
+func (v *Client) SetBaseURL(baseURL string) {
+	v.baseURL = baseURL
+}
+
+//SetHTTPClient - overrides the http client (and therefore the transport) used for every call
+func (v *Client) SetHTTPClient(httpClient *http.Client) {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	v.httpClient = httpClient
+}
+
+/*
+SetRetryPolicy - sets how many times a failed file download is retried and how long to wait between attempts
+
+The policy only applies to DownloadWorkoutFile and ResumeWorkoutFileDownload (and DownloadPlanFile which shares
+them), every other call is made once.  Only network errors, 429 and 5xx responses are retried.  A maxRetries of 0
+disables retrying.
+*/
+func (v *Client) SetRetryPolicy(maxRetries int, wait time.Duration) {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	v.maxRetries = maxRetries
+	v.retryWait = wait
+}
+
+//SetMaxFileSize - sets the largest file (in bytes) that will be downloaded.  Anything <= 0 resets to the default
+func (v *Client) SetMaxFileSize(maxBytes int64) {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxFileSize
+	}
+	v.maxFileSize = maxBytes
+}
+
 //AUTHORIZATION ENDPOINTS
 
 //GetOauthToken - Function that will get an Oauth Token from the code provided
@@ -49,7 +103,7 @@ func (v *Client) GetOauthToken(code string) (*Token, error) {
 	url := "https://" + v.baseURL + "/oauth/token?client_secret=" + v.clientSecret + "&code=" + code + "&redirect_uri=" + v.redirectURI + "&grant_type=authorization_code&client_id=" + v.clientID
 	method := "POST"
 
-	client := &http.Client{}
+	client := v.httpClient
 	req, err := http.NewRequest(method, url, nil)
 
 	if err != nil {
@@ -86,7 +140,7 @@ func (v *Client) RefreshToken(refreshToken string) (*Token, error) {
 	url := "https://" + v.baseURL + "/oauth/token?client_secret=" + v.clientSecret + "&client_id=" + v.clientID + "&grant_type=refresh_token&refresh_token=" + refreshToken
 	method := "POST"
 
-	client := &http.Client{}
+	client := v.httpClient
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, err
@@ -122,7 +176,7 @@ func (v *Client) DeauthorizeUser(accessToken string) error {
 	url := "https://" + v.baseURL + "/v1/permissions"
 	method := "DELETE"
 
-	client := &http.Client{}
+	client := v.httpClient
 	req, err := http.NewRequest(method, url, nil)
 
 	if err != nil {
@@ -155,7 +209,7 @@ func (v *Client) GetUserData(accessToken string) (*User, error) {
 	url := "https://" + v.baseURL + "/v1/user"
 	method := "GET"
 
-	client := &http.Client{}
+	client := v.httpClient
 	req, err := http.NewRequest(method, url, nil)
 
 	if err != nil {
@@ -185,23 +239,32 @@ func (v *Client) GetUserData(accessToken string) (*User, error) {
 	return user, nil
 }
 
-//UpdateUserData - sets the user data
+//UpdateUserData - sets the user data.  Only the fields that are not nil are sent, use UpdateUserFields to clear one
 func (v *Client) UpdateUserData(accessToken string, newUserData *User) error {
 
 	if accessToken == "" || newUserData == nil {
 		return errors.New("Missing Mandatory Value")
 	}
+	return v.UpdateUserFields(accessToken, newUserData.Update())
+}
+
+//UpdateUserFields - sets or clears the user fields in the update
+func (v *Client) UpdateUserFields(accessToken string, update *UserUpdate) error {
+
+	if accessToken == "" || update == nil {
+		return errors.New("Missing Mandatory Value")
+	}
 
 	url := "https://" + v.baseURL + "/v1/user"
 	method := "PUT"
 
-	client := &http.Client{}
+	client := v.httpClient
 
 	payload := &bytes.Buffer{}
 
 	writer := multipart.NewWriter(payload)
 
-	newUserData.convertUserToFormField(writer)
+	writeFormFields(writer, update.formFields())
 
 	err := writer.Close()
 	if err != nil {
@@ -240,7 +303,7 @@ func (v *Client) GetAllWorkouts(accessToken string, pageNumber, resultsPerPage i
 	url := "https://" + v.baseURL + "/v1/workouts?"
 	method := "GET"
 
-	client := &http.Client{}
+	client := v.httpClient
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, err
@@ -288,7 +351,7 @@ func (v *Client) GetWorkoutSummary(accessToken string, workoutID int) (*WorkoutS
 	url := "https://" + v.baseURL + "/v1/workouts/" + strconv.Itoa(workoutID) + "/workout_summary"
 	method := "GET"
 
-	client := &http.Client{}
+	client := v.httpClient
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, err
@@ -328,7 +391,7 @@ func (v *Client) GetSpecificWorkout(accessToken string, workoutID int) (*Workout
 	url := "https://" + v.baseURL + "/v1/workouts/" + strconv.Itoa(workoutID)
 	method := "GET"
 
-	client := &http.Client{}
+	client := v.httpClient
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, err
@@ -367,7 +430,7 @@ func (v *Client) DeleteSpecificWorkout(accessToken string, workoutID int) error
 	url := "https://" + v.baseURL + "/v1/workouts/" + strconv.Itoa(workoutID)
 	method := "DELETE"
 
-	client := &http.Client{}
+	client := v.httpClient
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return err
@@ -388,21 +451,33 @@ func (v *Client) DeleteSpecificWorkout(accessToken string, workoutID int) error
 	return nil
 }
 
-//UpdateSpecificWorkout - Method to update data on a workout
+/*
+UpdateSpecificWorkout - Method to update data on a workout
+
+Only the fields that are not nil are sent, use UpdateWorkoutFields to clear one.
+*/
 func (v *Client) UpdateSpecificWorkout(accessToken string, workout *Workout) error {
 	//Check that obth workoutID and workout is set
 	if workout == nil || workout.ID == 0 {
 		return errors.New("Missing Mandatory Value")
 	}
-	url := "https://" + v.baseURL + "/v1/workouts/" + strconv.Itoa(workout.ID)
+	return v.UpdateWorkoutFields(accessToken, workout.Update())
+}
+
+//UpdateWorkoutFields - sets or clears the fields in the update on the workout update.ID
+func (v *Client) UpdateWorkoutFields(accessToken string, update *WorkoutUpdate) error {
+	if update == nil || update.ID == 0 {
+		return errors.New("Missing Mandatory Value")
+	}
+	url := "https://" + v.baseURL + "/v1/workouts/" + strconv.Itoa(update.ID)
 	method := "PUT"
-	client := &http.Client{}
+	client := v.httpClient
 
 	payload := &bytes.Buffer{}
 
 	writer := multipart.NewWriter(payload)
 
-	workout.convertWorkoutToFormFields(writer)
+	writeFormFields(writer, update.formFields())
 
 	err := writer.Close()
 	if err != nil {
@@ -431,6 +506,597 @@ func (v *Client) UpdateSpecificWorkout(accessToken string, workout *Workout) err
 	return nil
 }
 
+/*
+WorkoutFileError - the workout was created but its file could not be attached
+
+CreateWorkoutFromFile deletes the workout when the upload fails.  Deleted is false when that failed too (DeleteErr
+says why) and Workout is then left on the server without a file for the caller to clean up.
+*/
+type WorkoutFileError struct {
+	Workout   *Workout
+	Err       error
+	Deleted   bool
+	DeleteErr error
+}
+
+func (e *WorkoutFileError) Error() string {
+	if e.Deleted {
+		return "attaching the workout file failed, the workout was deleted: " + e.Err.Error()
+	}
+	return "attaching the workout file failed and the workout could not be deleted: " + e.Err.Error()
+}
+
+func (e *WorkoutFileError) Unwrap() error {
+	return e.Err
+}
+
+/*
+CreateWorkoutFromFile - creates a workout and attaches the recorded FIT file as its summary
+
+The workout must have WorkoutTypeID and WorkoutToken set (the token is what Wahoo uses to stop duplicates).  If
+Starts or Minutes are not set they are taken from the session in the FIT file, which then has to be a FIT file this
+client can decode.  When both are set the file is uploaded as it is.  The returned workout has the WorkoutSummary that
+was created from the file.  If the file can not be attached the error is a *WorkoutFileError.
+*/
+func (v *Client) CreateWorkoutFromFile(accessToken string, workout *Workout, fitFile io.Reader) (*Workout, error) {
+	if accessToken == "" || workout == nil || workout.WorkoutTypeID == nil || workout.WorkoutToken == nil || fitFile == nil {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+
+	fileData, err := ioutil.ReadAll(io.LimitReader(fitFile, v.maxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(fileData)) > v.maxFileSize {
+		return nil, ErrFileTooLarge
+	}
+	workoutToSend := *workout
+	workoutToSend.WorkoutSummary = nil
+	if workoutToSend.Starts == nil || workoutToSend.Minutes == nil {
+		decoded, err := fit.DecodeBytes(fileData)
+		if err != nil {
+			return nil, err
+		}
+		fillWorkoutFromFitFile(&workoutToSend, decoded)
+	}
+
+	url := "https://" + v.baseURL + "/v1/workouts"
+	method := "POST"
+
+	client := v.httpClient
+
+	payload := &bytes.Buffer{}
+
+	writer := multipart.NewWriter(payload)
+
+	writeFormFields(writer, workoutToSend.Update().formFields())
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, url, payload)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+
+	//Do the http request
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	//Handle anything above 299
+	if res.StatusCode >= 300 {
+		return nil, constructWahooErrorFromResponse(res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	createdWorkout, err := convertJSONResponseToWorkout(body)
+	if err != nil {
+		return nil, err
+	}
+
+	//Now attach the file
+	summary, err := v.uploadWorkoutSummaryFile(accessToken, createdWorkout.ID, fileData)
+	if err != nil {
+		//Do not leave a workout without its file behind
+		fileErr := &WorkoutFileError{Workout: createdWorkout, Err: err}
+		fileErr.DeleteErr = v.DeleteSpecificWorkout(accessToken, createdWorkout.ID)
+		fileErr.Deleted = fileErr.DeleteErr == nil
+		return nil, fileErr
+	}
+	createdWorkout.WorkoutSummary = summary
+	return createdWorkout, nil
+}
+
+//uploadWorkoutSummaryFile - creates the workout summary for a workout from the FIT file
+func (v *Client) uploadWorkoutSummaryFile(accessToken string, workoutID int, fileData []byte) (*WorkoutSummary, error) {
+	if workoutID == 0 {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	url := "https://" + v.baseURL + "/v1/workouts/" + strconv.Itoa(workoutID) + "/workout_summary"
+	method := "POST"
+
+	client := v.httpClient
+
+	payload := &bytes.Buffer{}
+
+	writer := multipart.NewWriter(payload)
+
+	part, err := writer.CreateFormFile("workout_summary[file]", strconv.Itoa(workoutID)+".fit")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = part.Write(fileData); err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, url, payload)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+
+	//Do the http request
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	//Handle anything above 299
+	if res.StatusCode >= 300 {
+		return nil, constructWahooErrorFromResponse(res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return convertJSONResponseToWorkoutSummary(body)
+}
+
+//fillWorkoutFromFitFile - sets Starts and Minutes from the FIT session when the caller left them empty
+func fillWorkoutFromFitFile(workout *Workout, file *fit.File) {
+	if len(file.Sessions) == 0 {
+		return
+	}
+	session := file.Sessions[0]
+	if workout.Starts == nil && session.StartTime != nil {
+		starts := *session.StartTime
+		workout.Starts = &starts
+	}
+	if workout.Minutes == nil && session.TotalElapsedTime != nil {
+		minutes := int(math.Ceil(*session.TotalElapsedTime / 60))
+		workout.Minutes = &minutes
+	}
+}
+
+//PLAN ENDPOINTS
+
+//ListPlans - Method to get all the plans for a user.  If externalID is set only the plan with that external ID is returned
+func (v *Client) ListPlans(accessToken string, externalID string) ([]*Plan, error) {
+	if accessToken == "" {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+
+	url := "https://" + v.baseURL + "/v1/plans"
+	method := "GET"
+
+	client := v.httpClient
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+	//Add the query params
+	if externalID != "" {
+		q := req.URL.Query()
+		q.Add("external_id", externalID)
+		req.URL.RawQuery = q.Encode()
+	}
+
+	//Do the http request
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	//Handle anything above 299
+	if res.StatusCode >= 300 {
+		return nil, constructWahooErrorFromResponse(res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	//Convert the body to the slice to return
+	planSlice, err := convertJSONResponseToPlanArray(body)
+	if err != nil {
+		return nil, err
+	}
+	return planSlice, nil
+}
+
+//GetPlan - Method to get a specific plan
+func (v *Client) GetPlan(accessToken string, planID int) (*Plan, error) {
+	if accessToken == "" || planID == 0 {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+
+	url := "https://" + v.baseURL + "/v1/plans/" + strconv.Itoa(planID)
+	method := "GET"
+
+	client := v.httpClient
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+
+	//Do the http request
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	//Handle anything above 299
+	if res.StatusCode >= 300 {
+		return nil, constructWahooErrorFromResponse(res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := convertJSONResponseToPlan(body)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+/*
+CreatePlan - Method to create a plan from its plan file
+
+Set ExternalID and ProviderUpdatedAt so the plan can be found again with ListPlans and only updated when the
+source has changed.
+*/
+func (v *Client) CreatePlan(accessToken string, plan *Plan) (*Plan, error) {
+	if accessToken == "" || plan == nil || len(plan.PlanFile) == 0 {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	url := "https://" + v.baseURL + "/v1/plans"
+	return v.sendPlan(accessToken, "POST", url, plan)
+}
+
+/*
+UpdatePlan - Method to update a plan.  Only the values that are set are sent
+
+The plan file, name, description, ExternalID and ProviderUpdatedAt can be changed.  The workout type family and
+location come from the plan file.
+*/
+func (v *Client) UpdatePlan(accessToken string, plan *Plan) (*Plan, error) {
+	if accessToken == "" || plan == nil || plan.ID == 0 {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	url := "https://" + v.baseURL + "/v1/plans/" + strconv.Itoa(plan.ID)
+	return v.sendPlan(accessToken, "PUT", url, plan)
+}
+
+//sendPlan - does the multipart request shared by create and update
+func (v *Client) sendPlan(accessToken, method, url string, plan *Plan) (*Plan, error) {
+	client := v.httpClient
+
+	payload := &bytes.Buffer{}
+
+	writer := multipart.NewWriter(payload)
+
+	plan.convertPlanToFormFields(writer)
+
+	err := writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, url, payload)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+
+	//Do the http request
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	//Handle anything above 299
+	if res.StatusCode >= 300 {
+		return nil, constructWahooErrorFromResponse(res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return convertJSONResponseToPlan(body)
+}
+
+//DeletePlan - Method to delete a specific plan
+func (v *Client) DeletePlan(accessToken string, planID int) error {
+	if accessToken == "" || planID == 0 {
+		return errors.New("Missing Mandatory Value")
+	}
+	url := "https://" + v.baseURL + "/v1/plans/" + strconv.Itoa(planID)
+	method := "DELETE"
+
+	client := v.httpClient
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+
+	//Do the http request
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	//Handle anything above 299
+	if res.StatusCode >= 300 {
+		return constructWahooErrorFromResponse(res.StatusCode)
+	}
+	return nil
+}
+
+//ROUTE ENDPOINTS
+
+//ListRoutes - Method to get all the routes for a user.  If externalID is set only the route with that external ID is returned
+func (v *Client) ListRoutes(accessToken string, externalID string) ([]*Route, error) {
+	if accessToken == "" {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+
+	url := "https://" + v.baseURL + "/v1/routes"
+	method := "GET"
+
+	client := v.httpClient
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+	//Add the query params
+	if externalID != "" {
+		q := req.URL.Query()
+		q.Add("external_id", externalID)
+		req.URL.RawQuery = q.Encode()
+	}
+
+	//Do the http request
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	//Handle anything above 299
+	if res.StatusCode >= 300 {
+		return nil, constructWahooErrorFromResponse(res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	//Convert the body to the slice to return
+	routeSlice, err := convertJSONResponseToRouteArray(body)
+	if err != nil {
+		return nil, err
+	}
+	return routeSlice, nil
+}
+
+//GetRoute - Method to get a specific route
+func (v *Client) GetRoute(accessToken string, routeID int) (*Route, error) {
+	if accessToken == "" || routeID == 0 {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+
+	url := "https://" + v.baseURL + "/v1/routes/" + strconv.Itoa(routeID)
+	method := "GET"
+
+	client := v.httpClient
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+
+	//Do the http request
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	//Handle anything above 299
+	if res.StatusCode >= 300 {
+		return nil, constructWahooErrorFromResponse(res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	route, err := convertJSONResponseToRoute(body)
+	if err != nil {
+		return nil, err
+	}
+	return route, nil
+}
+
+/*
+CreateRoute - Method to create a route from a GPX or FIT route file
+
+The file type is detected from the contents so FileName only needs to be set to keep the original name.  Any of
+Name, StartLat, StartLng, Distance, Ascent and Descent that are not set are filled in from the route file (see
+RouteAnalyzer).  The route passed in is not changed.
+*/
+func (v *Client) CreateRoute(accessToken string, route *Route) (*Route, error) {
+	if accessToken == "" || route == nil || len(route.RouteFile) == 0 {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	routeToSend, err := routeWithFileMetadata(route, false)
+	if err != nil {
+		return nil, err
+	}
+	if routeToSend.Name == nil {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	url := "https://" + v.baseURL + "/v1/routes"
+	return v.sendRoute(accessToken, "POST", url, routeToSend)
+}
+
+/*
+UpdateRoute - Method to update a route.  Only the values that are set are sent
+
+A new route file fills in the metadata like CreateRoute.  The start, distance, ascent and descent of a route read
+with GetRoute or ListRoutes are replaced with the ones from the new file unless they were changed.
+*/
+func (v *Client) UpdateRoute(accessToken string, route *Route) (*Route, error) {
+	if accessToken == "" || route == nil || route.ID == 0 {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	routeToSend, err := routeWithFileMetadata(route, true)
+	if err != nil {
+		return nil, err
+	}
+	url := "https://" + v.baseURL + "/v1/routes/" + strconv.Itoa(route.ID)
+	return v.sendRoute(accessToken, "PUT", url, routeToSend)
+}
+
+/*
+routeWithFileMetadata - a copy of the route with the values that are not set filled in from its route file
+
+On an update (replace) the start, distance, ascent and descent that are still what was read from the API belong to
+the old file so they are replaced as well.  Values the caller changed are kept.
+*/
+func routeWithFileMetadata(route *Route, replace bool) (*Route, error) {
+	routeToSend := *route
+	if len(routeToSend.RouteFile) == 0 {
+		return &routeToSend, nil
+	}
+	analysis, err := AnalyzeRouteFile(routeToSend.RouteFile)
+	if err != nil {
+		return nil, err
+	}
+	if replace {
+		routeToSend.clearFetchedMetadata()
+	}
+	analysis.Apply(&routeToSend)
+	return &routeToSend, nil
+}
+
+//sendRoute - does the multipart request shared by create and update
+func (v *Client) sendRoute(accessToken, method, url string, route *Route) (*Route, error) {
+	client := v.httpClient
+
+	payload := &bytes.Buffer{}
+
+	writer := multipart.NewWriter(payload)
+
+	err := route.convertRouteToFormFields(writer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, url, payload)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+
+	//Do the http request
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	//Handle anything above 299
+	if res.StatusCode >= 300 {
+		return nil, constructWahooErrorFromResponse(res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return convertJSONResponseToRoute(body)
+}
+
+//DeleteRoute - Method to delete a specific route
+func (v *Client) DeleteRoute(accessToken string, routeID int) error {
+	if accessToken == "" || routeID == 0 {
+		return errors.New("Missing Mandatory Value")
+	}
+	url := "https://" + v.baseURL + "/v1/routes/" + strconv.Itoa(routeID)
+	method := "DELETE"
+
+	client := v.httpClient
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+
+	//Do the http request
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	//Handle anything above 299
+	if res.StatusCode >= 300 {
+		return constructWahooErrorFromResponse(res.StatusCode)
+	}
+	return nil
+}
+
 //Heart Rate zones Endpoint
 
 //GetHeartRateZones - gets the heart rate zones
@@ -443,7 +1109,7 @@ func (v *Client) GetHeartRateZones(accessToken string) (*HeartRateZone, error) {
 	url := "https://" + v.baseURL + "/v1/heart_rate_zone"
 	method := "GET"
 
-	client := &http.Client{}
+	client := v.httpClient
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, err
@@ -474,25 +1140,40 @@ func (v *Client) GetHeartRateZones(accessToken string) (*HeartRateZone, error) {
 	return heartRate, nil
 }
 
-//UpdateHeartRateZone - sets the heart rate zone
+//UpdateHeartRateZone - sets the heart rate zone.  Only the fields that are not nil are sent, use UpdateHeartRateZoneFields to clear one
 func (v *Client) UpdateHeartRateZone(accessToken string, newZonesData *HeartRateZone) error {
 
 	if accessToken == "" || newZonesData == nil {
 		return errors.New("Missing Mandatory Value")
 	}
+	return v.UpdateHeartRateZoneFields(accessToken, newZonesData.Update())
+}
+
+//UpdateHeartRateZoneFields - sets or clears the zone fields in the update
+func (v *Client) UpdateHeartRateZoneFields(accessToken string, update *HeartRateZoneUpdate) error {
+
+	if accessToken == "" || update == nil {
+		return errors.New("Missing Mandatory Value")
+	}
+
+	//Catch bad zones here rather than with a 422 from the API
+	err := update.Validate()
+	if err != nil {
+		return err
+	}
 
 	url := "https://" + v.baseURL + "/v1/heart_rate_zone"
 	method := "PUT"
 
-	client := &http.Client{}
+	client := v.httpClient
 
 	payload := &bytes.Buffer{}
 
 	writer := multipart.NewWriter(payload)
 
-	newZonesData.convertHeartRateZonesToFormFields(writer)
+	writeFormFields(writer, update.formFields())
 
-	err := writer.Close()
+	err = writer.Close()
 	if err != nil {
 		return err
 	}
@@ -530,7 +1211,7 @@ func (v *Client) GetPowerZones(accessToken string) (*PowerZone, error) {
 	url := "https://" + v.baseURL + "/v1/power_zone"
 	method := "GET"
 
-	client := &http.Client{}
+	client := v.httpClient
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, err
@@ -561,25 +1242,40 @@ func (v *Client) GetPowerZones(accessToken string) (*PowerZone, error) {
 	return powerZone, nil
 }
 
-//UpdatePowerZones - sets the power Zone
+//UpdatePowerZones - sets the power Zone.  Only the fields that are not nil are sent, use UpdatePowerZoneFields to clear one
 func (v *Client) UpdatePowerZones(accessToken string, newZonesData *PowerZone) error {
 
 	if accessToken == "" || newZonesData == nil {
 		return errors.New("Missing Mandatory Value")
 	}
+	return v.UpdatePowerZoneFields(accessToken, newZonesData.Update())
+}
+
+//UpdatePowerZoneFields - sets or clears the zone fields in the update
+func (v *Client) UpdatePowerZoneFields(accessToken string, update *PowerZoneUpdate) error {
+
+	if accessToken == "" || update == nil {
+		return errors.New("Missing Mandatory Value")
+	}
+
+	//Catch bad zones here rather than with a 422 from the API
+	err := update.Validate()
+	if err != nil {
+		return err
+	}
 
 	url := "https://" + v.baseURL + "/v1/power_zone"
 	method := "PUT"
 
-	client := &http.Client{}
+	client := v.httpClient
 
 	payload := &bytes.Buffer{}
 
 	writer := multipart.NewWriter(payload)
 
-	newZonesData.convertPowerZonesToFormFields(writer)
+	writeFormFields(writer, update.formFields())
 
-	err := writer.Close()
+	err = writer.Close()
 	if err != nil {
 		return err
 	}
@@ -668,3 +1364,45 @@ func convertJSONResponseToWorkoutArray(data []byte) ([]*Workout, error) {
 	}
 	return response.Workouts, nil
 }
+
+func convertJSONResponseToPlan(data []byte) (*Plan, error) {
+	response := &Plan{}
+	err := json.Unmarshal(data, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func convertJSONResponseToPlanArray(data []byte) ([]*Plan, error) {
+	response := []*Plan{}
+	err := json.Unmarshal(data, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func convertJSONResponseToRoute(data []byte) (*Route, error) {
+	response := &Route{}
+	err := json.Unmarshal(data, &response)
+	if err != nil {
+		return nil, err
+	}
+	response.rememberFetchedMetadata()
+	return response, nil
+}
+
+func convertJSONResponseToRouteArray(data []byte) ([]*Route, error) {
+	response := []*Route{}
+	err := json.Unmarshal(data, &response)
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range response {
+		if route != nil {
+			route.rememberFetchedMetadata()
+		}
+	}
+	return response, nil
+}
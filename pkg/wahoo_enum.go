@@ -1,63 +1,325 @@
 package wahoo
 
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
 //WorkoutType - the workout type
 type WorkoutType int
 
+/*
+The workout types.  The IDs are the workout_type_id values used by the API
+
+These used to be untyped ints.  Use Workout.SetType (or int(Biking)) where an *int is needed for WorkoutTypeID.
+*/
 const (
-	Biking                  = 0
-	Running                 = 1
-	Fe                      = 2
-	RunningTrack            = 3
-	RunningTrail            = 4
-	RunnintTreadmill        = 5
-	Walking                 = 6
-	WalkingSpeed            = 7
-	WalkingNordic           = 8
-	Hiking                  = 9
-	Mountaineering          = 10
-	BikingCylocross         = 11
-	BikingIndoor            = 12
-	BikingMountain          = 13
-	BikingRecumbant         = 14
-	BikingRoad              = 15
-	BikingTrack             = 16
-	BikingMotorcycling      = 17
-	FeGeneral               = 18
-	FeTreadmill             = 19
-	FeElliptical            = 20
-	FeBike                  = 21
-	FeRower                 = 22
-	FeClimber               = 23
-	Swimming                = 24
-	SwimmingLap             = 25
-	SwimmingOpenWater       = 26
-	SnowBoarding            = 27
-	Skiing                  = 28
-	SkiingDownhill          = 29
-	SkiingCrossCountry      = 30
-	Skating                 = 31
-	SkatingIce              = 32
-	SkatingInline           = 33
-	LongBoarding            = 34
-	Sailing                 = 35
-	Windsurfing             = 36
-	Canoeing                = 37
-	Kayaking                = 38
-	Rowing                  = 39
-	Kiteboarding            = 40
-	StandUpPaddleBoard      = 41
-	GenericWorkout          = 42
-	CardioClass             = 43
-	StairClimber            = 44
-	WheelChair              = 45
-	Golfing                 = 46
-	Other                   = 47
-	BikingIndoorCylingClass = 49
-	WalkingTreadmill        = 56
-	FeStepper               = 57
-	FeStepMill              = 58
-	FeTreadClimber          = 59
-	FeTotalBody             = 60
-	BikingIndoorTrainer     = 61
+	Biking                  WorkoutType = 0
+	Running                 WorkoutType = 1
+	Fe                      WorkoutType = 2
+	RunningTrack            WorkoutType = 3
+	RunningTrail            WorkoutType = 4
+	RunnintTreadmill        WorkoutType = 5
+	Walking                 WorkoutType = 6
+	WalkingSpeed            WorkoutType = 7
+	WalkingNordic           WorkoutType = 8
+	Hiking                  WorkoutType = 9
+	Mountaineering          WorkoutType = 10
+	BikingCylocross         WorkoutType = 11
+	BikingIndoor            WorkoutType = 12
+	BikingMountain          WorkoutType = 13
+	BikingRecumbant         WorkoutType = 14
+	BikingRoad              WorkoutType = 15
+	BikingTrack             WorkoutType = 16
+	BikingMotorcycling      WorkoutType = 17
+	FeGeneral               WorkoutType = 18
+	FeTreadmill             WorkoutType = 19
+	FeElliptical            WorkoutType = 20
+	FeBike                  WorkoutType = 21
+	FeRower                 WorkoutType = 22
+	FeClimber               WorkoutType = 23
+	Swimming                WorkoutType = 24
+	SwimmingLap             WorkoutType = 25
+	SwimmingOpenWater       WorkoutType = 26
+	SnowBoarding            WorkoutType = 27
+	Skiing                  WorkoutType = 28
+	SkiingDownhill          WorkoutType = 29
+	SkiingCrossCountry      WorkoutType = 30
+	Skating                 WorkoutType = 31
+	SkatingIce              WorkoutType = 32
+	SkatingInline           WorkoutType = 33
+	LongBoarding            WorkoutType = 34
+	Sailing                 WorkoutType = 35
+	Windsurfing             WorkoutType = 36
+	Canoeing                WorkoutType = 37
+	Kayaking                WorkoutType = 38
+	Rowing                  WorkoutType = 39
+	Kiteboarding            WorkoutType = 40
+	StandUpPaddleBoard      WorkoutType = 41
+	GenericWorkout          WorkoutType = 42
+	CardioClass             WorkoutType = 43
+	StairClimber            WorkoutType = 44
+	WheelChair              WorkoutType = 45
+	Golfing                 WorkoutType = 46
+	Other                   WorkoutType = 47
+	BikingIndoorCylingClass WorkoutType = 49
+	WalkingTreadmill        WorkoutType = 56
+	FeStepper               WorkoutType = 57
+	FeStepMill              WorkoutType = 58
+	FeTreadClimber          WorkoutType = 59
+	FeTotalBody             WorkoutType = 60
+	BikingIndoorTrainer     WorkoutType = 61
 )
 
+//WorkoutFamily - the group a workout type belongs to
+type WorkoutFamily string
+
+//The workout families.  Fitness equipment is everything done on a machine in a gym (other than the indoor bikes)
+const (
+	WorkoutFamilyBike             WorkoutFamily = "bike"
+	WorkoutFamilyRun              WorkoutFamily = "run"
+	WorkoutFamilyWalk             WorkoutFamily = "walk"
+	WorkoutFamilySwim             WorkoutFamily = "swim"
+	WorkoutFamilyFitnessEquipment WorkoutFamily = "fitness_equipment"
+	WorkoutFamilyWinter           WorkoutFamily = "winter"
+	WorkoutFamilyWater            WorkoutFamily = "water"
+	WorkoutFamilyOther            WorkoutFamily = "other"
+)
+
+//workoutCapability - what a workout type is and what data a recording of it is expected to have
+type workoutCapability uint8
+
+const (
+	indoor workoutCapability = 1 << iota
+	outdoor
+	hasDistance
+	hasGPS
+	hasPower
+)
+
+//workoutTypeInfo - a known workout type with the name used by String and MarshalText
+type workoutTypeInfo struct {
+	workoutType  WorkoutType
+	name         string
+	family       WorkoutFamily
+	capabilities workoutCapability
+}
+
+//workoutTypes - every known workout type in ID order
+var workoutTypes = []workoutTypeInfo{
+	{Biking, "biking", WorkoutFamilyBike, outdoor | hasDistance | hasGPS | hasPower},
+	{Running, "running", WorkoutFamilyRun, outdoor | hasDistance | hasGPS},
+	{Fe, "fe", WorkoutFamilyFitnessEquipment, indoor},
+	{RunningTrack, "running_track", WorkoutFamilyRun, outdoor | hasDistance | hasGPS},
+	{RunningTrail, "running_trail", WorkoutFamilyRun, outdoor | hasDistance | hasGPS},
+	{RunnintTreadmill, "running_treadmill", WorkoutFamilyRun, indoor | hasDistance},
+	{Walking, "walking", WorkoutFamilyWalk, outdoor | hasDistance | hasGPS},
+	{WalkingSpeed, "walking_speed", WorkoutFamilyWalk, outdoor | hasDistance | hasGPS},
+	{WalkingNordic, "walking_nordic", WorkoutFamilyWalk, outdoor | hasDistance | hasGPS},
+	{Hiking, "hiking", WorkoutFamilyWalk, outdoor | hasDistance | hasGPS},
+	{Mountaineering, "mountaineering", WorkoutFamilyWalk, outdoor | hasDistance | hasGPS},
+	{BikingCylocross, "biking_cyclocross", WorkoutFamilyBike, outdoor | hasDistance | hasGPS | hasPower},
+	{BikingIndoor, "biking_indoor", WorkoutFamilyBike, indoor | hasDistance | hasPower},
+	{BikingMountain, "biking_mountain", WorkoutFamilyBike, outdoor | hasDistance | hasGPS | hasPower},
+	{BikingRecumbant, "biking_recumbent", WorkoutFamilyBike, outdoor | hasDistance | hasGPS | hasPower},
+	{BikingRoad, "biking_road", WorkoutFamilyBike, outdoor | hasDistance | hasGPS | hasPower},
+	{BikingTrack, "biking_track", WorkoutFamilyBike, outdoor | hasDistance | hasGPS | hasPower},
+	{BikingMotorcycling, "biking_motorcycling", WorkoutFamilyOther, outdoor | hasDistance | hasGPS},
+	{FeGeneral, "fe_general", WorkoutFamilyFitnessEquipment, indoor},
+	{FeTreadmill, "fe_treadmill", WorkoutFamilyFitnessEquipment, indoor | hasDistance},
+	{FeElliptical, "fe_elliptical", WorkoutFamilyFitnessEquipment, indoor},
+	{FeBike, "fe_bike", WorkoutFamilyFitnessEquipment, indoor | hasDistance | hasPower},
+	{FeRower, "fe_rower", WorkoutFamilyFitnessEquipment, indoor | hasDistance | hasPower},
+	{FeClimber, "fe_climber", WorkoutFamilyFitnessEquipment, indoor},
+	{Swimming, "swimming", WorkoutFamilySwim, hasDistance},
+	{SwimmingLap, "swimming_lap", WorkoutFamilySwim, indoor | hasDistance},
+	{SwimmingOpenWater, "swimming_open_water", WorkoutFamilySwim, outdoor | hasDistance | hasGPS},
+	{SnowBoarding, "snowboarding", WorkoutFamilyWinter, outdoor | hasDistance | hasGPS},
+	{Skiing, "skiing", WorkoutFamilyWinter, outdoor | hasDistance | hasGPS},
+	{SkiingDownhill, "skiing_downhill", WorkoutFamilyWinter, outdoor | hasDistance | hasGPS},
+	{SkiingCrossCountry, "skiing_cross_country", WorkoutFamilyWinter, outdoor | hasDistance | hasGPS},
+	{Skating, "skating", WorkoutFamilyWinter, outdoor | hasDistance | hasGPS},
+	{SkatingIce, "skating_ice", WorkoutFamilyWinter, outdoor | hasDistance | hasGPS},
+	{SkatingInline, "skating_inline", WorkoutFamilyOther, outdoor | hasDistance | hasGPS},
+	{LongBoarding, "long_boarding", WorkoutFamilyOther, outdoor | hasDistance | hasGPS},
+	{Sailing, "sailing", WorkoutFamilyWater, outdoor | hasDistance | hasGPS},
+	{Windsurfing, "windsurfing", WorkoutFamilyWater, outdoor | hasDistance | hasGPS},
+	{Canoeing, "canoeing", WorkoutFamilyWater, outdoor | hasDistance | hasGPS},
+	{Kayaking, "kayaking", WorkoutFamilyWater, outdoor | hasDistance | hasGPS},
+	{Rowing, "rowing", WorkoutFamilyWater, outdoor | hasDistance | hasGPS},
+	{Kiteboarding, "kiteboarding", WorkoutFamilyWater, outdoor | hasDistance | hasGPS},
+	{StandUpPaddleBoard, "stand_up_paddle_board", WorkoutFamilyWater, outdoor | hasDistance | hasGPS},
+	{GenericWorkout, "workout", WorkoutFamilyOther, 0},
+	{CardioClass, "cardio_class", WorkoutFamilyOther, indoor},
+	{StairClimber, "stair_climber", WorkoutFamilyFitnessEquipment, indoor},
+	{WheelChair, "wheelchair", WorkoutFamilyOther, outdoor | hasDistance | hasGPS},
+	{Golfing, "golfing", WorkoutFamilyOther, outdoor | hasDistance | hasGPS},
+	{Other, "other", WorkoutFamilyOther, 0},
+	{BikingIndoorCylingClass, "biking_indoor_cycling_class", WorkoutFamilyBike, indoor},
+	{WalkingTreadmill, "walking_treadmill", WorkoutFamilyWalk, indoor | hasDistance},
+	{FeStepper, "fe_stepper", WorkoutFamilyFitnessEquipment, indoor},
+	{FeStepMill, "fe_step_mill", WorkoutFamilyFitnessEquipment, indoor},
+	{FeTreadClimber, "fe_tread_climber", WorkoutFamilyFitnessEquipment, indoor},
+	{FeTotalBody, "fe_total_body", WorkoutFamilyFitnessEquipment, indoor},
+	{BikingIndoorTrainer, "biking_indoor_trainer", WorkoutFamilyBike, indoor | hasDistance | hasPower},
+}
+
+//AllWorkoutTypes - every known workout type in ID order
+func AllWorkoutTypes() []WorkoutType {
+	all := make([]WorkoutType, len(workoutTypes))
+	for i, known := range workoutTypes {
+		all[i] = known.workoutType
+	}
+	return all
+}
+
+//Known - false for an ID that is not one of the constants (e.g. a type added to the API after this client)
+func (t WorkoutType) Known() bool {
+	_, ok := lookupWorkoutType(t)
+	return ok
+}
+
+//String - the name of the workout type (e.g. biking_road).  An unknown type is its ID
+func (t WorkoutType) String() string {
+	if known, ok := lookupWorkoutType(t); ok {
+		return known.name
+	}
+	return strconv.Itoa(int(t))
+}
+
+func lookupWorkoutType(t WorkoutType) (workoutTypeInfo, bool) {
+	for _, known := range workoutTypes {
+		if known.workoutType == t {
+			return known, true
+		}
+	}
+	return workoutTypeInfo{}, false
+}
+
+/*
+ParseWorkoutType - the workout type from its name or ID
+
+Names are not case sensitive and can use spaces or dashes instead of underscores (e.g. "Biking Road").  Any ID is
+accepted, including ones that are not known, so a type from the API always survives a round trip through String.
+*/
+func ParseWorkoutType(value string) (WorkoutType, error) {
+	value = strings.TrimSpace(value)
+	if id, err := strconv.Atoi(value); err == nil {
+		return WorkoutType(id), nil
+	}
+	name := strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(value))
+	for _, known := range workoutTypes {
+		if known.name == name {
+			return known.workoutType, nil
+		}
+	}
+	return 0, errors.New("unknown workout type: " + value)
+}
+
+//MarshalText - the String of the workout type so it is written as its name in JSON (or its ID when it is unknown)
+func (t WorkoutType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+//UnmarshalText - reads a name or ID with ParseWorkoutType
+func (t *WorkoutType) UnmarshalText(text []byte) error {
+	parsed, err := ParseWorkoutType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+//UnmarshalJSON - reads a name (see UnmarshalText) or the plain ID the API uses
+func (t *WorkoutType) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var id int
+	if err := json.Unmarshal(data, &id); err == nil {
+		*t = WorkoutType(id)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	return t.UnmarshalText([]byte(text))
+}
+
+//Family - the family of the workout type.  Unknown types are WorkoutFamilyOther
+func (t WorkoutType) Family() WorkoutFamily {
+	if known, ok := lookupWorkoutType(t); ok {
+		return known.family
+	}
+	return WorkoutFamilyOther
+}
+
+//WorkoutTypesInFamily - every known workout type in the family in ID order
+func WorkoutTypesInFamily(family WorkoutFamily) []WorkoutType {
+	var types []WorkoutType
+	for _, known := range workoutTypes {
+		if known.family == family {
+			types = append(types, known.workoutType)
+		}
+	}
+	return types
+}
+
+func (t WorkoutType) has(capability workoutCapability) bool {
+	known, ok := lookupWorkoutType(t)
+	return ok && known.capabilities&capability != 0
+}
+
+/*
+IsIndoor - the workout type is done indoors
+
+Some types are neither indoor or outdoor (e.g. swimming, which can be in a pool or open water) and so are unknown
+types.
+*/
+func (t WorkoutType) IsIndoor() bool {
+	return t.has(indoor)
+}
+
+//IsOutdoor - the workout type is done outdoors (see IsIndoor)
+func (t WorkoutType) IsOutdoor() bool {
+	return t.has(outdoor)
+}
+
+//ExpectsDistance - a recording of the workout type usually has distance (from GPS, a speed sensor or the machine)
+func (t WorkoutType) ExpectsDistance() bool {
+	return t.has(hasDistance)
+}
+
+//ExpectsGPS - a recording of the workout type usually has positions
+func (t WorkoutType) ExpectsGPS() bool {
+	return t.has(hasGPS)
+}
+
+//ExpectsPower - a recording of the workout type usually has power (e.g. a power meter, smart trainer or rower)
+func (t WorkoutType) ExpectsPower() bool {
+	return t.has(hasPower)
+}
+
+//IsCycling - the bike family and the fitness equipment bike
+func (t WorkoutType) IsCycling() bool {
+	return t.Family() == WorkoutFamilyBike || t == FeBike
+}
+
+//IsRunning - the run family and the fitness equipment treadmill
+func (t WorkoutType) IsRunning() bool {
+	return t.Family() == WorkoutFamilyRun || t == FeTreadmill
+}
+
+//IsWalking - the walk family (walking, hiking and mountaineering)
+func (t WorkoutType) IsWalking() bool {
+	return t.Family() == WorkoutFamilyWalk
+}
+
+//IsSwimming - the swim family
+func (t WorkoutType) IsSwimming() bool {
+	return t.Family() == WorkoutFamilySwim
+}
@@ -0,0 +1,251 @@
+package wahoo
+
+import (
+	"strings"
+
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
+)
+
+/*
+Workout type mappings
+
+Each workout type maps to the closest FIT sport/sub_sport, Strava sport type and TrainingPeaks workout type.  Going
+the other way each value maps back to the most common workout type for it, so a round trip is not always exact (e.g.
+walking_nordic is walking in FIT and comes back as walking).  Values with no mapping fall back to the sport on its own
+and then to Other.
+*/
+
+//workoutTypeMapping - the values a workout type maps to on the other platforms
+type workoutTypeMapping struct {
+	fitSport      int
+	fitSubSport   int
+	strava        string
+	trainingPeaks string
+}
+
+//workoutTypeMappings - every known workout type.  The tests check nothing is missing
+var workoutTypeMappings = map[WorkoutType]workoutTypeMapping{
+	Biking:                  {fit.SportCycling, fit.SubSportGeneric, "Ride", "Bike"},
+	Running:                 {fit.SportRunning, fit.SubSportGeneric, "Run", "Run"},
+	Fe:                      {fit.SportFitnessEquipment, fit.SubSportGeneric, "Workout", "Crosstrain"},
+	RunningTrack:            {fit.SportRunning, fit.SubSportTrack, "Run", "Run"},
+	RunningTrail:            {fit.SportRunning, fit.SubSportTrail, "TrailRun", "Run"},
+	RunnintTreadmill:        {fit.SportRunning, fit.SubSportTreadmill, "VirtualRun", "Run"},
+	Walking:                 {fit.SportWalking, fit.SubSportGeneric, "Walk", "Walk"},
+	WalkingSpeed:            {fit.SportWalking, fit.SubSportSpeedWalking, "Walk", "Walk"},
+	WalkingNordic:           {fit.SportWalking, fit.SubSportGeneric, "Walk", "Walk"},
+	Hiking:                  {fit.SportHiking, fit.SubSportGeneric, "Hike", "Walk"},
+	Mountaineering:          {fit.SportMountaineering, fit.SubSportGeneric, "Hike", "Walk"},
+	BikingCylocross:         {fit.SportCycling, fit.SubSportCyclocross, "Ride", "Bike"},
+	BikingIndoor:            {fit.SportCycling, fit.SubSportIndoorCycling, "VirtualRide", "Bike"},
+	BikingMountain:          {fit.SportCycling, fit.SubSportMountain, "MountainBikeRide", "MountainBike"},
+	BikingRecumbant:         {fit.SportCycling, fit.SubSportRecumbent, "Ride", "Bike"},
+	BikingRoad:              {fit.SportCycling, fit.SubSportRoad, "Ride", "Bike"},
+	BikingTrack:             {fit.SportCycling, fit.SubSportTrackCycling, "Ride", "Bike"},
+	BikingMotorcycling:      {fit.SportMotorcycling, fit.SubSportGeneric, "Workout", "Other"},
+	FeGeneral:               {fit.SportFitnessEquipment, fit.SubSportGeneric, "Workout", "Crosstrain"},
+	FeTreadmill:             {fit.SportFitnessEquipment, fit.SubSportTreadmill, "Run", "Run"},
+	FeElliptical:            {fit.SportFitnessEquipment, fit.SubSportElliptical, "Elliptical", "Crosstrain"},
+	FeBike:                  {fit.SportFitnessEquipment, fit.SubSportIndoorCycling, "VirtualRide", "Bike"},
+	FeRower:                 {fit.SportFitnessEquipment, fit.SubSportIndoorRowing, "Rowing", "Rowing"},
+	FeClimber:               {fit.SportFitnessEquipment, fit.SubSportStairClimbing, "StairStepper", "Crosstrain"},
+	Swimming:                {fit.SportSwimming, fit.SubSportGeneric, "Swim", "Swim"},
+	SwimmingLap:             {fit.SportSwimming, fit.SubSportLapSwimming, "Swim", "Swim"},
+	SwimmingOpenWater:       {fit.SportSwimming, fit.SubSportOpenWater, "Swim", "Swim"},
+	SnowBoarding:            {fit.SportSnowboarding, fit.SubSportGeneric, "Snowboard", "Other"},
+	Skiing:                  {fit.SportAlpineSkiing, fit.SubSportGeneric, "AlpineSki", "Other"},
+	SkiingDownhill:          {fit.SportAlpineSkiing, fit.SubSportDownhill, "AlpineSki", "Other"},
+	SkiingCrossCountry:      {fit.SportCrossCountrySkiing, fit.SubSportGeneric, "NordicSki", "XCSki"},
+	Skating:                 {fit.SportIceSkating, fit.SubSportGeneric, "IceSkate", "Other"},
+	SkatingIce:              {fit.SportIceSkating, fit.SubSportGeneric, "IceSkate", "Other"},
+	SkatingInline:           {fit.SportInlineSkating, fit.SubSportGeneric, "InlineSkate", "Other"},
+	LongBoarding:            {fit.SportGeneric, fit.SubSportGeneric, "Skateboard", "Other"},
+	Sailing:                 {fit.SportSailing, fit.SubSportGeneric, "Sail", "Other"},
+	Windsurfing:             {fit.SportWindsurfing, fit.SubSportGeneric, "Windsurf", "Other"},
+	Canoeing:                {fit.SportPaddling, fit.SubSportGeneric, "Canoeing", "Other"},
+	Kayaking:                {fit.SportKayaking, fit.SubSportGeneric, "Kayaking", "Other"},
+	Rowing:                  {fit.SportRowing, fit.SubSportGeneric, "Rowing", "Rowing"},
+	Kiteboarding:            {fit.SportKitesurfing, fit.SubSportGeneric, "Kitesurf", "Other"},
+	StandUpPaddleBoard:      {fit.SportStandUpPaddleboarding, fit.SubSportGeneric, "StandUpPaddling", "Other"},
+	GenericWorkout:          {fit.SportTraining, fit.SubSportGeneric, "Workout", "Other"},
+	CardioClass:             {fit.SportTraining, fit.SubSportCardioTraining, "Workout", "Crosstrain"},
+	StairClimber:            {fit.SportFitnessEquipment, fit.SubSportStairClimbing, "StairStepper", "Crosstrain"},
+	WheelChair:              {fit.SportGeneric, fit.SubSportGeneric, "Wheelchair", "Other"},
+	Golfing:                 {fit.SportGolf, fit.SubSportGeneric, "Golf", "Other"},
+	Other:                   {fit.SportGeneric, fit.SubSportGeneric, "Workout", "Other"},
+	BikingIndoorCylingClass: {fit.SportCycling, fit.SubSportSpin, "VirtualRide", "Bike"},
+	WalkingTreadmill:        {fit.SportWalking, fit.SubSportIndoorWalking, "Walk", "Walk"},
+	FeStepper:               {fit.SportFitnessEquipment, fit.SubSportStairClimbing, "StairStepper", "Crosstrain"},
+	FeStepMill:              {fit.SportFitnessEquipment, fit.SubSportStairClimbing, "StairStepper", "Crosstrain"},
+	FeTreadClimber:          {fit.SportFitnessEquipment, fit.SubSportGeneric, "Workout", "Crosstrain"},
+	FeTotalBody:             {fit.SportFitnessEquipment, fit.SubSportGeneric, "Workout", "Crosstrain"},
+	BikingIndoorTrainer:     {fit.SportCycling, fit.SubSportIndoorCycling, "VirtualRide", "Bike"},
+}
+
+//fitWorkoutTypes - FIT sport and sub_sport back to a workout type
+var fitWorkoutTypes = map[[2]int]WorkoutType{
+	{fit.SportGeneric, fit.SubSportGeneric}:                Other,
+	{fit.SportRunning, fit.SubSportGeneric}:                Running,
+	{fit.SportRunning, fit.SubSportStreet}:                 Running,
+	{fit.SportRunning, fit.SubSportTrack}:                  RunningTrack,
+	{fit.SportRunning, fit.SubSportTrail}:                  RunningTrail,
+	{fit.SportRunning, fit.SubSportTreadmill}:              RunnintTreadmill,
+	{fit.SportRunning, fit.SubSportIndoorRunning}:          RunnintTreadmill,
+	{fit.SportRunning, fit.SubSportVirtualActivity}:        RunnintTreadmill,
+	{fit.SportCycling, fit.SubSportGeneric}:                Biking,
+	{fit.SportCycling, fit.SubSportRoad}:                   BikingRoad,
+	{fit.SportCycling, fit.SubSportMountain}:               BikingMountain,
+	{fit.SportCycling, fit.SubSportDownhill}:               BikingMountain,
+	{fit.SportCycling, fit.SubSportCyclocross}:             BikingCylocross,
+	{fit.SportCycling, fit.SubSportGravelCycling}:          BikingCylocross,
+	{fit.SportCycling, fit.SubSportRecumbent}:              BikingRecumbant,
+	{fit.SportCycling, fit.SubSportTrackCycling}:           BikingTrack,
+	{fit.SportCycling, fit.SubSportSpin}:                   BikingIndoorCylingClass,
+	{fit.SportCycling, fit.SubSportIndoorCycling}:          BikingIndoorTrainer,
+	{fit.SportCycling, fit.SubSportVirtualActivity}:        BikingIndoorTrainer,
+	{fit.SportEBiking, fit.SubSportGeneric}:                Biking,
+	{fit.SportFitnessEquipment, fit.SubSportGeneric}:       Fe,
+	{fit.SportFitnessEquipment, fit.SubSportTreadmill}:     FeTreadmill,
+	{fit.SportFitnessEquipment, fit.SubSportElliptical}:    FeElliptical,
+	{fit.SportFitnessEquipment, fit.SubSportIndoorCycling}: FeBike,
+	{fit.SportFitnessEquipment, fit.SubSportIndoorRowing}:  FeRower,
+	{fit.SportFitnessEquipment, fit.SubSportStairClimbing}: FeClimber,
+	{fit.SportSwimming, fit.SubSportGeneric}:               Swimming,
+	{fit.SportSwimming, fit.SubSportLapSwimming}:           SwimmingLap,
+	{fit.SportSwimming, fit.SubSportOpenWater}:             SwimmingOpenWater,
+	{fit.SportTraining, fit.SubSportGeneric}:               GenericWorkout,
+	{fit.SportTraining, fit.SubSportStrengthTraining}:      GenericWorkout,
+	{fit.SportTraining, fit.SubSportCardioTraining}:        CardioClass,
+	{fit.SportWalking, fit.SubSportGeneric}:                Walking,
+	{fit.SportWalking, fit.SubSportSpeedWalking}:           WalkingSpeed,
+	{fit.SportWalking, fit.SubSportIndoorWalking}:          WalkingTreadmill,
+	{fit.SportCrossCountrySkiing, fit.SubSportGeneric}:     SkiingCrossCountry,
+	{fit.SportAlpineSkiing, fit.SubSportGeneric}:           Skiing,
+	{fit.SportAlpineSkiing, fit.SubSportDownhill}:          SkiingDownhill,
+	{fit.SportSnowboarding, fit.SubSportGeneric}:           SnowBoarding,
+	{fit.SportRowing, fit.SubSportGeneric}:                 Rowing,
+	{fit.SportRowing, fit.SubSportIndoorRowing}:            FeRower,
+	{fit.SportMountaineering, fit.SubSportGeneric}:         Mountaineering,
+	{fit.SportHiking, fit.SubSportGeneric}:                 Hiking,
+	{fit.SportPaddling, fit.SubSportGeneric}:               Canoeing,
+	{fit.SportMotorcycling, fit.SubSportGeneric}:           BikingMotorcycling,
+	{fit.SportGolf, fit.SubSportGeneric}:                   Golfing,
+	{fit.SportInlineSkating, fit.SubSportGeneric}:          SkatingInline,
+	{fit.SportSailing, fit.SubSportGeneric}:                Sailing,
+	{fit.SportIceSkating, fit.SubSportGeneric}:             SkatingIce,
+	{fit.SportStandUpPaddleboarding, fit.SubSportGeneric}:  StandUpPaddleBoard,
+	{fit.SportKayaking, fit.SubSportGeneric}:               Kayaking,
+	{fit.SportWindsurfing, fit.SubSportGeneric}:            Windsurfing,
+	{fit.SportKitesurfing, fit.SubSportGeneric}:            Kiteboarding,
+}
+
+//stravaWorkoutTypes - Strava sport types back to a workout type.  The keys are lower case
+var stravaWorkoutTypes = map[string]WorkoutType{
+	"ride":              Biking,
+	"gravelride":        BikingCylocross,
+	"mountainbikeride":  BikingMountain,
+	"emountainbikeride": BikingMountain,
+	"ebikeride":         Biking,
+	"velomobile":        BikingRecumbant,
+	"handcycle":         Biking,
+	"virtualride":       BikingIndoorTrainer,
+	"run":               Running,
+	"trailrun":          RunningTrail,
+	"virtualrun":        RunnintTreadmill,
+	"walk":              Walking,
+	"hike":              Hiking,
+	"swim":              Swimming,
+	"alpineski":         Skiing,
+	"backcountryski":    Skiing,
+	"nordicski":         SkiingCrossCountry,
+	"rollerski":         SkiingCrossCountry,
+	"snowboard":         SnowBoarding,
+	"iceskate":          SkatingIce,
+	"inlineskate":       SkatingInline,
+	"skateboard":        LongBoarding,
+	"canoeing":          Canoeing,
+	"kayaking":          Kayaking,
+	"rowing":            Rowing,
+	"virtualrow":        FeRower,
+	"sail":              Sailing,
+	"windsurf":          Windsurfing,
+	"kitesurf":          Kiteboarding,
+	"standuppaddling":   StandUpPaddleBoard,
+	"elliptical":        FeElliptical,
+	"stairstepper":      StairClimber,
+	"workout":           GenericWorkout,
+	"weighttraining":    GenericWorkout,
+	"crossfit":          GenericWorkout,
+	"wheelchair":        WheelChair,
+	"golf":              Golfing,
+}
+
+//trainingPeaksWorkoutTypes - TrainingPeaks workout types back to a workout type.  The keys are lower case
+var trainingPeaksWorkoutTypes = map[string]WorkoutType{
+	"bike":         Biking,
+	"mountainbike": BikingMountain,
+	"run":          Running,
+	"walk":         Walking,
+	"swim":         Swimming,
+	"xcski":        SkiingCrossCountry,
+	"rowing":       Rowing,
+	"crosstrain":   Fe,
+	"strength":     GenericWorkout,
+	"other":        Other,
+}
+
+//FITSport - the FIT sport and sub_sport for the workout type.  Unknown types are generic
+func (t WorkoutType) FITSport() (int, int) {
+	if mapping, ok := workoutTypeMappings[t]; ok {
+		return mapping.fitSport, mapping.fitSubSport
+	}
+	return fit.SportGeneric, fit.SubSportGeneric
+}
+
+/*
+WorkoutTypeFromFIT - the workout type for a FIT sport and sub_sport (e.g. from the session message)
+
+A sub_sport without a mapping falls back to the sport's generic workout type and a sport without one is Other.
+*/
+func WorkoutTypeFromFIT(sport, subSport int) WorkoutType {
+	if workoutType, ok := fitWorkoutTypes[[2]int{sport, subSport}]; ok {
+		return workoutType
+	}
+	if workoutType, ok := fitWorkoutTypes[[2]int{sport, fit.SubSportGeneric}]; ok {
+		return workoutType
+	}
+	return Other
+}
+
+//StravaType - the Strava sport type for the workout type (e.g. VirtualRide).  Unknown types are Workout
+func (t WorkoutType) StravaType() string {
+	if mapping, ok := workoutTypeMappings[t]; ok {
+		return mapping.strava
+	}
+	return "Workout"
+}
+
+//WorkoutTypeFromStrava - the workout type for a Strava sport type (or the older activity type).  Not case sensitive
+func WorkoutTypeFromStrava(stravaType string) WorkoutType {
+	if workoutType, ok := stravaWorkoutTypes[strings.ToLower(strings.TrimSpace(stravaType))]; ok {
+		return workoutType
+	}
+	return Other
+}
+
+//TrainingPeaksType - the TrainingPeaks workout type for the workout type (e.g. MountainBike).  Unknown types are Other
+func (t WorkoutType) TrainingPeaksType() string {
+	if mapping, ok := workoutTypeMappings[t]; ok {
+		return mapping.trainingPeaks
+	}
+	return "Other"
+}
+
+//WorkoutTypeFromTrainingPeaks - the workout type for a TrainingPeaks workout type.  Not case sensitive
+func WorkoutTypeFromTrainingPeaks(trainingPeaksType string) WorkoutType {
+	name := strings.Replace(strings.ToLower(strings.TrimSpace(trainingPeaksType)), "-", "", -1)
+	if workoutType, ok := trainingPeaksWorkoutTypes[strings.Replace(name, " ", "", -1)]; ok {
+		return workoutType
+	}
+	return Other
+}
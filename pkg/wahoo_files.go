@@ -0,0 +1,208 @@
+package wahoo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ErrFileTooLarge - returned when a download goes over the client's max file size
+var ErrFileTooLarge = errors.New("File Exceeds The Maximum Allowed Size")
+
+//ErrRangeMismatch - returned when a resumed download gets back a different part of the file than was asked for
+var ErrRangeMismatch = errors.New("File Range Does Not Match The Request")
+
+//FileDownload - the result of downloading a workout file
+type FileDownload struct {
+	//BytesWritten - the number of bytes written to the writer by this call (retries included)
+	BytesWritten int64
+	//TotalSize - the full size of the file if the server reported it, otherwise -1
+	TotalSize int64
+	//SHA256 - hex encoded checksum of the bytes written by this call
+	SHA256 string
+	//ContentType - the content type reported by the server
+	ContentType string
+	//Resumed - true when a range request was used (either by the caller or after a retry)
+	Resumed bool
+}
+
+/*
+DownloadWorkoutFile - streams the FIT file referenced by summary.File into the writer
+
+The download uses the client's http client, retry policy and max file size.  If the connection drops part way
+through the retry picks up where it left off with a range request so nothing is written twice.
+*/
+func (v *Client) DownloadWorkoutFile(ctx context.Context, summary *WorkoutSummary, writer io.Writer) (*FileDownload, error) {
+	return v.ResumeWorkoutFileDownload(ctx, summary, writer, 0)
+}
+
+/*
+ResumeWorkoutFileDownload - same as DownloadWorkoutFile but starts at the byte offset
+
+Use it when a previous download was interrupted and the first offset bytes are already stored.  The checksum only
+covers the bytes written by this call.
+*/
+func (v *Client) ResumeWorkoutFileDownload(ctx context.Context, summary *WorkoutSummary, writer io.Writer, offset int64) (*FileDownload, error) {
+	if summary == nil || summary.File == nil || summary.File.URL == "" || writer == nil || offset < 0 {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	return v.downloadFile(ctx, summary.File.URL, writer, offset)
+}
+
+//DownloadPlanFile - streams the plan JSON referenced by plan.File into the writer.  Same behaviour as DownloadWorkoutFile
+func (v *Client) DownloadPlanFile(ctx context.Context, plan *Plan, writer io.Writer) (*FileDownload, error) {
+	if plan == nil || plan.File == nil || plan.File.URL == "" || writer == nil {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	return v.downloadFile(ctx, plan.File.URL, writer, 0)
+}
+
+//downloadFile - the download and retry loop shared by the file downloads
+func (v *Client) downloadFile(ctx context.Context, url string, writer io.Writer, offset int64) (*FileDownload, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	result := &FileDownload{TotalSize: -1, Resumed: offset > 0}
+	checksum := sha256.New()
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = v.downloadFileAttempt(ctx, url, writer, offset+result.BytesWritten, checksum, result)
+		if err == nil || !retry || attempt >= v.maxRetries {
+			break
+		}
+		//Anything already written is kept so the next attempt is a range request
+		if result.BytesWritten > 0 || offset > 0 {
+			result.Resumed = true
+		}
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(v.retryWait):
+		}
+	}
+	result.SHA256 = hex.EncodeToString(checksum.Sum(nil))
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+//downloadFileAttempt - makes a single request for the file.  The bool is true when the error is worth retrying
+func (v *Client) downloadFileAttempt(ctx context.Context, url string, writer io.Writer, start int64, checksum hash.Hash, result *FileDownload) (bool, error) {
+	method := "GET"
+
+	client := v.httpClient
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	if start > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(start, 10)+"-")
+	}
+
+	//Do the http request
+	res, err := client.Do(req)
+	if err != nil {
+		//A cancelled context is not worth retrying
+		return ctx.Err() == nil, err
+	}
+	defer res.Body.Close()
+
+	//The range starts at the end of the file so there is nothing left to fetch.  Any other 416 means the file is not
+	//the one that was partly downloaded
+	if res.StatusCode == http.StatusRequestedRangeNotSatisfiable && start > 0 {
+		total := totalFromContentRange(res.Header.Get("Content-Range"))
+		if total != start {
+			return false, ErrRangeMismatch
+		}
+		result.TotalSize = total
+		return false, nil
+	}
+
+	//Handle anything above 299
+	if res.StatusCode >= 300 {
+		return res.StatusCode == 429 || res.StatusCode >= 500, constructWahooErrorFromResponse(res.StatusCode)
+	}
+
+	result.ContentType = res.Header.Get("Content-Type")
+
+	body := io.Reader(res.Body)
+	switch {
+	case res.StatusCode == http.StatusPartialContent:
+		//Appending anything but the bytes from start on would corrupt the file
+		if startFromContentRange(res.Header.Get("Content-Range")) != start {
+			return false, ErrRangeMismatch
+		}
+		if total := totalFromContentRange(res.Header.Get("Content-Range")); total >= 0 {
+			result.TotalSize = total
+		}
+	case start > 0:
+		//The server ignored the range so throw away what we already have
+		if _, err := io.CopyN(ioutil.Discard, body, start); err != nil {
+			return true, err
+		}
+		if res.ContentLength >= 0 {
+			result.TotalSize = res.ContentLength
+		}
+	default:
+		if res.ContentLength >= 0 {
+			result.TotalSize = res.ContentLength
+		}
+	}
+
+	if result.TotalSize > v.maxFileSize {
+		return false, ErrFileTooLarge
+	}
+
+	written, err := io.Copy(io.MultiWriter(writer, checksum), io.LimitReader(body, v.maxFileSize-start))
+	result.BytesWritten += written
+	if err != nil {
+		return true, err
+	}
+	//Anything left in the body means the limit was hit
+	if n, _ := io.ReadFull(body, make([]byte, 1)); n > 0 {
+		return false, ErrFileTooLarge
+	}
+	return false, nil
+}
+
+//startFromContentRange - pulls the first byte out of a "bytes 100-199/1234" header.  Returns -1 when it is missing
+func startFromContentRange(contentRange string) int64 {
+	if !strings.HasPrefix(contentRange, "bytes ") {
+		return -1
+	}
+	index := strings.Index(contentRange, "-")
+	if index < 0 {
+		return -1
+	}
+	first, err := strconv.ParseInt(strings.TrimSpace(contentRange[len("bytes "):index]), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return first
+}
+
+//totalFromContentRange - pulls the full size out of a "bytes 0-99/1234" header.  Returns -1 when unknown
+func totalFromContentRange(contentRange string) int64 {
+	index := strings.LastIndex(contentRange, "/")
+	if index < 0 {
+		return -1
+	}
+	total, err := strconv.ParseInt(contentRange[index+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return total
+}
@@ -0,0 +1,437 @@
+package wahoo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
+)
+
+//The factors that turn a best effort into an FTP estimate
+const (
+	//FTPFactor20Minute - FTP is 95% of the best 20 minute power
+	FTPFactor20Minute = 0.95
+	//FTPFactorRampTest - FTP is 75% of the best 1 minute power of a ramp test
+	FTPFactorRampTest = 0.75
+	//RampTestMinimumSteps - a ramp test has at least this many minutes in a row that are harder than the one before
+	RampTestMinimumSteps = 8
+	//FTPTopEfforts - the FTP of a FitnessEstimate is the median of this many of the best workout estimates
+	FTPTopEfforts = 3
+)
+
+//FTPMethod - how an FTP estimate was worked out
+type FTPMethod string
+
+//The FTP methods
+const (
+	FTPFrom20Minutes FTPMethod = "20_minute"
+	FTPFromRampTest  FTPMethod = "ramp_test"
+)
+
+//WorkoutEstimate - the efforts in one workout that say something about FTP and max heart rate
+type WorkoutEstimate struct {
+	Workout *Workout
+	//Best20MinutePower and Best1MinutePower - the best average power over the active time, nil when it is too short
+	Best20MinutePower *float64
+	Best1MinutePower  *float64
+	RampTest          bool
+	//FTP - the estimate from this workout, nil without enough power data
+	FTP       *float64
+	FTPMethod FTPMethod
+	//MaxHeartRate - the highest heart rate recorded
+	MaxHeartRate *float64
+}
+
+/*
+EstimateFromSamples - the FTP and max heart rate estimate from one workout (e.g. from SamplesFromFitFile)
+
+Power is looked at over the active time (see SummaryCalculator.Calculate), with missing power counted as 0 watts.
+A ramp test gives FTPFactorRampTest of the best minute, anything else gives FTPFactor20Minute of the best 20 minutes.
+*/
+func EstimateFromSamples(samples []Sample) *WorkoutEstimate {
+	estimate := &WorkoutEstimate{}
+	for _, sample := range samples {
+		if sample.HeartRate != nil && (estimate.MaxHeartRate == nil || *sample.HeartRate > *estimate.MaxHeartRate) {
+			heartRate := *sample.HeartRate
+			estimate.MaxHeartRate = &heartRate
+		}
+	}
+
+	series := activePowerSeries(samples)
+	if best, ok := bestAverage(series, 20*60); ok {
+		estimate.Best20MinutePower = &best
+		ftp := best * FTPFactor20Minute
+		estimate.FTP = &ftp
+		estimate.FTPMethod = FTPFrom20Minutes
+	}
+	if best, ok := bestAverage(series, 60); ok {
+		estimate.Best1MinutePower = &best
+		if isRampTest(series) {
+			estimate.RampTest = true
+			ftp := best * FTPFactorRampTest
+			estimate.FTP = &ftp
+			estimate.FTPMethod = FTPFromRampTest
+		}
+	}
+	return estimate
+}
+
+//activePowerSeries - one power value per active second (resampled so sub-second recording works too)
+func activePowerSeries(samples []Sample) []float64 {
+	series := &secondSeries{}
+	for i := 0; i < len(samples)-1; i++ {
+		gap := samples[i+1].Time.Sub(samples[i].Time)
+		if samples[i].Paused || gap < 0 || gap > DefaultMaxSampleGap {
+			continue
+		}
+		power := 0.0
+		if samples[i].Power != nil {
+			power = *samples[i].Power
+		}
+		series.add(power, gap.Seconds())
+	}
+	return series.values
+}
+
+//bestAverage - the best rolling average over window values
+func bestAverage(series []float64, window int) (float64, bool) {
+	if len(series) < window {
+		return 0, false
+	}
+	var rolling, best float64
+	for i, value := range series {
+		rolling += value
+		if i >= window {
+			rolling -= series[i-window]
+		}
+		if i >= window-1 && rolling > best {
+			best = rolling
+		}
+	}
+	return best / float64(window), true
+}
+
+/*
+isRampTest - the hardest minute comes at the end of RampTestMinimumSteps minutes that each go up, and the rider
+stops (or drops below 80% of it) straight after
+*/
+func isRampTest(series []float64) bool {
+	minutes := make([]float64, len(series)/60)
+	for i := range minutes {
+		for _, value := range series[i*60 : (i+1)*60] {
+			minutes[i] += value / 60
+		}
+	}
+	if len(minutes) < RampTestMinimumSteps+1 {
+		return false
+	}
+	peak := 0
+	for i, minute := range minutes {
+		if minute > minutes[peak] {
+			peak = i
+		}
+	}
+	steps := 0
+	for i := peak; i > 0 && minutes[i] > minutes[i-1]; i-- {
+		steps++
+	}
+	return steps >= RampTestMinimumSteps && (peak == len(minutes)-1 || minutes[peak+1] < minutes[peak]*0.8)
+}
+
+//FitnessEstimate - the FTP and max heart rate across a set of workouts
+type FitnessEstimate struct {
+	Workouts []*WorkoutEstimate
+	//Skipped - workouts without a file, with a file that could not be downloaded (e.g. a 404 or an expired URL) or
+	//with a file that could not be decoded
+	Skipped int
+	//FTP - the median of the FTPTopEfforts best workout estimates (see EstimateFitness).  FTPWorkout is the one it
+	//came from
+	FTP        *float64
+	FTPWorkout *WorkoutEstimate
+	//MaxHeartRate - the highest heart rate in any workout.  MaxHeartRateWorkout is the one it came from
+	MaxHeartRate        *float64
+	MaxHeartRateWorkout *WorkoutEstimate
+}
+
+//Add - adds a workout and updates the FTP and max heart rate
+func (e *FitnessEstimate) Add(workout *WorkoutEstimate) {
+	if workout == nil {
+		return
+	}
+	e.Workouts = append(e.Workouts, workout)
+	if workout.FTP != nil {
+		e.FTPWorkout = medianTopEffort(e.Workouts)
+		e.FTP = e.FTPWorkout.FTP
+	}
+	if workout.MaxHeartRate != nil && (e.MaxHeartRate == nil || *workout.MaxHeartRate > *e.MaxHeartRate) {
+		e.MaxHeartRate = workout.MaxHeartRate
+		e.MaxHeartRateWorkout = workout
+	}
+}
+
+/*
+medianTopEffort - the median of the FTPTopEfforts workouts with the highest FTP, the lower of the middle two when
+there are only two.  Once there are two estimates a single spike (a power meter glitch or a bad file) is never used
+*/
+func medianTopEffort(workouts []*WorkoutEstimate) *WorkoutEstimate {
+	withFTP := []*WorkoutEstimate{}
+	for _, workout := range workouts {
+		if workout.FTP != nil {
+			withFTP = append(withFTP, workout)
+		}
+	}
+	if len(withFTP) == 0 {
+		return nil
+	}
+	sort.SliceStable(withFTP, func(i, j int) bool { return *withFTP[i].FTP > *withFTP[j].FTP })
+	if len(withFTP) > FTPTopEfforts {
+		withFTP = withFTP[:FTPTopEfforts]
+	}
+	return withFTP[len(withFTP)/2]
+}
+
+/*
+EstimateFitness - goes through a user's workouts with GetAllWorkouts and estimates FTP and max heart rate from their
+files
+
+Workouts that started before since are left out (pass the zero time for all of them).  The workouts come newest
+first so paging stops at the first page where every workout is older than since.  Every file is downloaded so keep
+since recent for users with a long history.  A file that can not be downloaded or decoded is counted in Skipped
+rather than failing the estimate.
+
+Each workout gives its own FTP estimate (see EstimateFromSamples).  The estimate for the user is the median of the
+FTPTopEfforts best of them rather than the best one, so one workout with a power spike does not set the zones.  With
+only two workouts the lower one is used and with one that one is used.  The max heart rate is the highest recorded.
+*/
+func (v *Client) EstimateFitness(ctx context.Context, accessToken string, since time.Time) (*FitnessEstimate, error) {
+	if accessToken == "" {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	const perPage = 50
+	estimate := &FitnessEstimate{}
+	for page := 1; ; page++ {
+		workouts, err := v.GetAllWorkouts(accessToken, page, perPage)
+		if err != nil {
+			return nil, err
+		}
+		allOlder := len(workouts) > 0
+		for _, workout := range workouts {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if workout.Starts != nil && workout.Starts.Before(since) {
+				continue
+			}
+			allOlder = false
+			if workout.WorkoutSummary == nil || workout.WorkoutSummary.File == nil || workout.WorkoutSummary.File.URL == "" {
+				estimate.Skipped++
+				continue
+			}
+			buffer := &bytes.Buffer{}
+			if _, err := v.DownloadWorkoutFile(ctx, workout.WorkoutSummary, buffer); err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				estimate.Skipped++
+				continue
+			}
+			file, err := fit.DecodeBytes(buffer.Bytes())
+			if err != nil {
+				estimate.Skipped++
+				continue
+			}
+			workoutEstimate := EstimateFromSamples(SamplesFromFitFile(file))
+			workoutEstimate.Workout = workout
+			estimate.Add(workoutEstimate)
+		}
+		if len(workouts) < perPage || allOlder {
+			return estimate, nil
+		}
+	}
+}
+
+//ZoneChange - one field that a proposal changes.  Field is the name used in the PUT (e.g. power_zone[ftp])
+type ZoneChange struct {
+	Field    string
+	Current  *int
+	Proposed *int
+}
+
+//ZoneProposal - updated zones from a FitnessEstimate.  A zone is nil when there is nothing to change
+type ZoneProposal struct {
+	Estimate      *FitnessEstimate
+	PowerZone     *PowerZone
+	HeartRateZone *HeartRateZone
+	Changes       []ZoneChange
+}
+
+/*
+ProposeZones - zones for the estimated FTP and max heart rate, with the changes from the current zones
+
+The current zones keep their shape: power zones are scaled by the change in FTP and heart rate zones by the change in
+heart rate reserve (maximum - resting, or just maximum without a resting heart rate).  Without current zones (or
+when scaling them would not validate) the Coggan power zones and percent of max heart rate zones are used.  The max
+heart rate is only proposed when it is above the current maximum since a history without a max effort can not lower
+it.  Either current zone can be nil.
+*/
+func (e *FitnessEstimate) ProposeZones(currentPower *PowerZone, currentHeartRate *HeartRateZone) *ZoneProposal {
+	proposal := &ZoneProposal{Estimate: e}
+	if currentPower == nil {
+		currentPower = &PowerZone{}
+	}
+	if currentHeartRate == nil {
+		currentHeartRate = &HeartRateZone{}
+	}
+
+	if e.FTP != nil && int(math.Round(*e.FTP)) > 0 {
+		power := proposePowerZone(currentPower, int(math.Round(*e.FTP)))
+		if proposal.addChanges("power_zone", powerZoneFields(currentPower), powerZoneFields(power)) {
+			proposal.PowerZone = power
+		}
+	}
+	if e.MaxHeartRate != nil {
+		maximum := int(math.Round(*e.MaxHeartRate))
+		if currentHeartRate.Maximum == nil || maximum > *currentHeartRate.Maximum {
+			heartRate := proposeHeartRateZone(currentHeartRate, maximum)
+			if proposal.addChanges("heart_rate_zone", heartRateZoneFields(currentHeartRate), heartRateZoneFields(heartRate)) {
+				proposal.HeartRateZone = heartRate
+			}
+		}
+	}
+	return proposal
+}
+
+//proposePowerZone - the current zones scaled to the new FTP, or the Coggan model for the zone count
+func proposePowerZone(current *PowerZone, ftp int) *PowerZone {
+	if current.Ftp != nil && *current.Ftp > 0 && current.Zone2 != nil {
+		ratio := float64(ftp) / float64(*current.Ftp)
+		scaled := &PowerZone{
+			Zone1:     scaleZone(current.Zone1, 0, ratio),
+			Zone2:     scaleZone(current.Zone2, 0, ratio),
+			Zone3:     scaleZone(current.Zone3, 0, ratio),
+			Zone4:     scaleZone(current.Zone4, 0, ratio),
+			Zone5:     scaleZone(current.Zone5, 0, ratio),
+			Zone6:     scaleZone(current.Zone6, 0, ratio),
+			Zone7:     scaleZone(current.Zone7, 0, ratio),
+			Ftp:       &ftp,
+			ZoneCount: current.ZoneCount,
+		}
+		if scaled.Validate() == nil {
+			return scaled
+		}
+	}
+	model := PowerZonesCoggan7
+	if current.ZoneCount != nil && *current.ZoneCount == 6 {
+		model = PowerZonesCoggan6
+	} else if current.ZoneCount != nil && *current.ZoneCount == 5 {
+		model = PowerZonesCoggan5
+	}
+	zones, _ := CalculatePowerZones(ftp, model)
+	return zones
+}
+
+//proposeHeartRateZone - the current zones scaled to the new heart rate reserve, or percent of the new maximum
+func proposeHeartRateZone(current *HeartRateZone, maximum int) *HeartRateZone {
+	resting := 0
+	if current.Resting != nil && *current.Resting > 0 && *current.Resting < maximum {
+		resting = *current.Resting
+	}
+	if current.Maximum != nil && *current.Maximum > resting && current.Zone1 != nil {
+		ratio := float64(maximum-resting) / float64(*current.Maximum-resting)
+		scaled := &HeartRateZone{
+			Zone1:   scaleZone(current.Zone1, resting, ratio),
+			Zone2:   scaleZone(current.Zone2, resting, ratio),
+			Zone3:   scaleZone(current.Zone3, resting, ratio),
+			Zone4:   scaleZone(current.Zone4, resting, ratio),
+			Zone5:   scaleZone(current.Zone5, resting, ratio),
+			Resting: current.Resting,
+			Maximum: &maximum,
+		}
+		if scaled.Validate() == nil {
+			return scaled
+		}
+	}
+	zones, _ := CalculateHeartRateZonesFromMax(maximum, resting)
+	return zones
+}
+
+//scaleZone - moves a zone so its distance from offset changes by ratio
+func scaleZone(zone *int, offset int, ratio float64) *int {
+	if zone == nil {
+		return nil
+	}
+	scaled := int(math.Round(float64(offset) + float64(*zone-offset)*ratio))
+	return &scaled
+}
+
+func powerZoneFields(zones *PowerZone) []zoneValue {
+	return []zoneValue{
+		{"zone_1", zones.Zone1},
+		{"zone_2", zones.Zone2},
+		{"zone_3", zones.Zone3},
+		{"zone_4", zones.Zone4},
+		{"zone_5", zones.Zone5},
+		{"zone_6", zones.Zone6},
+		{"zone_7", zones.Zone7},
+		{"ftp", zones.Ftp},
+		{"zone_count", zones.ZoneCount},
+	}
+}
+
+func heartRateZoneFields(zones *HeartRateZone) []zoneValue {
+	return []zoneValue{
+		{"zone_1", zones.Zone1},
+		{"zone_2", zones.Zone2},
+		{"zone_3", zones.Zone3},
+		{"zone_4", zones.Zone4},
+		{"zone_5", zones.Zone5},
+		{"resting", zones.Resting},
+		{"maximum", zones.Maximum},
+	}
+}
+
+//addChanges - every field where the proposed value is set and is not the current one.  False when nothing changed
+func (p *ZoneProposal) addChanges(prefix string, current, proposed []zoneValue) bool {
+	changes := len(p.Changes)
+	for i := range proposed {
+		if proposed[i].value == nil || (current[i].value != nil && *current[i].value == *proposed[i].value) {
+			continue
+		}
+		p.Changes = append(p.Changes, ZoneChange{
+			Field:    prefix + "[" + proposed[i].field + "]",
+			Current:  current[i].value,
+			Proposed: proposed[i].value,
+		})
+	}
+	return len(p.Changes) > changes
+}
+
+/*
+ProposeZones - EstimateFitness and then FitnessEstimate.ProposeZones against the zones from GetPowerZones and
+GetHeartRateZones
+
+Nothing is updated, send the proposed zones with UpdatePowerZones and UpdateHeartRateZone once they are accepted.
+*/
+func (v *Client) ProposeZones(ctx context.Context, accessToken string, since time.Time) (*ZoneProposal, error) {
+	estimate, err := v.EstimateFitness(ctx, accessToken, since)
+	if err != nil {
+		return nil, err
+	}
+	power, err := v.GetPowerZones(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	heartRate, err := v.GetHeartRateZones(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return estimate.ProposeZones(power, heartRate), nil
+}
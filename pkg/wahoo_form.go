@@ -0,0 +1,290 @@
+package wahoo
+
+import (
+	"fmt"
+	"mime/multipart"
+	"strconv"
+	"time"
+)
+
+/*
+Form fields
+
+The user, workout and zone updates are sent as multipart forms.  Each update payload lists its writable fields once in
+a formFields method, the form key next to the value, and writeFormFields sends the ones that are set.  The key is the
+JSON name of the field inside the type's form prefix (e.g. user[height]).  A null optional field is sent with an empty
+value (see Optional fields for what that is expected to do).
+*/
+
+//formField - one field of a form.  value is nil when the field is not sent
+type formField struct {
+	key   string
+	value *string
+}
+
+//writeFormFields - writes every field that has a value
+func writeFormFields(writer *multipart.Writer, fields []formField) {
+	for _, field := range fields {
+		if field.value != nil {
+			_ = writer.WriteField(field.key, *field.value)
+		}
+	}
+}
+
+func formString(value *string) *string {
+	return value
+}
+
+func formInt(value *int) *string {
+	if value == nil {
+		return nil
+	}
+	formatted := strconv.Itoa(*value)
+	return &formatted
+}
+
+func formFloat(value *float64) *string {
+	if value == nil {
+		return nil
+	}
+	formatted := fmt.Sprintf("%f", *value)
+	return &formatted
+}
+
+//formTimestamp - the time in UTC in the Wahoo format.  A zero time is not sent
+func formTimestamp(value *time.Time) *string {
+	if value == nil || value.IsZero() {
+		return nil
+	}
+	formatted := value.UTC().Format(wahooDateString)
+	return &formatted
+}
+
+//formDate - the date without a time (e.g. a birthday), left in its own time zone so the day does not move
+func formDate(value *time.Time) *string {
+	if value == nil || value.IsZero() {
+		return nil
+	}
+	formatted := value.Format(wahooYearDateFormat)
+	return &formatted
+}
+
+//UserUpdate - the user fields to change with UpdateUserFields.  Fields that are unset are left alone
+type UserUpdate struct {
+	Height OptionalFloat
+	Weight OptionalFloat
+	First  OptionalString
+	Last   OptionalString
+	Email  OptionalString
+	Mobile OptionalString
+	Birth  OptionalTime
+	Gender OptionalInt
+}
+
+//Update - an update that sets every field that is not nil
+func (v *User) Update() *UserUpdate {
+	return &UserUpdate{
+		Height: optionalFloat(v.Height),
+		Weight: optionalFloat(v.Weight),
+		First:  optionalString(v.First),
+		Last:   optionalString(v.Last),
+		Email:  optionalString(v.Email),
+		Mobile: optionalString(v.Mobile),
+		Birth:  optionalTime(v.Birth),
+		Gender: optionalInt(v.Gender),
+	}
+}
+
+//formFields - the writable user fields
+func (v *UserUpdate) formFields() []formField {
+	return []formField{
+		{"user[email]", v.Email.formValue()},
+		{"user[first]", v.First.formValue()},
+		{"user[last]", v.Last.formValue()},
+		{"user[mobile]", v.Mobile.formValue()},
+		{"user[height]", v.Height.formValue()},
+		{"user[weight]", v.Weight.formValue()},
+		{"user[birth]", v.Birth.formValue(formDate)},
+		{"user[gender]", v.Gender.formValue()},
+	}
+}
+
+/*
+WorkoutUpdate - the workout fields to change with UpdateWorkoutFields.  Fields that are unset are left alone
+
+ID is the workout to update.  The summary can not be cleared, only the values in it that are set are sent.
+*/
+type WorkoutUpdate struct {
+	ID             int
+	Starts         OptionalTime
+	Minutes        OptionalInt
+	Name           OptionalString
+	PlanID         OptionalString
+	WorkoutToken   OptionalString
+	WorkoutTypeID  OptionalInt
+	WorkoutSummary *WorkoutSummary
+}
+
+//Update - an update that sets every field that is not nil
+func (v *Workout) Update() *WorkoutUpdate {
+	return &WorkoutUpdate{
+		ID:             v.ID,
+		Starts:         optionalTime(v.Starts),
+		Minutes:        optionalInt(v.Minutes),
+		Name:           optionalString(v.Name),
+		PlanID:         optionalString(v.PlanID),
+		WorkoutToken:   optionalString(v.WorkoutToken),
+		WorkoutTypeID:  optionalInt(v.WorkoutTypeID),
+		WorkoutSummary: v.WorkoutSummary,
+	}
+}
+
+//formFields - the writable workout fields, including the summary when it is set
+func (v *WorkoutUpdate) formFields() []formField {
+	fields := []formField{
+		{"workout[starts]", v.Starts.formValue(formTimestamp)},
+		{"workout[minutes]", v.Minutes.formValue()},
+		{"workout[name]", v.Name.formValue()},
+		{"workout[plan_id]", v.PlanID.formValue()},
+		{"workout[workout_token]", v.WorkoutToken.formValue()},
+		{"workout[workout_type_id]", v.WorkoutTypeID.formValue()},
+	}
+	if v.WorkoutSummary != nil {
+		fields = append(fields, v.WorkoutSummary.formFields("workout[workout_summary]")...)
+	}
+	return fields
+}
+
+//formFields - the writable summary fields inside prefix
+func (v *WorkoutSummary) formFields(prefix string) []formField {
+	fields := []formField{
+		{prefix + "[heart_rate_avg]", formFloat(v.HeartRateAvg)},
+		{prefix + "[calories_accum]", formFloat(v.CaloriesAccum)},
+		{prefix + "[power_avg]", formFloat(v.PowerAvg)},
+		{prefix + "[distance_accum]", formFloat(v.DistanceAccum)},
+		{prefix + "[cadence_avg]", formFloat(v.CadenceAvg)},
+		{prefix + "[ascent_accum]", formFloat(v.AscentAccum)},
+		{prefix + "[duration_active_accum]", formFloat(v.DurationActiveAccum)},
+		{prefix + "[duration_paused_accum]", formFloat(v.DurationPausedAccum)},
+		{prefix + "[duration_total_accum]", formFloat(v.DurationTotalAccum)},
+		{prefix + "[power_bike_np_last]", formFloat(v.PowerBikeNpLast)},
+		{prefix + "[power_bike_tss_last]", formFloat(v.PowerBikeTssLast)},
+		{prefix + "[speed_avg]", formFloat(v.SpeedAvg)},
+		{prefix + "[work_accum]", formFloat(v.WorkAccum)},
+	}
+	if v.File != nil && v.File.URL != "" {
+		url := v.File.URL
+		fields = append(fields, formField{prefix + "[file][url]", &url})
+	}
+	return fields
+}
+
+//HeartRateZoneUpdate - the heart rate zone fields to change with UpdateHeartRateZoneFields
+type HeartRateZoneUpdate struct {
+	Zone1   OptionalInt
+	Zone2   OptionalInt
+	Zone3   OptionalInt
+	Zone4   OptionalInt
+	Zone5   OptionalInt
+	Resting OptionalInt
+	Maximum OptionalInt
+}
+
+//Update - an update that sets every field that is not nil
+func (v *HeartRateZone) Update() *HeartRateZoneUpdate {
+	return &HeartRateZoneUpdate{
+		Zone1:   optionalInt(v.Zone1),
+		Zone2:   optionalInt(v.Zone2),
+		Zone3:   optionalInt(v.Zone3),
+		Zone4:   optionalInt(v.Zone4),
+		Zone5:   optionalInt(v.Zone5),
+		Resting: optionalInt(v.Resting),
+		Maximum: optionalInt(v.Maximum),
+	}
+}
+
+//Validate - checks the values that are being set the same way as HeartRateZone.Validate.  Null fields are not checked
+func (v *HeartRateZoneUpdate) Validate() error {
+	zones := &HeartRateZone{
+		Zone1:   v.Zone1.pointer(),
+		Zone2:   v.Zone2.pointer(),
+		Zone3:   v.Zone3.pointer(),
+		Zone4:   v.Zone4.pointer(),
+		Zone5:   v.Zone5.pointer(),
+		Resting: v.Resting.pointer(),
+		Maximum: v.Maximum.pointer(),
+	}
+	return zones.Validate()
+}
+
+//formFields - the writable heart rate zone fields
+func (v *HeartRateZoneUpdate) formFields() []formField {
+	return []formField{
+		{"heart_rate_zone[zone_1]", v.Zone1.formValue()},
+		{"heart_rate_zone[zone_2]", v.Zone2.formValue()},
+		{"heart_rate_zone[zone_3]", v.Zone3.formValue()},
+		{"heart_rate_zone[zone_4]", v.Zone4.formValue()},
+		{"heart_rate_zone[zone_5]", v.Zone5.formValue()},
+		{"heart_rate_zone[resting]", v.Resting.formValue()},
+		{"heart_rate_zone[maximum]", v.Maximum.formValue()},
+	}
+}
+
+//PowerZoneUpdate - the power zone fields to change with UpdatePowerZoneFields
+type PowerZoneUpdate struct {
+	Zone1     OptionalInt
+	Zone2     OptionalInt
+	Zone3     OptionalInt
+	Zone4     OptionalInt
+	Zone5     OptionalInt
+	Zone6     OptionalInt
+	Zone7     OptionalInt
+	Ftp       OptionalInt
+	ZoneCount OptionalInt
+}
+
+//Update - an update that sets every field that is not nil
+func (v *PowerZone) Update() *PowerZoneUpdate {
+	return &PowerZoneUpdate{
+		Zone1:     optionalInt(v.Zone1),
+		Zone2:     optionalInt(v.Zone2),
+		Zone3:     optionalInt(v.Zone3),
+		Zone4:     optionalInt(v.Zone4),
+		Zone5:     optionalInt(v.Zone5),
+		Zone6:     optionalInt(v.Zone6),
+		Zone7:     optionalInt(v.Zone7),
+		Ftp:       optionalInt(v.Ftp),
+		ZoneCount: optionalInt(v.ZoneCount),
+	}
+}
+
+//Validate - checks the values that are being set the same way as PowerZone.Validate.  Null fields are not checked
+func (v *PowerZoneUpdate) Validate() error {
+	zones := &PowerZone{
+		Zone1:     v.Zone1.pointer(),
+		Zone2:     v.Zone2.pointer(),
+		Zone3:     v.Zone3.pointer(),
+		Zone4:     v.Zone4.pointer(),
+		Zone5:     v.Zone5.pointer(),
+		Zone6:     v.Zone6.pointer(),
+		Zone7:     v.Zone7.pointer(),
+		Ftp:       v.Ftp.pointer(),
+		ZoneCount: v.ZoneCount.pointer(),
+	}
+	return zones.Validate()
+}
+
+//formFields - the writable power zone fields
+func (v *PowerZoneUpdate) formFields() []formField {
+	return []formField{
+		{"power_zone[zone_1]", v.Zone1.formValue()},
+		{"power_zone[zone_2]", v.Zone2.formValue()},
+		{"power_zone[zone_3]", v.Zone3.formValue()},
+		{"power_zone[zone_4]", v.Zone4.formValue()},
+		{"power_zone[zone_5]", v.Zone5.formValue()},
+		{"power_zone[zone_6]", v.Zone6.formValue()},
+		{"power_zone[zone_7]", v.Zone7.formValue()},
+		{"power_zone[ftp]", v.Ftp.formValue()},
+		{"power_zone[zone_count]", v.ZoneCount.formValue()},
+	}
+}
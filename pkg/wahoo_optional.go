@@ -0,0 +1,225 @@
+package wahoo
+
+import "time"
+
+/*
+Optional fields
+
+A nil pointer on User, Workout and the zones means "leave it alone", so on its own it can not clear a value that is
+already set on the server.  The update payloads (UserUpdate, WorkoutUpdate, HeartRateZoneUpdate and
+PowerZoneUpdate) use the optional types below instead, which have three states:
+
+	unset - the zero value, the field is not sent
+	null  - the field is sent with an empty value
+	value - the field is sent with the value
+
+The Wahoo API documentation does not say what it does with an empty value.  Rails style APIs like it usually store
+null for an empty number or date and an empty string for text, but that has not been checked against Wahoo, so check
+the result (e.g. with GetUserData) before relying on a field being cleared.
+*/
+
+//optionalState - which of the three states an optional field is in
+type optionalState int
+
+const (
+	optionalUnset optionalState = iota
+	optionalNull
+	optionalValue
+)
+
+//formValue - the value to send for the state.  value is only called when there is one
+func (v optionalState) formValue(value func() *string) *string {
+	switch v {
+	case optionalNull:
+		empty := ""
+		return &empty
+	case optionalValue:
+		return value()
+	}
+	return nil
+}
+
+//OptionalString - a string that can be unset, null or a value
+type OptionalString struct {
+	state optionalState
+	value string
+}
+
+//SetString - an OptionalString with a value
+func SetString(value string) OptionalString {
+	return OptionalString{state: optionalValue, value: value}
+}
+
+//NullString - an OptionalString that is sent empty to clear the field
+func NullString() OptionalString {
+	return OptionalString{state: optionalNull}
+}
+
+//optionalString - unset when the pointer is nil
+func optionalString(value *string) OptionalString {
+	if value == nil {
+		return OptionalString{}
+	}
+	return SetString(*value)
+}
+
+//IsSet - true when the field is sent (null or a value)
+func (v OptionalString) IsSet() bool {
+	return v.state != optionalUnset
+}
+
+//IsNull - true when the field is cleared
+func (v OptionalString) IsNull() bool {
+	return v.state == optionalNull
+}
+
+//Value - the value, false when it is unset or null
+func (v OptionalString) Value() (string, bool) {
+	return v.value, v.state == optionalValue
+}
+
+func (v OptionalString) formValue() *string {
+	return v.state.formValue(func() *string { return formString(&v.value) })
+}
+
+//OptionalInt - an int that can be unset, null or a value
+type OptionalInt struct {
+	state optionalState
+	value int
+}
+
+//SetInt - an OptionalInt with a value
+func SetInt(value int) OptionalInt {
+	return OptionalInt{state: optionalValue, value: value}
+}
+
+//NullInt - an OptionalInt that is sent empty to clear the field
+func NullInt() OptionalInt {
+	return OptionalInt{state: optionalNull}
+}
+
+//optionalInt - unset when the pointer is nil
+func optionalInt(value *int) OptionalInt {
+	if value == nil {
+		return OptionalInt{}
+	}
+	return SetInt(*value)
+}
+
+//IsSet - true when the field is sent (null or a value)
+func (v OptionalInt) IsSet() bool {
+	return v.state != optionalUnset
+}
+
+//IsNull - true when the field is cleared
+func (v OptionalInt) IsNull() bool {
+	return v.state == optionalNull
+}
+
+//Value - the value, false when it is unset or null
+func (v OptionalInt) Value() (int, bool) {
+	return v.value, v.state == optionalValue
+}
+
+//pointer - the value as a pointer, nil when it is unset or null
+func (v OptionalInt) pointer() *int {
+	if v.state != optionalValue {
+		return nil
+	}
+	value := v.value
+	return &value
+}
+
+func (v OptionalInt) formValue() *string {
+	return v.state.formValue(func() *string { return formInt(&v.value) })
+}
+
+//OptionalFloat - a float64 that can be unset, null or a value
+type OptionalFloat struct {
+	state optionalState
+	value float64
+}
+
+//SetFloat - an OptionalFloat with a value
+func SetFloat(value float64) OptionalFloat {
+	return OptionalFloat{state: optionalValue, value: value}
+}
+
+//NullFloat - an OptionalFloat that is sent empty to clear the field
+func NullFloat() OptionalFloat {
+	return OptionalFloat{state: optionalNull}
+}
+
+//optionalFloat - unset when the pointer is nil
+func optionalFloat(value *float64) OptionalFloat {
+	if value == nil {
+		return OptionalFloat{}
+	}
+	return SetFloat(*value)
+}
+
+//IsSet - true when the field is sent (null or a value)
+func (v OptionalFloat) IsSet() bool {
+	return v.state != optionalUnset
+}
+
+//IsNull - true when the field is cleared
+func (v OptionalFloat) IsNull() bool {
+	return v.state == optionalNull
+}
+
+//Value - the value, false when it is unset or null
+func (v OptionalFloat) Value() (float64, bool) {
+	return v.value, v.state == optionalValue
+}
+
+func (v OptionalFloat) formValue() *string {
+	return v.state.formValue(func() *string { return formFloat(&v.value) })
+}
+
+//OptionalTime - a time that can be unset, null or a value.  A zero time is treated as unset
+type OptionalTime struct {
+	state optionalState
+	value time.Time
+}
+
+//SetTime - an OptionalTime with a value
+func SetTime(value time.Time) OptionalTime {
+	if value.IsZero() {
+		return OptionalTime{}
+	}
+	return OptionalTime{state: optionalValue, value: value}
+}
+
+//NullTime - an OptionalTime that is sent empty to clear the field
+func NullTime() OptionalTime {
+	return OptionalTime{state: optionalNull}
+}
+
+//optionalTime - unset when the pointer is nil
+func optionalTime(value *time.Time) OptionalTime {
+	if value == nil {
+		return OptionalTime{}
+	}
+	return SetTime(*value)
+}
+
+//IsSet - true when the field is sent (null or a value)
+func (v OptionalTime) IsSet() bool {
+	return v.state != optionalUnset
+}
+
+//IsNull - true when the field is cleared
+func (v OptionalTime) IsNull() bool {
+	return v.state == optionalNull
+}
+
+//Value - the value, false when it is unset or null
+func (v OptionalTime) Value() (time.Time, bool) {
+	return v.value, v.state == optionalValue
+}
+
+//formValue - the value written with format (formTimestamp or formDate)
+func (v OptionalTime) formValue(format func(*time.Time) *string) *string {
+	return v.state.formValue(func() *string { return format(&v.value) })
+}
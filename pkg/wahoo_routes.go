@@ -0,0 +1,364 @@
+package wahoo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
+)
+
+//ErrEmptyRoute - the route file does not have any points with a position
+var ErrEmptyRoute = errors.New("route file has no points")
+
+//RoutePoint - a position on a route.  Elevation is meters and nil when the file does not have it
+type RoutePoint struct {
+	Lat       float64
+	Lng       float64
+	Elevation *float64
+}
+
+//RouteBounds - the bounding box of a route in degrees
+type RouteBounds struct {
+	MinLat float64
+	MinLng float64
+	MaxLat float64
+	MaxLng float64
+}
+
+//RouteAnalysis - the metadata CreateRoute needs, worked out from the route file.  Distances are meters
+type RouteAnalysis struct {
+	Name         string
+	Points       int
+	StartLat     float64
+	StartLng     float64
+	EndLat       float64
+	EndLng       float64
+	Distance     float64
+	Ascent       float64
+	Descent      float64
+	HasElevation bool
+	Bounds       RouteBounds
+}
+
+/*
+RouteAnalyzer - works out distance, ascent, descent and bounds from the points of a GPX or FIT route
+
+Route files are usually built from elevation models so they have small steps up and down that are not really
+climbing.  The elevation is averaged over SmoothingDistance (centered on each point) and then a change only counts
+once it goes over ElevationThreshold from the last turning point.
+*/
+type RouteAnalyzer struct {
+	//SmoothingDistance - meters of route the elevation is averaged over.  Negative turns smoothing off
+	SmoothingDistance float64
+	//ElevationThreshold - meters the smoothed elevation has to change before it counts as ascent or descent
+	ElevationThreshold float64
+}
+
+//Defaults used when the RouteAnalyzer values are not set
+const (
+	DefaultRouteSmoothingDistance  = 100.0
+	DefaultRouteElevationThreshold = 1.0
+)
+
+//earthRadius - mean radius of the earth in meters
+const earthRadius = 6371008.8
+
+//AnalyzeRouteFile - analyzes a GPX or FIT route file with the default settings
+func AnalyzeRouteFile(data []byte) (*RouteAnalysis, error) {
+	analyzer := &RouteAnalyzer{}
+	return analyzer.Analyze(data)
+}
+
+//Analyze - detects the type of the route file, reads its points and analyzes them
+func (a *RouteAnalyzer) Analyze(data []byte) (*RouteAnalysis, error) {
+	_, extension, err := routeFileType(data)
+	if err != nil {
+		return nil, err
+	}
+	name := ""
+	var points []RoutePoint
+	if extension == ".fit" {
+		file, err := fit.DecodeBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		points = RoutePointsFromFitFile(file)
+	} else {
+		name, points, err = RoutePointsFromGPX(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+	}
+	analysis, err := a.AnalyzePoints(points)
+	if err != nil {
+		return nil, err
+	}
+	analysis.Name = name
+	return analysis, nil
+}
+
+//AnalyzePoints - analyzes points that have already been read
+func (a *RouteAnalyzer) AnalyzePoints(points []RoutePoint) (*RouteAnalysis, error) {
+	if len(points) == 0 {
+		return nil, ErrEmptyRoute
+	}
+	smoothing := a.SmoothingDistance
+	if smoothing == 0 {
+		smoothing = DefaultRouteSmoothingDistance
+	}
+	threshold := a.ElevationThreshold
+	if threshold <= 0 {
+		threshold = DefaultRouteElevationThreshold
+	}
+
+	first, last := points[0], points[len(points)-1]
+	analysis := &RouteAnalysis{
+		Points:   len(points),
+		StartLat: first.Lat,
+		StartLng: first.Lng,
+		EndLat:   last.Lat,
+		EndLng:   last.Lng,
+		Bounds:   RouteBounds{MinLat: first.Lat, MinLng: first.Lng, MaxLat: first.Lat, MaxLng: first.Lng},
+	}
+
+	//Distance along the route to each point, used for the smoothing window
+	distances := make([]float64, len(points))
+	for i, point := range points {
+		if i > 0 {
+			distances[i] = distances[i-1] + haversineDistance(points[i-1], point)
+		}
+		analysis.Bounds.MinLat = math.Min(analysis.Bounds.MinLat, point.Lat)
+		analysis.Bounds.MinLng = math.Min(analysis.Bounds.MinLng, point.Lng)
+		analysis.Bounds.MaxLat = math.Max(analysis.Bounds.MaxLat, point.Lat)
+		analysis.Bounds.MaxLng = math.Max(analysis.Bounds.MaxLng, point.Lng)
+	}
+	analysis.Distance = distances[len(distances)-1]
+
+	elevations, elevationDistances := []float64{}, []float64{}
+	for i, point := range points {
+		if point.Elevation != nil {
+			elevations = append(elevations, *point.Elevation)
+			elevationDistances = append(elevationDistances, distances[i])
+		}
+	}
+	if len(elevations) == 0 {
+		return analysis, nil
+	}
+	analysis.HasElevation = true
+	if smoothing > 0 {
+		elevations = smoothElevation(elevations, elevationDistances, smoothing/2)
+	}
+	analysis.Ascent, analysis.Descent = elevationChange(elevations, threshold)
+	return analysis, nil
+}
+
+/*
+Apply - fills in the route values that are not set yet
+
+The start position, distance, ascent and descent come from the analysis.  The name is only used when the route
+does not have one and the file had one.
+*/
+func (r *RouteAnalysis) Apply(route *Route) {
+	if route == nil {
+		return
+	}
+	if route.Name == nil && r.Name != "" {
+		name := r.Name
+		route.Name = &name
+	}
+	if route.StartLat == nil {
+		startLat := r.StartLat
+		route.StartLat = &startLat
+	}
+	if route.StartLng == nil {
+		startLng := r.StartLng
+		route.StartLng = &startLng
+	}
+	if route.Distance == nil {
+		distance := r.Distance
+		route.Distance = &distance
+	}
+	if route.Ascent == nil {
+		ascent := r.Ascent
+		route.Ascent = &ascent
+	}
+	if route.Descent == nil {
+		descent := r.Descent
+		route.Descent = &descent
+	}
+}
+
+//routeFileMetadata - the route values that are worked out from the route file
+type routeFileMetadata struct {
+	startLat *float64
+	startLng *float64
+	distance *float64
+	ascent   *float64
+	descent  *float64
+}
+
+//rememberFetchedMetadata - keeps the file metadata as it came from the API so an update can tell what was changed
+func (v *Route) rememberFetchedMetadata() {
+	v.fetched = &routeFileMetadata{
+		startLat: copyFloat(v.StartLat),
+		startLng: copyFloat(v.StartLng),
+		distance: copyFloat(v.Distance),
+		ascent:   copyFloat(v.Ascent),
+		descent:  copyFloat(v.Descent),
+	}
+}
+
+//clearFetchedMetadata - unsets the file metadata that is still what was read from the API
+func (v *Route) clearFetchedMetadata() {
+	if v.fetched == nil {
+		return
+	}
+	for _, field := range []struct{ value, fetched **float64 }{
+		{&v.StartLat, &v.fetched.startLat},
+		{&v.StartLng, &v.fetched.startLng},
+		{&v.Distance, &v.fetched.distance},
+		{&v.Ascent, &v.fetched.ascent},
+		{&v.Descent, &v.fetched.descent},
+	} {
+		if sameFloat(*field.value, *field.fetched) {
+			*field.value = nil
+		}
+	}
+}
+
+func copyFloat(value *float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
+
+func sameFloat(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+//gpxRouteDocument - only the parts of a GPX file needed for the route points
+type gpxRouteDocument struct {
+	Name   string `xml:"metadata>name"`
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Segments []struct {
+			Points []gpxRoutePoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Name   string          `xml:"name"`
+		Points []gpxRoutePoint `xml:"rtept"`
+	} `xml:"rte"`
+}
+
+type gpxRoutePoint struct {
+	Lat       float64  `xml:"lat,attr"`
+	Lng       float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele"`
+}
+
+/*
+RoutePointsFromGPX - reads the name and points of a GPX file
+
+Track points are used when there are any, otherwise the route points.  The name is the metadata name, falling back
+to the first track or route name.
+*/
+func RoutePointsFromGPX(r io.Reader) (string, []RoutePoint, error) {
+	document := &gpxRouteDocument{}
+	if err := xml.NewDecoder(r).Decode(document); err != nil {
+		return "", nil, err
+	}
+	name := strings.TrimSpace(document.Name)
+	points := []RoutePoint{}
+	for _, track := range document.Tracks {
+		if name == "" {
+			name = strings.TrimSpace(track.Name)
+		}
+		for _, segment := range track.Segments {
+			for _, point := range segment.Points {
+				points = append(points, RoutePoint{Lat: point.Lat, Lng: point.Lng, Elevation: point.Elevation})
+			}
+		}
+	}
+	if len(points) == 0 {
+		for _, route := range document.Routes {
+			if name == "" {
+				name = strings.TrimSpace(route.Name)
+			}
+			for _, point := range route.Points {
+				points = append(points, RoutePoint{Lat: point.Lat, Lng: point.Lng, Elevation: point.Elevation})
+			}
+		}
+	}
+	return name, points, nil
+}
+
+//RoutePointsFromFitFile - the records of a FIT course or activity that have a position
+func RoutePointsFromFitFile(file *fit.File) []RoutePoint {
+	points := []RoutePoint{}
+	if file == nil {
+		return points
+	}
+	for _, record := range file.Records {
+		if record.Lat == nil || record.Long == nil {
+			continue
+		}
+		points = append(points, RoutePoint{Lat: *record.Lat, Lng: *record.Long, Elevation: record.Altitude})
+	}
+	return points
+}
+
+//haversineDistance - great circle distance in meters between two points
+func haversineDistance(from, to RoutePoint) float64 {
+	lat1 := from.Lat * math.Pi / 180
+	lat2 := to.Lat * math.Pi / 180
+	deltaLat := lat2 - lat1
+	deltaLng := (to.Lng - from.Lng) * math.Pi / 180
+	h := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(deltaLng/2)*math.Sin(deltaLng/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+//smoothElevation - the average elevation of the points within halfWindow meters of each point
+func smoothElevation(elevations, distances []float64, halfWindow float64) []float64 {
+	smoothed := make([]float64, len(elevations))
+	start, end := 0, 0
+	sum := 0.0
+	for i := range elevations {
+		for end < len(elevations) && distances[end] <= distances[i]+halfWindow {
+			sum += elevations[end]
+			end++
+		}
+		for distances[start] < distances[i]-halfWindow {
+			sum -= elevations[start]
+			start++
+		}
+		smoothed[i] = sum / float64(end-start)
+	}
+	return smoothed
+}
+
+//elevationChange - ascent and descent, a change only counts once it goes over the threshold from the last turning point
+func elevationChange(elevations []float64, threshold float64) (float64, float64) {
+	var ascent, descent float64
+	reference := elevations[0]
+	for _, elevation := range elevations[1:] {
+		switch {
+		case elevation-reference >= threshold:
+			ascent += elevation - reference
+			reference = elevation
+		case reference-elevation >= threshold:
+			descent += reference - elevation
+			reference = elevation
+		}
+	}
+	return ascent, descent
+}
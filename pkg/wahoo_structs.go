@@ -1,11 +1,16 @@
 package wahoo
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"mime/multipart"
 	"strconv"
 	"time"
+
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
 )
 
 var wahooDateString = "2006-01-02T15:04:05.000Z"
@@ -21,7 +26,7 @@ type Token struct {
 	CreatedAt    int    `json:"created_at"`
 }
 
-//User - the User Data
+//User - the User Data.  Height is in meters and weight in kilograms (see HeightLength and WeightMass)
 type User struct {
 	ID            int            `json:"id"`
 	Height        *float64       `json:"height"`
@@ -38,40 +43,6 @@ type User struct {
 	UpdatedAt     *time.Time     `json:"updated_at"`
 }
 
-/*
-convertUserToFormField - method that will take values from a user zone and convert them
-
-It will only convert the values that are able to be set on the PUT operations
-
-*/
-func (v *User) convertUserToFormField(writer *multipart.Writer) {
-
-	if v.Email != nil {
-		_ = writer.WriteField("user[email]", *v.Email)
-	}
-	if v.First != nil {
-		_ = writer.WriteField("user[first]", *v.First)
-	}
-	if v.Last != nil {
-		_ = writer.WriteField("user[last]", *v.Last)
-	}
-	if v.Mobile != nil {
-		_ = writer.WriteField("user[mobile]", *v.Mobile)
-	}
-	if v.Height != nil {
-		_ = writer.WriteField("user[last]", fmt.Sprintf("%f", *v.Height))
-	}
-	if v.Weight != nil {
-		_ = writer.WriteField("user[last]", fmt.Sprintf("%f", *v.Weight))
-	}
-	if v.Birth != nil {
-		_ = writer.WriteField("user[birth]", v.Birth.Format(wahooYearDateFormat))
-	}
-	if v.Gender != nil {
-		_ = writer.WriteField("user[gender]", strconv.Itoa(*v.Gender))
-	}
-}
-
 /*
 UnmarshalJSON - custom json unmarshaller because the API appears to use an explicit null (e.g. passes null instead of nothing)
 for empty values and the data types aren't correct (e.g. numbers coming across as strings)
@@ -256,37 +227,6 @@ type HeartRateZone struct {
 	Maximum   *int      `json:"maximum"`
 }
 
-/*
-convertHeartRateZonesToFormFields - method that will take values from a heartrate zone and convert them
-
-It will only convert the values that are able to be set on the PUT operations
-
-*/
-func (v *HeartRateZone) convertHeartRateZonesToFormFields(writer *multipart.Writer) {
-
-	if v.Zone1 != nil {
-		_ = writer.WriteField("heart_rate_zone[zone_1]", strconv.Itoa(*v.Zone1))
-	}
-	if v.Zone2 != nil {
-		_ = writer.WriteField("heart_rate_zone[zone_2]", strconv.Itoa(*v.Zone2))
-	}
-	if v.Zone3 != nil {
-		_ = writer.WriteField("heart_rate_zone[zone_3]", strconv.Itoa(*v.Zone3))
-	}
-	if v.Zone4 != nil {
-		_ = writer.WriteField("heart_rate_zone[zone_4]", strconv.Itoa(*v.Zone4))
-	}
-	if v.Zone5 != nil {
-		_ = writer.WriteField("heart_rate_zone[zone_5]", strconv.Itoa(*v.Zone5))
-	}
-	if v.Resting != nil {
-		_ = writer.WriteField("heart_rate_zone[resting]", strconv.Itoa(*v.Resting))
-	}
-	if v.Maximum != nil {
-		_ = writer.WriteField("heart_rate_zone[maximum]", strconv.Itoa(*v.Maximum))
-	}
-}
-
 //PowerZone - the power zone
 type PowerZone struct {
 	ID        int       `json:"id"`
@@ -303,41 +243,151 @@ type PowerZone struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+//Plan - a structured workout plan.  Workout.PlanID points at one of these
+type Plan struct {
+	ID                    int        `json:"id"`
+	UserID                int        `json:"user_id"`
+	Name                  *string    `json:"name"`
+	Description           *string    `json:"description"`
+	File                  *File      `json:"file"`
+	WorkoutTypeFamilyID   *int       `json:"workout_type_family_id"`
+	WorkoutTypeLocationID *int       `json:"workout_type_location_id"`
+	ExternalID            *string    `json:"external_id"`
+	ProviderUpdatedAt     *time.Time `json:"provider_updated_at"`
+	Deleted               *bool      `json:"deleted"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+	//PlanFile - the plan JSON document.  It is only sent on create/update, reads return File.URL instead
+	PlanFile []byte `json:"-"`
+	//FileName - the name the plan file is uploaded with (defaults to plan.json)
+	FileName *string `json:"-"`
+}
+
 /*
-convertHeartRateZonesToFormFields - method that will take values from a heartrate zone and convert them
+convertPlanToFormFields - method that will take values from a plan and convert them
 
-It will only convert the values that are able to be set on the PUT operations
+It will only convert the values that are able to be set on the POST/PUT operations.  The plan file is sent as a
+base64 data URI which is what the API expects.
+*/
+func (v *Plan) convertPlanToFormFields(writer *multipart.Writer) {
 
+	if len(v.PlanFile) > 0 {
+		_ = writer.WriteField("plan[file]", "data:application/json;base64,"+base64.StdEncoding.EncodeToString(v.PlanFile))
+		fileName := "plan.json"
+		if v.FileName != nil && *v.FileName != "" {
+			fileName = *v.FileName
+		}
+		_ = writer.WriteField("plan[filename]", fileName)
+	}
+	if v.Name != nil {
+		_ = writer.WriteField("plan[name]", *v.Name)
+	}
+	if v.Description != nil {
+		_ = writer.WriteField("plan[description]", *v.Description)
+	}
+	if v.ExternalID != nil {
+		_ = writer.WriteField("plan[external_id]", *v.ExternalID)
+	}
+	if v.ProviderUpdatedAt != nil && !v.ProviderUpdatedAt.IsZero() {
+		_ = writer.WriteField("plan[provider_updated_at]", v.ProviderUpdatedAt.UTC().Format(wahooDateString))
+	}
+}
+
+//Route - a route that can be sent to the user's devices
+type Route struct {
+	ID                  int        `json:"id"`
+	UserID              int        `json:"user_id"`
+	Name                *string    `json:"name"`
+	Description         *string    `json:"description"`
+	File                *File      `json:"file"`
+	WorkoutTypeFamilyID *int       `json:"workout_type_family_id"`
+	ExternalID          *string    `json:"external_id"`
+	ProviderUpdatedAt   *time.Time `json:"provider_updated_at"`
+	StartLat            *float64   `json:"start_lat"`
+	StartLng            *float64   `json:"start_lng"`
+	Distance            *float64   `json:"distance"`
+	Ascent              *float64   `json:"ascent"`
+	Descent             *float64   `json:"descent"`
+	Deleted             *bool      `json:"deleted"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	//RouteFile - the GPX or FIT route.  It is only sent on create/update, reads return File.URL instead
+	RouteFile []byte `json:"-"`
+	//FileName - the name the route file is uploaded with (defaults to route.gpx or route.fit)
+	FileName *string `json:"-"`
+	//fetched - the file metadata the route had when it was read from the API (nil for routes built by the caller)
+	fetched *routeFileMetadata
+}
+
+//ErrUnknownRouteFile - the route file is neither a GPX nor a FIT file
+var ErrUnknownRouteFile = errors.New("route file must be a GPX or FIT file")
+
+//routeFileType - works out the mime type and extension from the contents of the route file
+func routeFileType(data []byte) (string, string, error) {
+	if fit.IsFitFile(data) {
+		return "application/vnd.fit", ".fit", nil
+	}
+	start := data
+	if len(start) > 1024 {
+		start = start[:1024]
+	}
+	if bytes.Contains(start, []byte("<gpx")) {
+		return "application/gpx+xml", ".gpx", nil
+	}
+	return "", "", ErrUnknownRouteFile
+}
+
+/*
+convertRouteToFormFields - method that will take values from a route and convert them
+
+It will only convert the values that are able to be set on the POST/PUT operations.  Like plans the route file is
+sent as a base64 data URI with the mime type of the file.
 */
-func (v *PowerZone) convertPowerZonesToFormFields(writer *multipart.Writer) {
+func (v *Route) convertRouteToFormFields(writer *multipart.Writer) error {
 
-	if v.Zone1 != nil {
-		_ = writer.WriteField("power_zone[zone_1]", strconv.Itoa(*v.Zone1))
+	if len(v.RouteFile) > 0 {
+		mimeType, extension, err := routeFileType(v.RouteFile)
+		if err != nil {
+			return err
+		}
+		_ = writer.WriteField("route[file]", "data:"+mimeType+";base64,"+base64.StdEncoding.EncodeToString(v.RouteFile))
+		fileName := "route" + extension
+		if v.FileName != nil && *v.FileName != "" {
+			fileName = *v.FileName
+		}
+		_ = writer.WriteField("route[filename]", fileName)
+	}
+	if v.Name != nil {
+		_ = writer.WriteField("route[name]", *v.Name)
 	}
-	if v.Zone2 != nil {
-		_ = writer.WriteField("power_zone[zone_2]", strconv.Itoa(*v.Zone2))
+	if v.Description != nil {
+		_ = writer.WriteField("route[description]", *v.Description)
 	}
-	if v.Zone3 != nil {
-		_ = writer.WriteField("power_zone[zone_3]", strconv.Itoa(*v.Zone3))
+	if v.WorkoutTypeFamilyID != nil {
+		_ = writer.WriteField("route[workout_type_family_id]", strconv.Itoa(*v.WorkoutTypeFamilyID))
 	}
-	if v.Zone4 != nil {
-		_ = writer.WriteField("power_zone[zone_4]", strconv.Itoa(*v.Zone4))
+	if v.ExternalID != nil {
+		_ = writer.WriteField("route[external_id]", *v.ExternalID)
 	}
-	if v.Zone5 != nil {
-		_ = writer.WriteField("power_zone[zone_5]", strconv.Itoa(*v.Zone5))
+	if v.ProviderUpdatedAt != nil && !v.ProviderUpdatedAt.IsZero() {
+		_ = writer.WriteField("route[provider_updated_at]", v.ProviderUpdatedAt.UTC().Format(wahooDateString))
 	}
-	if v.Zone6 != nil {
-		_ = writer.WriteField("power_zone[zone_6]", strconv.Itoa(*v.Zone6))
+	if v.StartLat != nil {
+		_ = writer.WriteField("route[start_lat]", fmt.Sprintf("%f", *v.StartLat))
 	}
-	if v.Zone7 != nil {
-		_ = writer.WriteField("power_zone[zone_7]", strconv.Itoa(*v.Zone7))
+	if v.StartLng != nil {
+		_ = writer.WriteField("route[start_lng]", fmt.Sprintf("%f", *v.StartLng))
 	}
-	if v.Ftp != nil {
-		_ = writer.WriteField("power_zone[ftp]", strconv.Itoa(*v.Ftp))
+	if v.Distance != nil {
+		_ = writer.WriteField("route[distance]", fmt.Sprintf("%f", *v.Distance))
 	}
-	if v.ZoneCount != nil {
-		_ = writer.WriteField("power_zone[zone_count]", strconv.Itoa(*v.ZoneCount))
+	if v.Ascent != nil {
+		_ = writer.WriteField("route[ascent]", fmt.Sprintf("%f", *v.Ascent))
 	}
+	if v.Descent != nil {
+		_ = writer.WriteField("route[descent]", fmt.Sprintf("%f", *v.Descent))
+	}
+	return nil
 }
 
 //GetAllWorkoutsResponse - the response to get all the workouts response
@@ -364,106 +414,18 @@ type Workout struct {
 	WorkoutSummary *WorkoutSummary `json:"workout_summary"`
 }
 
-/*
-convertWorkoutToFormFields - method that will take values from a workout and convert them
-
-It will only convert the values that are able to be set on the PUT operations
-
-*/
-func (v *Workout) convertWorkoutToFormFields(writer *multipart.Writer) {
-
-	if v.Starts != nil && !v.Starts.IsZero() {
-		//Convert the time to string
-		value := v.Starts.Format(wahooDateString)
-		_ = writer.WriteField("workout[starts]", value)
-	}
-
-	// Minutes        int            `json:"minutes"`
-	if v.Minutes != nil {
-		_ = writer.WriteField("workout[minutes]", strconv.Itoa(*v.Minutes))
-	}
-
-	// Name           string         `json:"name"`
-	if v.Name != nil {
-		_ = writer.WriteField("workout[name]", *v.Name)
-	}
-
-	//PlanID         string         `json:"plan_id"`
-	if v.PlanID != nil {
-		_ = writer.WriteField("workout[plan_id]", *v.PlanID)
-
-	}
-	// WorkoutToken   string         `json:"workout_token"`
-	if v.WorkoutToken != nil {
-		_ = writer.WriteField("workout[workout_token]", *v.WorkoutToken)
-	}
-	// WorkoutTypeID  int            `json:"workout_type_id"`
-	if v.WorkoutTypeID != nil {
-		_ = writer.WriteField("workout[type_id]", strconv.Itoa(*v.WorkoutTypeID))
+//Type - the workout type.  False when WorkoutTypeID is not set.  Unknown IDs are returned as they are
+func (v *Workout) Type() (WorkoutType, bool) {
+	if v.WorkoutTypeID == nil {
+		return 0, false
 	}
+	return WorkoutType(*v.WorkoutTypeID), true
+}
 
-	// WorkoutSummary WorkoutSummary `json:"workout_summary"`
-	if v.WorkoutSummary != nil {
-		// HeartRateAvg        *float64  `json:"heart_rate_avg"`
-		if v.WorkoutSummary.HeartRateAvg != nil {
-			_ = writer.WriteField("workout[workout_summary][heart_rate_avg]", fmt.Sprintf("%f", *v.WorkoutSummary.HeartRateAvg))
-		}
-		// CaloriesAccum       *float64  `json:"calories_accum"`
-		if v.WorkoutSummary.CaloriesAccum != nil {
-			_ = writer.WriteField("workout[workout_summary][calories_accum]", fmt.Sprintf("%f", *v.WorkoutSummary.CaloriesAccum))
-		}
-		// PowerAvg            *float64  `json:"power_avg"`
-		if v.WorkoutSummary.PowerAvg != nil {
-			_ = writer.WriteField("workout[workout_summary][power_avg]", fmt.Sprintf("%f", *v.WorkoutSummary.PowerAvg))
-		}
-		// DistanceAccum       *float64  `json:"distance_accum"`
-		if v.WorkoutSummary.DistanceAccum != nil {
-			_ = writer.WriteField("workout[workout_summary][distance_accum]", fmt.Sprintf("%f", *v.WorkoutSummary.DistanceAccum))
-		}
-		// CadenceAvg          *float64  `json:"cadence_avg"`
-		if v.WorkoutSummary.CadenceAvg != nil {
-			_ = writer.WriteField("workout[workout_summary][cadence_avg]", fmt.Sprintf("%f", *v.WorkoutSummary.CadenceAvg))
-		}
-		// AscentAccum         *float64  `json:"ascent_accum"`
-		if v.WorkoutSummary.AscentAccum != nil {
-			_ = writer.WriteField("workout[workout_summary][ascent_accum]", fmt.Sprintf("%f", *v.WorkoutSummary.AscentAccum))
-		}
-		// DurationActiveAccum *float64  `json:"duration_active_accum"`
-		if v.WorkoutSummary.DurationActiveAccum != nil {
-			_ = writer.WriteField("workout[workout_summary][duration_active_accum]", fmt.Sprintf("%f", *v.WorkoutSummary.DurationActiveAccum))
-		}
-		// DurationPausedAccum *float64  `json:"duration_paused_accum"`
-		if v.WorkoutSummary.DurationPausedAccum != nil {
-			_ = writer.WriteField("workout[workout_summary][duration_paused_accum]", fmt.Sprintf("%f", *v.WorkoutSummary.DurationPausedAccum))
-		}
-		// DurationTotalAccum  *float64  `json:"duration_total_accum"`
-		if v.WorkoutSummary.DurationTotalAccum != nil {
-			_ = writer.WriteField("workout[workout_summary][duration_total_accum]", fmt.Sprintf("%f", *v.WorkoutSummary.DurationTotalAccum))
-		}
-		// PowerBikeNpLast     *float64  `json:"power_bike_np_last"`
-		if v.WorkoutSummary.PowerBikeNpLast != nil {
-			_ = writer.WriteField("workout[workout_summary][power_bike_np_last]", fmt.Sprintf("%f", *v.WorkoutSummary.PowerBikeNpLast))
-		}
-		// PowerBikeTssLast    *float64  `json:"power_bike_tss_last"`
-		if v.WorkoutSummary.PowerBikeTssLast != nil {
-			_ = writer.WriteField("workout[workout_summary][power_bike_tss_last]", fmt.Sprintf("%f", *v.WorkoutSummary.PowerBikeTssLast))
-		}
-		// SpeedAvg            *float64  `json:"speed_avg"`
-		if v.WorkoutSummary.SpeedAvg != nil {
-			_ = writer.WriteField("workout[workout_summary][speed_avg]", fmt.Sprintf("%f", *v.WorkoutSummary.SpeedAvg))
-		}
-		// WorkAccum           *float64  `json:"work_accum"`
-		if v.WorkoutSummary.WorkAccum != nil {
-			_ = writer.WriteField("workout[workout_summary][work_accum]", fmt.Sprintf("%f", *v.WorkoutSummary.WorkAccum))
-		}
-		// File                File      `json:"file"`
-		if v.WorkoutSummary.File != nil {
-			if v.WorkoutSummary.File.URL != "" {
-				_ = writer.WriteField("workout[workout_summary][file][url]", fmt.Sprintf("%f", *v.WorkoutSummary.WorkAccum))
-			}
-		}
-	}
-	return
+//SetType - sets WorkoutTypeID from the workout type
+func (v *Workout) SetType(workoutType WorkoutType) {
+	id := int(workoutType)
+	v.WorkoutTypeID = &id
 }
 
 //File - the location of the fit file
@@ -471,7 +433,12 @@ type File struct {
 	URL string `json:"url"`
 }
 
-//WorkoutSummary - the workoutSummary
+/*
+WorkoutSummary - the workoutSummary
+
+Distance and ascent are in meters, speed in m/s, work in joules, calories in kilocalories and durations in seconds.
+Distance, Ascent, Speed, Work and the Duration methods return them as typed quantities.
+*/
 type WorkoutSummary struct {
 	ID                  int       `json:"id"`
 	HeartRateAvg        *float64  `json:"heart_rate_avg"`
@@ -492,6 +459,32 @@ type WorkoutSummary struct {
 	File                *File     `json:"file"`
 }
 
+/*
+summaryFloat - a summary value, which the API sends as a string but is also accepted as a number.  Null, an empty string
+or a string that is not a number is nil so only that value is lost.  Any other type is an error
+*/
+func summaryFloat(field string, value interface{}) (*float64, error) {
+	switch typed := value.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		return &typed, nil
+	case json.Number:
+		floatValue, err := typed.Float64()
+		if err != nil {
+			return nil, nil
+		}
+		return &floatValue, nil
+	case string:
+		floatValue, err := strconv.ParseFloat(typed, 64)
+		if err != nil {
+			return nil, nil
+		}
+		return &floatValue, nil
+	}
+	return nil, fmt.Errorf("invalid workout summary %s: %v", field, value)
+}
+
 /*
 UnmarshalJSON - custom json unmarshaller because the API appears to use an explicit null (e.g. passes null instead of nothing)
 for empty values and the data types aren't correct (e.g. numbers coming across as strings)
@@ -503,6 +496,7 @@ func (v *WorkoutSummary) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	var err error
 	value, exists := mapToStoreValues["id"]
 	if exists && value != nil {
 		intValue, ok := value.(float64)
@@ -512,19 +506,15 @@ func (v *WorkoutSummary) UnmarshalJSON(data []byte) error {
 	}
 
 	//Heart Rate
-	value, exists = mapToStoreValues["heart_rate_avg"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.HeartRateAvg = &floatValue
-		}
+	v.HeartRateAvg, err = summaryFloat("heart_rate_avg", mapToStoreValues["heart_rate_avg"])
+	if err != nil {
+		return err
 	}
 
 	//Calories Accum
-	value, exists = mapToStoreValues["calories_accum"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.CaloriesAccum = &floatValue
-		}
+	v.CaloriesAccum, err = summaryFloat("calories_accum", mapToStoreValues["calories_accum"])
+	if err != nil {
+		return err
 	}
 
 	//Created At
@@ -558,91 +548,69 @@ func (v *WorkoutSummary) UnmarshalJSON(data []byte) error {
 	}
 
 	//Do the power average
-	value, exists = mapToStoreValues["power_avg"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.PowerAvg = &floatValue
-		}
+	v.PowerAvg, err = summaryFloat("power_avg", mapToStoreValues["power_avg"])
+	if err != nil {
+		return err
 	}
 
 	// DistanceAccum       string    `json:"distance_accum"`
-	value, exists = mapToStoreValues["distance_accum"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.DistanceAccum = &floatValue
-		}
+	v.DistanceAccum, err = summaryFloat("distance_accum", mapToStoreValues["distance_accum"])
+	if err != nil {
+		return err
 	}
 
 	// CadenceAvg          string    `json:"cadence_avg"`
-	value, exists = mapToStoreValues["cadence_avg"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.CadenceAvg = &floatValue
-		}
+	v.CadenceAvg, err = summaryFloat("cadence_avg", mapToStoreValues["cadence_avg"])
+	if err != nil {
+		return err
 	}
 
 	// AscentAccum         string    `json:"ascent_accum"`
-	value, exists = mapToStoreValues["ascent_accum"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.AscentAccum = &floatValue
-		}
+	v.AscentAccum, err = summaryFloat("ascent_accum", mapToStoreValues["ascent_accum"])
+	if err != nil {
+		return err
 	}
 
 	// DurationActiveAccum string    `json:"duration_active_accum"`
-	value, exists = mapToStoreValues["duration_active_accum"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.DurationActiveAccum = &floatValue
-		}
+	v.DurationActiveAccum, err = summaryFloat("duration_active_accum", mapToStoreValues["duration_active_accum"])
+	if err != nil {
+		return err
 	}
 
 	// DurationPausedAccum string    `json:"duration_paused_accum"`
-	value, exists = mapToStoreValues["duration_paused_accum"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.DurationPausedAccum = &floatValue
-		}
+	v.DurationPausedAccum, err = summaryFloat("duration_paused_accum", mapToStoreValues["duration_paused_accum"])
+	if err != nil {
+		return err
 	}
 
 	// DurationTotalAccum  string    `json:"duration_total_accum"`
-	value, exists = mapToStoreValues["duration_total_accum"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.DurationTotalAccum = &floatValue
-		}
+	v.DurationTotalAccum, err = summaryFloat("duration_total_accum", mapToStoreValues["duration_total_accum"])
+	if err != nil {
+		return err
 	}
 
 	// PowerBikeNpLast     int       `json:"power_bike_np_last"`
-	value, exists = mapToStoreValues["power_bike_np_last"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.PowerBikeNpLast = &floatValue
-		}
+	v.PowerBikeNpLast, err = summaryFloat("power_bike_np_last", mapToStoreValues["power_bike_np_last"])
+	if err != nil {
+		return err
 	}
 
 	// PowerBikeTssLast    float32   `json:"power_bike_tss_last"`
-	value, exists = mapToStoreValues["power_bike_tss_last"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.PowerBikeTssLast = &floatValue
-		}
+	v.PowerBikeTssLast, err = summaryFloat("power_bike_tss_last", mapToStoreValues["power_bike_tss_last"])
+	if err != nil {
+		return err
 	}
 
 	// SpeedAvg            float32   `json:"speed_avg"`
-	value, exists = mapToStoreValues["speed_avg"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.SpeedAvg = &floatValue
-		}
+	v.SpeedAvg, err = summaryFloat("speed_avg", mapToStoreValues["speed_avg"])
+	if err != nil {
+		return err
 	}
 
 	// WorkAccum           int       `json:"work_accum"`
-	value, exists = mapToStoreValues["work_accum"]
-	if exists && value != nil {
-		if floatValue, err := strconv.ParseFloat(value.(string), 64); err == nil {
-			v.WorkAccum = &floatValue
-		}
+	v.WorkAccum, err = summaryFloat("work_accum", mapToStoreValues["work_accum"])
+	if err != nil {
+		return err
 	}
 
 	// File                File      `json:"file"`
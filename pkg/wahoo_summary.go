@@ -0,0 +1,329 @@
+package wahoo
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
+)
+
+//Sample - a single point of a recorded workout.  Speed is m/s, distance (cumulative) and altitude are meters
+type Sample struct {
+	Time      time.Time
+	Power     *float64
+	HeartRate *float64
+	Cadence   *float64
+	Speed     *float64
+	Distance  *float64
+	Altitude  *float64
+	//Paused - the timer was stopped from this sample until the next one
+	Paused bool
+}
+
+//SummaryCalculator - works out the WorkoutSummary metrics from a series of samples
+type SummaryCalculator struct {
+	//PowerZone - the athlete's zones.  The Ftp is needed for TSS
+	PowerZone *PowerZone
+	//MaxSampleGap - a gap between samples longer than this is counted as paused time
+	MaxSampleGap time.Duration
+	//AscentThreshold - the altitude (meters) that has to be climbed before it counts.  Smooths out sensor noise
+	AscentThreshold float64
+}
+
+//Defaults used when the calculator values are not set
+const (
+	DefaultMaxSampleGap    = 5 * time.Second
+	DefaultAscentThreshold = 2.0
+)
+
+//joulesPerKilocalorie and grossEfficiency turn mechanical work into the calories burnt
+const (
+	joulesPerKilocalorie = 4184.0
+	grossEfficiency      = 0.24
+)
+
+//CalculateWorkoutSummary - calculates a summary with the default calculator settings
+func CalculateWorkoutSummary(samples []Sample, powerZone *PowerZone) (*WorkoutSummary, error) {
+	calculator := &SummaryCalculator{PowerZone: powerZone}
+	return calculator.Calculate(samples)
+}
+
+/*
+Calculate - derives the WorkoutSummary metrics from the samples
+
+Every sample holds its values until the next sample (so the last sample only marks the end).  Time between samples
+is active unless the sample is Paused or the gap is longer than MaxSampleGap.  Averages are weighted by active
+time, except cadence which also leaves out coasting (zero) values.  Fields that need data the samples do not have
+(e.g. power for TSS) are left nil.
+*/
+func (c *SummaryCalculator) Calculate(samples []Sample) (*WorkoutSummary, error) {
+	if len(samples) < 2 {
+		return nil, errors.New("At Least Two Samples Are Needed")
+	}
+	maxGap := c.MaxSampleGap
+	if maxGap <= 0 {
+		maxGap = DefaultMaxSampleGap
+	}
+	ascentThreshold := c.AscentThreshold
+	if ascentThreshold <= 0 {
+		ascentThreshold = DefaultAscentThreshold
+	}
+
+	var active, work, distance float64
+	power := &weightedAverage{}
+	heartRate := &weightedAverage{}
+	cadence := &weightedAverage{}
+	powerSeries := &secondSeries{}
+
+	for i := 0; i < len(samples)-1; i++ {
+		sample := samples[i]
+		gap := samples[i+1].Time.Sub(sample.Time)
+		if gap < 0 {
+			return nil, errors.New("Samples Must Be In Time Order")
+		}
+		seconds := gap.Seconds()
+		if sample.Paused || gap > maxGap {
+			continue
+		}
+		active += seconds
+
+		if sample.Power != nil {
+			power.add(*sample.Power, seconds)
+			work += *sample.Power * seconds
+			//Resampled to one value per active second for normalized power
+			powerSeries.add(*sample.Power, seconds)
+		}
+		if sample.HeartRate != nil {
+			heartRate.add(*sample.HeartRate, seconds)
+		}
+		if sample.Cadence != nil && *sample.Cadence > 0 {
+			cadence.add(*sample.Cadence, seconds)
+		}
+		//Only integrate speed when there is no distance sensor
+		if sample.Distance == nil && sample.Speed != nil {
+			distance += *sample.Speed * seconds
+		}
+	}
+
+	first := samples[0]
+	last := samples[len(samples)-1]
+	total := last.Time.Sub(first.Time).Seconds()
+	paused := total - active
+
+	summary := &WorkoutSummary{
+		DurationActiveAccum: &active,
+		DurationPausedAccum: &paused,
+		DurationTotalAccum:  &total,
+		HeartRateAvg:        heartRate.value(),
+		CadenceAvg:          cadence.value(),
+		PowerAvg:            power.value(),
+	}
+
+	if start, end := firstDistance(samples), lastDistance(samples); start != nil && end != nil {
+		distance = *end - *start
+	}
+	if distance > 0 || hasSpeedOrDistance(samples) {
+		summary.DistanceAccum = &distance
+		if active > 0 {
+			speed := distance / active
+			summary.SpeedAvg = &speed
+		}
+	}
+
+	if ascent, ok := calculateAscent(samples, ascentThreshold); ok {
+		summary.AscentAccum = &ascent
+	}
+
+	if summary.PowerAvg != nil {
+		summary.WorkAccum = &work
+		calories := work / joulesPerKilocalorie / grossEfficiency
+		summary.CaloriesAccum = &calories
+
+		if normalized, ok := normalizedPower(powerSeries.values); ok {
+			summary.PowerBikeNpLast = &normalized
+			if c.PowerZone != nil && c.PowerZone.Ftp != nil && *c.PowerZone.Ftp > 0 {
+				ftp := float64(*c.PowerZone.Ftp)
+				intensity := normalized / ftp
+				tss := active * normalized * intensity / (ftp * 3600) * 100
+				summary.PowerBikeTssLast = &tss
+			}
+		}
+	}
+
+	return summary, nil
+}
+
+/*
+secondSeries - resamples values held for any length of time to one value a second
+
+Each value is the time weighted average over that second, so 4Hz samples are averaged four at a time and a sample
+held for 5 seconds gives 5 values.  A last second that is not full is left out.
+*/
+type secondSeries struct {
+	values   []float64
+	binSum   float64
+	binSpent float64
+}
+
+func (v *secondSeries) add(value, seconds float64) {
+	for seconds > 0 {
+		take := math.Min(1-v.binSpent, seconds)
+		v.binSum += value * take
+		v.binSpent += take
+		seconds -= take
+		//Allow for floating point error in the fractions of a second
+		if v.binSpent >= 1-1e-9 {
+			v.values = append(v.values, v.binSum/v.binSpent)
+			v.binSum, v.binSpent = 0, 0
+		}
+	}
+}
+
+//weightedAverage - a time weighted average
+type weightedAverage struct {
+	sum    float64
+	weight float64
+}
+
+func (w *weightedAverage) add(value, weight float64) {
+	w.sum += value * weight
+	w.weight += weight
+}
+
+func (w *weightedAverage) value() *float64 {
+	if w.weight == 0 {
+		return nil
+	}
+	average := w.sum / w.weight
+	return &average
+}
+
+/*
+normalizedPower - 30 second rolling average, raised to the 4th power, averaged and then the 4th root
+
+The series has one value per active second.  Less than 30 seconds of power is not enough to calculate it.
+*/
+func normalizedPower(series []float64) (float64, bool) {
+	const window = 30
+	if len(series) < window {
+		return 0, false
+	}
+	var rolling, sumOfFourth float64
+	count := 0
+	for i, value := range series {
+		rolling += value
+		if i >= window {
+			rolling -= series[i-window]
+		}
+		if i >= window-1 {
+			sumOfFourth += math.Pow(rolling/window, 4)
+			count++
+		}
+	}
+	return math.Pow(sumOfFourth/float64(count), 0.25), true
+}
+
+//calculateAscent - climbing only counts once it goes over the threshold from the last low point
+func calculateAscent(samples []Sample, threshold float64) (float64, bool) {
+	var ascent float64
+	var reference *float64
+	for _, sample := range samples {
+		if sample.Altitude == nil {
+			continue
+		}
+		altitude := *sample.Altitude
+		if reference == nil {
+			reference = &altitude
+			continue
+		}
+		switch {
+		case altitude-*reference >= threshold:
+			ascent += altitude - *reference
+			reference = &altitude
+		case altitude < *reference:
+			reference = &altitude
+		}
+	}
+	return ascent, reference != nil
+}
+
+func firstDistance(samples []Sample) *float64 {
+	for _, sample := range samples {
+		if sample.Distance != nil {
+			return sample.Distance
+		}
+	}
+	return nil
+}
+
+func lastDistance(samples []Sample) *float64 {
+	for i := len(samples) - 1; i >= 0; i-- {
+		if samples[i].Distance != nil {
+			return samples[i].Distance
+		}
+	}
+	return nil
+}
+
+func hasSpeedOrDistance(samples []Sample) bool {
+	for _, sample := range samples {
+		if sample.Speed != nil || sample.Distance != nil {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+SamplesFromFitFile - converts the records of a decoded FIT file into samples
+
+Timer stop events mark the record before them as Paused so the time until the timer is started again is not active.
+*/
+func SamplesFromFitFile(file *fit.File) []Sample {
+	if file == nil {
+		return nil
+	}
+	var stops []time.Time
+	for _, event := range file.Events {
+		if event.Timestamp == nil || event.Event == nil || event.EventType == nil || *event.Event != fit.EventTimer {
+			continue
+		}
+		if *event.EventType == fit.EventTypeStop || *event.EventType == fit.EventTypeStopAll {
+			stops = append(stops, *event.Timestamp)
+		}
+	}
+
+	samples := make([]Sample, 0, len(file.Records))
+	for _, record := range file.Records {
+		if record.Timestamp == nil {
+			continue
+		}
+		samples = append(samples, Sample{
+			Time:      *record.Timestamp,
+			Power:     intToFloat(record.Power),
+			HeartRate: intToFloat(record.HeartRate),
+			Cadence:   intToFloat(record.Cadence),
+			Speed:     record.Speed,
+			Distance:  record.Distance,
+			Altitude:  record.Altitude,
+		})
+	}
+
+	for i := 0; i < len(samples)-1; i++ {
+		for _, stop := range stops {
+			if !stop.Before(samples[i].Time) && stop.Before(samples[i+1].Time) {
+				samples[i].Paused = true
+			}
+		}
+	}
+	return samples
+}
+
+func intToFloat(value *int) *float64 {
+	if value == nil {
+		return nil
+	}
+	floatValue := float64(*value)
+	return &floatValue
+}
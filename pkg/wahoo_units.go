@@ -0,0 +1,312 @@
+package wahoo
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//UnitSystem - how quantities are shown to a user
+type UnitSystem int
+
+//The unit systems
+const (
+	UnitSystemMetric UnitSystem = iota
+	UnitSystemImperial
+)
+
+//String - metric or imperial
+func (u UnitSystem) String() string {
+	if u == UnitSystemImperial {
+		return "imperial"
+	}
+	return "metric"
+}
+
+//ParseUnitSystem - the unit system from its name (metric or imperial, not case sensitive)
+func ParseUnitSystem(value string) (UnitSystem, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "metric":
+		return UnitSystemMetric, nil
+	case "imperial":
+		return UnitSystemImperial, nil
+	}
+	return UnitSystemMetric, errors.New("unknown unit system: " + value)
+}
+
+//MarshalText - the name of the unit system so a preference can be stored as text or JSON
+func (u UnitSystem) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+//UnmarshalText - reads a name with ParseUnitSystem
+func (u *UnitSystem) UnmarshalText(text []byte) error {
+	parsed, err := ParseUnitSystem(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+//Conversion factors
+const (
+	metersPerFoot     = 0.3048
+	metersPerInch     = 0.0254
+	metersPerMile     = 1609.344
+	kilogramsPerPound = 0.45359237
+)
+
+//Quantity - a value in a unit, ready to show
+type Quantity struct {
+	Value float64
+	Unit  string
+}
+
+//Format - the value with precision decimal places and the unit (e.g. 42.20 km)
+func (q Quantity) Format(precision int) string {
+	return strconv.FormatFloat(q.Value, 'f', precision, 64) + " " + q.Unit
+}
+
+//Length - a length in meters
+type Length float64
+
+//LengthFromKilometers - a Length from kilometers
+func LengthFromKilometers(kilometers float64) Length {
+	return Length(kilometers * 1000)
+}
+
+//LengthFromFeet - a Length from feet
+func LengthFromFeet(feet float64) Length {
+	return Length(feet * metersPerFoot)
+}
+
+//LengthFromInches - a Length from inches (e.g. LengthFromFeet(5) + LengthFromInches(11) for 5'11")
+func LengthFromInches(inches float64) Length {
+	return Length(inches * metersPerInch)
+}
+
+//LengthFromMiles - a Length from miles
+func LengthFromMiles(miles float64) Length {
+	return Length(miles * metersPerMile)
+}
+
+//Meters - the length in meters
+func (l Length) Meters() float64 {
+	return float64(l)
+}
+
+//Kilometers - the length in kilometers
+func (l Length) Kilometers() float64 {
+	return float64(l) / 1000
+}
+
+//Feet - the length in feet
+func (l Length) Feet() float64 {
+	return float64(l) / metersPerFoot
+}
+
+//Miles - the length in miles
+func (l Length) Miles() float64 {
+	return float64(l) / metersPerMile
+}
+
+//Short - the length in meters or feet, for heights and ascent
+func (l Length) Short(system UnitSystem) Quantity {
+	if system == UnitSystemImperial {
+		return Quantity{l.Feet(), "ft"}
+	}
+	return Quantity{l.Meters(), "m"}
+}
+
+//Long - the length in kilometers or miles, for distances
+func (l Length) Long(system UnitSystem) Quantity {
+	if system == UnitSystemImperial {
+		return Quantity{l.Miles(), "mi"}
+	}
+	return Quantity{l.Kilometers(), "km"}
+}
+
+//Mass - a mass in kilograms
+type Mass float64
+
+//MassFromPounds - a Mass from pounds
+func MassFromPounds(pounds float64) Mass {
+	return Mass(pounds * kilogramsPerPound)
+}
+
+//Kilograms - the mass in kilograms
+func (m Mass) Kilograms() float64 {
+	return float64(m)
+}
+
+//Pounds - the mass in pounds
+func (m Mass) Pounds() float64 {
+	return float64(m) / kilogramsPerPound
+}
+
+//In - the mass in kilograms or pounds
+func (m Mass) In(system UnitSystem) Quantity {
+	if system == UnitSystemImperial {
+		return Quantity{m.Pounds(), "lb"}
+	}
+	return Quantity{m.Kilograms(), "kg"}
+}
+
+//Speed - a speed in meters per second
+type Speed float64
+
+//SpeedFromKilometersPerHour - a Speed from km/h
+func SpeedFromKilometersPerHour(kilometersPerHour float64) Speed {
+	return Speed(kilometersPerHour / 3.6)
+}
+
+//SpeedFromMilesPerHour - a Speed from mph
+func SpeedFromMilesPerHour(milesPerHour float64) Speed {
+	return Speed(milesPerHour * metersPerMile / 3600)
+}
+
+//MetersPerSecond - the speed in m/s
+func (s Speed) MetersPerSecond() float64 {
+	return float64(s)
+}
+
+//KilometersPerHour - the speed in km/h
+func (s Speed) KilometersPerHour() float64 {
+	return float64(s) * 3.6
+}
+
+//MilesPerHour - the speed in mph
+func (s Speed) MilesPerHour() float64 {
+	return float64(s) * 3600 / metersPerMile
+}
+
+//In - the speed in km/h or mph
+func (s Speed) In(system UnitSystem) Quantity {
+	if system == UnitSystemImperial {
+		return Quantity{s.MilesPerHour(), "mph"}
+	}
+	return Quantity{s.KilometersPerHour(), "km/h"}
+}
+
+//Energy - an amount of work in joules
+type Energy float64
+
+//EnergyFromKilojoules - an Energy from kilojoules
+func EnergyFromKilojoules(kilojoules float64) Energy {
+	return Energy(kilojoules * 1000)
+}
+
+//Joules - the energy in joules
+func (e Energy) Joules() float64 {
+	return float64(e)
+}
+
+//Kilojoules - the energy in kilojoules
+func (e Energy) Kilojoules() float64 {
+	return float64(e) / 1000
+}
+
+//Quantity - the energy in kilojoules.  Work on a bike is shown in kJ whatever the unit system so there is no In
+func (e Energy) Quantity() Quantity {
+	return Quantity{e.Kilojoules(), "kJ"}
+}
+
+//lengthFromMeters, massFromKilograms, speedFromMetersPerSecond, energyFromJoules and durationFromSeconds - nil when not set
+func lengthFromMeters(value *float64) *Length {
+	if value == nil {
+		return nil
+	}
+	length := Length(*value)
+	return &length
+}
+
+func massFromKilograms(value *float64) *Mass {
+	if value == nil {
+		return nil
+	}
+	mass := Mass(*value)
+	return &mass
+}
+
+func speedFromMetersPerSecond(value *float64) *Speed {
+	if value == nil {
+		return nil
+	}
+	speed := Speed(*value)
+	return &speed
+}
+
+func energyFromJoules(value *float64) *Energy {
+	if value == nil {
+		return nil
+	}
+	energy := Energy(*value)
+	return &energy
+}
+
+func durationFromSeconds(value *float64) *time.Duration {
+	if value == nil {
+		return nil
+	}
+	duration := time.Duration(*value * float64(time.Second))
+	return &duration
+}
+
+//HeightLength - the height as a Length, nil when it is not set
+func (v *User) HeightLength() *Length {
+	return lengthFromMeters(v.Height)
+}
+
+//SetHeight - sets Height from a Length (e.g. LengthFromFeet(6) for 6 feet)
+func (v *User) SetHeight(height Length) {
+	meters := height.Meters()
+	v.Height = &meters
+}
+
+//WeightMass - the weight as a Mass, nil when it is not set
+func (v *User) WeightMass() *Mass {
+	return massFromKilograms(v.Weight)
+}
+
+//SetWeight - sets Weight from a Mass (e.g. MassFromPounds(154))
+func (v *User) SetWeight(weight Mass) {
+	kilograms := weight.Kilograms()
+	v.Weight = &kilograms
+}
+
+//Distance - DistanceAccum as a Length, nil when it is not set
+func (v *WorkoutSummary) Distance() *Length {
+	return lengthFromMeters(v.DistanceAccum)
+}
+
+//Ascent - AscentAccum as a Length, nil when it is not set
+func (v *WorkoutSummary) Ascent() *Length {
+	return lengthFromMeters(v.AscentAccum)
+}
+
+//Speed - SpeedAvg as a Speed, nil when it is not set
+func (v *WorkoutSummary) Speed() *Speed {
+	return speedFromMetersPerSecond(v.SpeedAvg)
+}
+
+//Work - WorkAccum as an Energy, nil when it is not set
+func (v *WorkoutSummary) Work() *Energy {
+	return energyFromJoules(v.WorkAccum)
+}
+
+//ActiveDuration - DurationActiveAccum as a time.Duration, nil when it is not set
+func (v *WorkoutSummary) ActiveDuration() *time.Duration {
+	return durationFromSeconds(v.DurationActiveAccum)
+}
+
+//PausedDuration - DurationPausedAccum as a time.Duration, nil when it is not set
+func (v *WorkoutSummary) PausedDuration() *time.Duration {
+	return durationFromSeconds(v.DurationPausedAccum)
+}
+
+//TotalDuration - DurationTotalAccum as a time.Duration, nil when it is not set
+func (v *WorkoutSummary) TotalDuration() *time.Duration {
+	return durationFromSeconds(v.DurationTotalAccum)
+}
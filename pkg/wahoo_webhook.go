@@ -0,0 +1,221 @@
+package wahoo
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+//WebhookEventType - the event_type of a webhook
+type WebhookEventType string
+
+//The webhook event types.  Wahoo currently only sends workout_summary
+const (
+	WebhookEventWorkoutSummary WebhookEventType = "workout_summary"
+	WebhookEventWorkout        WebhookEventType = "workout"
+	WebhookEventUser           WebhookEventType = "user"
+)
+
+//defaultMaxWebhookSize - webhooks are small, anything bigger than this is not from Wahoo
+const defaultMaxWebhookSize = 1 << 20
+
+/*
+WebhookEvent - a webhook sent by Wahoo
+
+Only the values in the payload are set.  For workout_summary events the workout the summary belongs to is in
+Workout with Workout.WorkoutSummary pointing at WorkoutSummary.
+*/
+type WebhookEvent struct {
+	EventType      WebhookEventType
+	WebhookToken   string
+	User           *User
+	Workout        *Workout
+	WorkoutSummary *WorkoutSummary
+	//Payload - the body as it was received
+	Payload []byte
+}
+
+//webhookPayload - the summary is decoded separately so the workout nested in it can be read too
+type webhookPayload struct {
+	EventType      WebhookEventType `json:"event_type"`
+	WebhookToken   string           `json:"webhook_token"`
+	User           *User            `json:"user"`
+	Workout        *Workout         `json:"workout"`
+	WorkoutSummary json.RawMessage  `json:"workout_summary"`
+}
+
+/*
+ParseWebhookEvent - converts a webhook body into a WebhookEvent
+
+The workout summary is decoded with the WorkoutSummary UnmarshalJSON so the values come out the same as from the
+API.  A summary value of the wrong type (e.g. an object where a number is expected) is returned as an error.
+*/
+func ParseWebhookEvent(data []byte) (*WebhookEvent, error) {
+	payload := &webhookPayload{}
+	if err := json.Unmarshal(data, payload); err != nil {
+		return nil, err
+	}
+	if payload.EventType == "" {
+		return nil, errors.New("invalid webhook payload: missing event_type")
+	}
+	event := &WebhookEvent{
+		EventType:    payload.EventType,
+		WebhookToken: payload.WebhookToken,
+		User:         payload.User,
+		Workout:      payload.Workout,
+		Payload:      data,
+	}
+
+	if len(payload.WorkoutSummary) > 0 && string(payload.WorkoutSummary) != "null" {
+		summary := &WorkoutSummary{}
+		if err := json.Unmarshal(payload.WorkoutSummary, summary); err != nil {
+			return nil, err
+		}
+		event.WorkoutSummary = summary
+
+		nested := struct {
+			Workout *Workout `json:"workout"`
+		}{}
+		if err := json.Unmarshal(payload.WorkoutSummary, &nested); err != nil {
+			return nil, err
+		}
+		if event.Workout == nil {
+			event.Workout = nested.Workout
+		}
+		if event.Workout != nil && event.Workout.WorkoutSummary == nil {
+			event.Workout.WorkoutSummary = summary
+		}
+	}
+	return event, nil
+}
+
+//VerifyWebhookToken - compares the tokens in constant time.  Both are hashed first so the length is not leaked either
+func VerifyWebhookToken(expected, received string) bool {
+	if expected == "" {
+		return false
+	}
+	expectedSum := sha256.Sum256([]byte(expected))
+	receivedSum := sha256.Sum256([]byte(received))
+	return subtle.ConstantTimeCompare(expectedSum[:], receivedSum[:]) == 1
+}
+
+//WebhookHandlerFunc - handles one webhook event.  Returning an error makes the receiver answer 500 so Wahoo sends it again
+type WebhookHandlerFunc func(ctx context.Context, event *WebhookEvent) error
+
+/*
+WebhookReceiver - an http.Handler for the Wahoo webhook
+
+Every request has its webhook_token checked against the token from the Wahoo developer portal before the rest of
+the payload is decoded, and is then dispatched to the handlers registered for its event type.  Events without a
+handler are acknowledged and dropped unless a default handler is set.
+*/
+type WebhookReceiver struct {
+	token           string
+	maxBodySize     int64
+	mutex           sync.RWMutex
+	handlers        map[WebhookEventType][]WebhookHandlerFunc
+	defaultHandlers []WebhookHandlerFunc
+}
+
+//ConstructWebhookReceiver - constructor for the webhook receiver.  webhookToken is the token set up with the webhook
+func ConstructWebhookReceiver(webhookToken string) (*WebhookReceiver, error) {
+	if webhookToken == "" {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	return &WebhookReceiver{
+		token:       webhookToken,
+		maxBodySize: defaultMaxWebhookSize,
+		handlers:    map[WebhookEventType][]WebhookHandlerFunc{},
+	}, nil
+}
+
+//SetMaxBodySize - the largest webhook body that is accepted
+func (v *WebhookReceiver) SetMaxBodySize(maxBodySize int64) {
+	if maxBodySize > 0 {
+		v.maxBodySize = maxBodySize
+	}
+}
+
+//Handle - registers a handler for an event type.  Handlers run in the order they were registered
+func (v *WebhookReceiver) Handle(eventType WebhookEventType, handler WebhookHandlerFunc) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.handlers[eventType] = append(v.handlers[eventType], handler)
+}
+
+//HandleDefault - registers a handler for the event types that do not have a handler of their own
+func (v *WebhookReceiver) HandleDefault(handler WebhookHandlerFunc) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.defaultHandlers = append(v.defaultHandlers, handler)
+}
+
+/*
+Dispatch - runs the handlers for the event, stopping at the first error
+
+It does not check the token, ServeHTTP does that before it dispatches.
+*/
+func (v *WebhookReceiver) Dispatch(ctx context.Context, event *WebhookEvent) error {
+	if event == nil {
+		return errors.New("Missing Mandatory Value")
+	}
+	v.mutex.RLock()
+	handlers, exists := v.handlers[event.EventType]
+	if !exists {
+		handlers = v.defaultHandlers
+	}
+	v.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ServeHTTP - reads, verifies and dispatches a webhook
+func (v *WebhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	event, status := v.readEvent(w, r)
+	if event == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if err := v.Dispatch(r.Context(), event); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+//readEvent - the verified event, or nil and the status to answer with
+func (v *WebhookReceiver) readEvent(w http.ResponseWriter, r *http.Request) (*WebhookEvent, int) {
+	if r.Method != http.MethodPost {
+		return nil, http.StatusMethodNotAllowed
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, v.maxBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, http.StatusRequestEntityTooLarge
+		}
+		return nil, http.StatusBadRequest
+	}
+	//Only the token is read until it has been verified, anything without a valid one is a 401
+	token := struct {
+		WebhookToken string `json:"webhook_token"`
+	}{}
+	if err := json.Unmarshal(body, &token); err != nil || !VerifyWebhookToken(v.token, token.WebhookToken) {
+		return nil, http.StatusUnauthorized
+	}
+	event, err := ParseWebhookEvent(body)
+	if err != nil {
+		return nil, http.StatusBadRequest
+	}
+	return event, http.StatusOK
+}
@@ -0,0 +1,181 @@
+package wahoo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//Defaults for the webhook processor
+const (
+	defaultWebhookWorkers      = 4
+	defaultWebhookMaxAttempts  = 5
+	defaultWebhookRetryWait    = 10 * time.Second
+	defaultWebhookPollInterval = time.Second
+)
+
+/*
+WebhookProcessor - acknowledges webhooks as soon as they are queued and runs the handlers in the background
+
+Use it as the http.Handler instead of the WebhookReceiver when the handlers are slow.  ServeHTTP checks the token,
+stores the webhook in the queue and answers 200 straight away (redeliveries that are already queued or were
+processed are dropped, see WebhookEvent.ID).  Run starts the workers which dispatch the webhooks to the receiver's
+handlers.  A handler error is retried with the wait doubling each time, and after the last attempt the webhook is
+passed to the queue's Fail.  An error while the context passed to Run is cancelled does not count as an attempt.
+*/
+type WebhookProcessor struct {
+	receiver     *WebhookReceiver
+	queue        WebhookQueue
+	workers      int
+	maxAttempts  int
+	retryWait    time.Duration
+	pollInterval time.Duration
+	wake         chan struct{}
+}
+
+//ConstructWebhookProcessor - constructor for the processor.  The handlers are the ones registered on the receiver
+func ConstructWebhookProcessor(receiver *WebhookReceiver, queue WebhookQueue) (*WebhookProcessor, error) {
+	if receiver == nil || queue == nil {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	return &WebhookProcessor{
+		receiver:     receiver,
+		queue:        queue,
+		workers:      defaultWebhookWorkers,
+		maxAttempts:  defaultWebhookMaxAttempts,
+		retryWait:    defaultWebhookRetryWait,
+		pollInterval: defaultWebhookPollInterval,
+		wake:         make(chan struct{}, 1),
+	}, nil
+}
+
+//SetWorkers - how many webhooks are processed at the same time.  Set it before calling Run
+func (v *WebhookProcessor) SetWorkers(workers int) {
+	if workers > 0 {
+		v.workers = workers
+	}
+}
+
+//SetRetryPolicy - how many times a webhook is tried and the wait before the first retry (it doubles after that)
+func (v *WebhookProcessor) SetRetryPolicy(maxAttempts int, wait time.Duration) {
+	if maxAttempts > 0 {
+		v.maxAttempts = maxAttempts
+	}
+	if wait >= 0 {
+		v.retryWait = wait
+	}
+}
+
+//SetPollInterval - how often idle workers check for retries that have become due
+func (v *WebhookProcessor) SetPollInterval(interval time.Duration) {
+	if interval > 0 {
+		v.pollInterval = interval
+	}
+}
+
+//ServeHTTP - verifies and queues a webhook.  Only a queue error makes Wahoo send it again
+func (v *WebhookProcessor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	event, status := v.receiver.readEvent(w, r)
+	if event == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if _, err := v.Enqueue(event); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+//Enqueue - queues an event that was received some other way.  It returns false when the event is a duplicate
+func (v *WebhookProcessor) Enqueue(event *WebhookEvent) (bool, error) {
+	if event == nil {
+		return false, errors.New("Missing Mandatory Value")
+	}
+	now := time.Now()
+	added, err := v.queue.Enqueue(&QueuedWebhook{
+		ID:          event.ID(),
+		Payload:     event.Payload,
+		ReceivedAt:  now,
+		NextAttempt: now,
+	})
+	if added {
+		select {
+		case v.wake <- struct{}{}:
+		default:
+		}
+	}
+	return added, err
+}
+
+//Run - processes webhooks until the context is cancelled.  It waits for the handlers that are running to return
+func (v *WebhookProcessor) Run(ctx context.Context) error {
+	if ctx == nil {
+		return errors.New("Missing Mandatory Value")
+	}
+	var group sync.WaitGroup
+	for i := 0; i < v.workers; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			v.work(ctx)
+		}()
+	}
+	group.Wait()
+	return ctx.Err()
+}
+
+//work - one worker.  It keeps going while there is something due and otherwise waits to be woken or for the poll
+func (v *WebhookProcessor) work(ctx context.Context) {
+	ticker := time.NewTicker(v.pollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			processed, err := v.processNext(ctx)
+			if err != nil || !processed {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-v.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+//processNext - runs the next due webhook.  It returns false when there was nothing to run
+func (v *WebhookProcessor) processNext(ctx context.Context) (bool, error) {
+	item, err := v.queue.Dequeue(time.Now())
+	if err != nil || item == nil {
+		return false, err
+	}
+	//Let another worker look at the queue in case there is more
+	select {
+	case v.wake <- struct{}{}:
+	default:
+	}
+
+	event, err := ParseWebhookEvent(item.Payload)
+	if err == nil {
+		err = v.receiver.Dispatch(ctx, event)
+	}
+	if err == nil {
+		return true, v.queue.Complete(item)
+	}
+	//A handler that stopped because of a shutdown has not used up an attempt, it runs again straight away next time
+	if ctx.Err() != nil && event != nil {
+		return true, v.queue.Retry(item)
+	}
+
+	item.Attempts++
+	item.LastError = err.Error()
+	if item.Attempts >= v.maxAttempts || event == nil {
+		return true, v.queue.Fail(item)
+	}
+	item.NextAttempt = time.Now().Add(v.retryWait << uint(item.Attempts-1))
+	return true, v.queue.Retry(item)
+}
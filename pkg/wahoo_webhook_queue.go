@@ -0,0 +1,522 @@
+package wahoo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DefaultWebhookDedupWindow - how long a processed event is remembered so a redelivery is dropped
+const DefaultWebhookDedupWindow = 7 * 24 * time.Hour
+
+/*
+ID - the key used to deduplicate the event
+
+Wahoo does not send an event ID so it is made from what the event is about: the workout summary ID and its
+UpdatedAt for workout_summary events (a summary that is updated again is a new event), the workout or user ID and
+UpdatedAt otherwise, and a hash of the payload when none of those are set.
+*/
+func (v *WebhookEvent) ID() string {
+	prefix := string(v.EventType) + ":"
+	switch {
+	case v.WorkoutSummary != nil && v.WorkoutSummary.ID != 0:
+		return prefix + "workout_summary:" + strconv.Itoa(v.WorkoutSummary.ID) + ":" + v.WorkoutSummary.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case v.Workout != nil && v.Workout.ID != 0:
+		return prefix + "workout:" + strconv.Itoa(v.Workout.ID) + ":" + v.Workout.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case v.User != nil && v.User.ID != 0 && v.User.UpdatedAt != nil:
+		return prefix + "user:" + strconv.Itoa(v.User.ID) + ":" + v.User.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	sum := sha256.Sum256(v.Payload)
+	return prefix + "payload:" + hex.EncodeToString(sum[:])
+}
+
+//QueuedWebhook - a webhook waiting to be processed.  The payload is kept as received and parsed again when it runs
+type QueuedWebhook struct {
+	ID          string    `json:"id"`
+	Payload     []byte    `json:"payload"`
+	ReceivedAt  time.Time `json:"received_at"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+/*
+WebhookQueue - where acknowledged webhooks wait to be processed
+
+Implementations have to be safe to use from several workers.  MemoryWebhookQueue and FileWebhookQueue are
+provided here and bbolt and SQLite queues in the boltqueue and sqlitequeue packages, anything else (another
+database, a message broker) can be plugged in by implementing this.
+*/
+type WebhookQueue interface {
+	//Enqueue - stores the webhook.  It returns false without storing it when the ID is pending or was completed within the dedup window
+	Enqueue(item *QueuedWebhook) (bool, error)
+	//Dequeue - takes the webhook that has been due the longest, nil when nothing is due.  It is not handed out again until Retry
+	Dequeue(now time.Time) (*QueuedWebhook, error)
+	//Retry - puts a dequeued webhook back to run again at its NextAttempt
+	Retry(item *QueuedWebhook) error
+	//Complete - removes a dequeued webhook and remembers its ID for the dedup window
+	Complete(item *QueuedWebhook) error
+	//Fail - removes a dequeued webhook that ran out of attempts
+	Fail(item *QueuedWebhook) error
+}
+
+//errNotDequeued - Retry, Complete and Fail are only for webhooks that were dequeued
+var errNotDequeued = errors.New("webhook was not dequeued")
+
+//nextDue - the pending webhook that has been due the longest that is not already being processed
+func nextDue(pending map[string]*QueuedWebhook, inFlight map[string]bool, now time.Time) *QueuedWebhook {
+	var next *QueuedWebhook
+	for id, item := range pending {
+		if inFlight[id] || item.NextAttempt.After(now) {
+			continue
+		}
+		if next == nil || item.NextAttempt.Before(next.NextAttempt) ||
+			(item.NextAttempt.Equal(next.NextAttempt) && item.ReceivedAt.Before(next.ReceivedAt)) {
+			next = item
+		}
+	}
+	return next
+}
+
+//MemoryWebhookQueue - a WebhookQueue that is lost when the process stops.  Fine for tests and for handlers that can miss events
+type MemoryWebhookQueue struct {
+	mutex       sync.Mutex
+	dedupWindow time.Duration
+	pending     map[string]*QueuedWebhook
+	inFlight    map[string]bool
+	completed   map[string]time.Time
+	failed      []*QueuedWebhook
+}
+
+//ConstructMemoryWebhookQueue - constructor for the in memory queue.  A dedupWindow of 0 uses DefaultWebhookDedupWindow
+func ConstructMemoryWebhookQueue(dedupWindow time.Duration) *MemoryWebhookQueue {
+	if dedupWindow <= 0 {
+		dedupWindow = DefaultWebhookDedupWindow
+	}
+	return &MemoryWebhookQueue{
+		dedupWindow: dedupWindow,
+		pending:     map[string]*QueuedWebhook{},
+		inFlight:    map[string]bool{},
+		completed:   map[string]time.Time{},
+	}
+}
+
+//Enqueue - see WebhookQueue
+func (v *MemoryWebhookQueue) Enqueue(item *QueuedWebhook) (bool, error) {
+	if item == nil || item.ID == "" {
+		return false, errors.New("Missing Mandatory Value")
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	now := time.Now()
+	for id, completedAt := range v.completed {
+		if now.Sub(completedAt) > v.dedupWindow {
+			delete(v.completed, id)
+		}
+	}
+	if _, exists := v.pending[item.ID]; exists {
+		return false, nil
+	}
+	if _, exists := v.completed[item.ID]; exists {
+		return false, nil
+	}
+	copied := *item
+	v.pending[item.ID] = &copied
+	return true, nil
+}
+
+//Dequeue - see WebhookQueue
+func (v *MemoryWebhookQueue) Dequeue(now time.Time) (*QueuedWebhook, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	next := nextDue(v.pending, v.inFlight, now)
+	if next == nil {
+		return nil, nil
+	}
+	v.inFlight[next.ID] = true
+	copied := *next
+	return &copied, nil
+}
+
+//Retry - see WebhookQueue
+func (v *MemoryWebhookQueue) Retry(item *QueuedWebhook) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if item == nil || !v.inFlight[item.ID] {
+		return errNotDequeued
+	}
+	copied := *item
+	v.pending[item.ID] = &copied
+	delete(v.inFlight, item.ID)
+	return nil
+}
+
+//Complete - see WebhookQueue
+func (v *MemoryWebhookQueue) Complete(item *QueuedWebhook) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if item == nil || !v.inFlight[item.ID] {
+		return errNotDequeued
+	}
+	delete(v.pending, item.ID)
+	delete(v.inFlight, item.ID)
+	v.completed[item.ID] = time.Now()
+	return nil
+}
+
+//Fail - see WebhookQueue.  The webhook is kept and returned by Failed
+func (v *MemoryWebhookQueue) Fail(item *QueuedWebhook) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if item == nil || !v.inFlight[item.ID] {
+		return errNotDequeued
+	}
+	delete(v.pending, item.ID)
+	delete(v.inFlight, item.ID)
+	copied := *item
+	v.failed = append(v.failed, &copied)
+	return nil
+}
+
+//Len - the number of webhooks waiting, including the ones being processed
+func (v *MemoryWebhookQueue) Len() int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return len(v.pending)
+}
+
+//Failed - the webhooks that ran out of attempts
+func (v *MemoryWebhookQueue) Failed() []*QueuedWebhook {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return append([]*QueuedWebhook{}, v.failed...)
+}
+
+/*
+FileWebhookQueue - a WebhookQueue kept in a directory so webhooks survive a restart
+
+Each webhook is a JSON file in pending/ named after a hash of its ID.  Completing one replaces it with an empty
+marker in completed/ (its modification time is when it completed) and failing one moves it to failed/.  Files are
+written to a temporary file, synced and renamed so a crash never leaves half a webhook.  Webhooks that were being
+processed when the process stopped are pending again when the queue is opened, unless they had already been
+completed or failed (a crash between writing the marker and removing the pending file).  The workers of one queue
+share a lock, but only one process should use a directory.
+
+The bbolt and SQLite queues are in the boltqueue and sqlitequeue packages, this one only needs the standard library.
+*/
+type FileWebhookQueue struct {
+	mutex       sync.Mutex
+	directory   string
+	dedupWindow time.Duration
+	lastPrune   time.Time
+	pending     map[string]*QueuedWebhook
+	inFlight    map[string]bool
+}
+
+//Subdirectories of the file queue
+const (
+	webhookPendingDirectory   = "pending"
+	webhookCompletedDirectory = "completed"
+	webhookFailedDirectory    = "failed"
+)
+
+//ConstructFileWebhookQueue - opens (or creates) the queue in directory.  A dedupWindow of 0 uses DefaultWebhookDedupWindow
+func ConstructFileWebhookQueue(directory string, dedupWindow time.Duration) (*FileWebhookQueue, error) {
+	if directory == "" {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	if dedupWindow <= 0 {
+		dedupWindow = DefaultWebhookDedupWindow
+	}
+	for _, subdirectory := range []string{webhookPendingDirectory, webhookCompletedDirectory, webhookFailedDirectory} {
+		if err := os.MkdirAll(filepath.Join(directory, subdirectory), 0700); err != nil {
+			return nil, err
+		}
+		if err := removeTemporaryFiles(filepath.Join(directory, subdirectory)); err != nil {
+			return nil, err
+		}
+	}
+	queue := &FileWebhookQueue{
+		directory:   directory,
+		dedupWindow: dedupWindow,
+		pending:     map[string]*QueuedWebhook{},
+		inFlight:    map[string]bool{},
+	}
+
+	//Load what was pending when the queue was last used
+	files, err := ioutil.ReadDir(filepath.Join(directory, webhookPendingDirectory))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		name := filepath.Join(directory, webhookPendingDirectory, file.Name())
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		item := &QueuedWebhook{}
+		if err := json.Unmarshal(data, item); err != nil {
+			return nil, err
+		}
+		//Finish a Complete or Fail that stopped before the pending file was removed
+		if queue.writtenSince(webhookCompletedDirectory, item.ID, "", file.ModTime()) ||
+			queue.writtenSince(webhookFailedDirectory, item.ID, ".json", file.ModTime()) {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			continue
+		}
+		queue.pending[item.ID] = item
+	}
+	return queue, nil
+}
+
+//removeTemporaryFiles - removes the temporary files of writes that never got renamed
+func removeTemporaryFiles(directory string) error {
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), ".tmp-") {
+			if err := os.Remove(filepath.Join(directory, file.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+writtenSince - true when the file for the ID is in the subdirectory and was written at or after pendingTime
+
+A marker older than the pending file is from an earlier delivery of the same ID (e.g. one that failed and was sent
+again) so it does not count.
+*/
+func (v *FileWebhookQueue) writtenSince(subdirectory, id, extension string, pendingTime time.Time) bool {
+	info, err := os.Stat(v.fileName(subdirectory, id, extension))
+	return err == nil && !info.ModTime().Before(pendingTime)
+}
+
+//fileName - IDs can have any characters in them so the file is named after a hash
+func (v *FileWebhookQueue) fileName(subdirectory, id, extension string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(v.directory, subdirectory, hex.EncodeToString(sum[:])+extension)
+}
+
+//writeFileAtomically - writes to a temporary file, syncs it and renames it over the real name
+func writeFileAtomically(name string, data []byte) error {
+	temporary, err := ioutil.TempFile(filepath.Dir(name), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	if _, err := temporary.Write(data); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Sync(); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary.Name(), name); err != nil {
+		return err
+	}
+	syncDirectory(filepath.Dir(name))
+	return nil
+}
+
+//removeFile - removes the file (it is fine if it has already gone) and syncs the directory
+func removeFile(name string) error {
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	syncDirectory(filepath.Dir(name))
+	return nil
+}
+
+/*
+syncDirectory - syncs a directory so a rename or remove in it survives a crash
+
+Not every platform can sync a directory (Windows can not) so this is best effort.
+*/
+func syncDirectory(name string) {
+	directory, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	_ = directory.Sync()
+	_ = directory.Close()
+}
+
+//pruneCompleted - removes the completed markers that are older than the dedup window.  Runs at most once a minute
+func (v *FileWebhookQueue) pruneCompleted(now time.Time) {
+	if now.Sub(v.lastPrune) < time.Minute {
+		return
+	}
+	v.lastPrune = now
+	directory := filepath.Join(v.directory, webhookCompletedDirectory)
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if now.Sub(file.ModTime()) > v.dedupWindow {
+			_ = os.Remove(filepath.Join(directory, file.Name()))
+		}
+	}
+}
+
+//Enqueue - see WebhookQueue.  The webhook is on disk before this returns
+func (v *FileWebhookQueue) Enqueue(item *QueuedWebhook) (bool, error) {
+	if item == nil || item.ID == "" {
+		return false, errors.New("Missing Mandatory Value")
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	now := time.Now()
+	v.pruneCompleted(now)
+	if _, exists := v.pending[item.ID]; exists {
+		return false, nil
+	}
+	if info, err := os.Stat(v.fileName(webhookCompletedDirectory, item.ID, "")); err == nil && now.Sub(info.ModTime()) <= v.dedupWindow {
+		return false, nil
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return false, err
+	}
+	if err := writeFileAtomically(v.fileName(webhookPendingDirectory, item.ID, ".json"), data); err != nil {
+		return false, err
+	}
+	copied := *item
+	v.pending[item.ID] = &copied
+	return true, nil
+}
+
+//Dequeue - see WebhookQueue
+func (v *FileWebhookQueue) Dequeue(now time.Time) (*QueuedWebhook, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	next := nextDue(v.pending, v.inFlight, now)
+	if next == nil {
+		return nil, nil
+	}
+	v.inFlight[next.ID] = true
+	copied := *next
+	return &copied, nil
+}
+
+//Retry - see WebhookQueue.  The attempts and next attempt are saved so they carry over a restart
+func (v *FileWebhookQueue) Retry(item *QueuedWebhook) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if item == nil || !v.inFlight[item.ID] {
+		return errNotDequeued
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomically(v.fileName(webhookPendingDirectory, item.ID, ".json"), data); err != nil {
+		return err
+	}
+	copied := *item
+	v.pending[item.ID] = &copied
+	delete(v.inFlight, item.ID)
+	return nil
+}
+
+//Complete - see WebhookQueue
+func (v *FileWebhookQueue) Complete(item *QueuedWebhook) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if item == nil || !v.inFlight[item.ID] {
+		return errNotDequeued
+	}
+	//Write the marker first, a crash before the pending file is removed is finished off when the queue is opened
+	if err := writeFileAtomically(v.fileName(webhookCompletedDirectory, item.ID, ""), nil); err != nil {
+		return err
+	}
+	if err := removeFile(v.fileName(webhookPendingDirectory, item.ID, ".json")); err != nil {
+		return err
+	}
+	delete(v.pending, item.ID)
+	delete(v.inFlight, item.ID)
+	return nil
+}
+
+//Fail - see WebhookQueue.  The webhook is kept in failed/ and returned by Failed
+func (v *FileWebhookQueue) Fail(item *QueuedWebhook) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if item == nil || !v.inFlight[item.ID] {
+		return errNotDequeued
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomically(v.fileName(webhookFailedDirectory, item.ID, ".json"), data); err != nil {
+		return err
+	}
+	if err := removeFile(v.fileName(webhookPendingDirectory, item.ID, ".json")); err != nil {
+		return err
+	}
+	delete(v.pending, item.ID)
+	delete(v.inFlight, item.ID)
+	return nil
+}
+
+//Len - the number of webhooks waiting, including the ones being processed
+func (v *FileWebhookQueue) Len() int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return len(v.pending)
+}
+
+//Failed - the webhooks that ran out of attempts, oldest first
+func (v *FileWebhookQueue) Failed() ([]*QueuedWebhook, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	directory := filepath.Join(v.directory, webhookFailedDirectory)
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	failed := []*QueuedWebhook{}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(directory, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		item := &QueuedWebhook{}
+		if err := json.Unmarshal(data, item); err != nil {
+			return nil, err
+		}
+		failed = append(failed, item)
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].ReceivedAt.Before(failed[j].ReceivedAt) })
+	return failed, nil
+}
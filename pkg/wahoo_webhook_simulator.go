@@ -0,0 +1,245 @@
+package wahoo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+BuildWorkoutSummaryWebhook - a workout_summary webhook body the way Wahoo sends it
+
+The summary values are written as strings and the times in the Wahoo format so the payload goes through
+ParseWebhookEvent (and the WorkoutSummary UnmarshalJSON) exactly like a real one.  workout is nested in the summary
+when it is set.
+*/
+func BuildWorkoutSummaryWebhook(webhookToken string, userID int, workout *Workout, summary *WorkoutSummary) ([]byte, error) {
+	if summary == nil && workout != nil {
+		summary = workout.WorkoutSummary
+	}
+	if summary == nil {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+
+	summaryValues := map[string]interface{}{
+		"id":         summary.ID,
+		"created_at": wahooTimeValue(&summary.CreatedAt),
+		"updated_at": wahooTimeValue(&summary.UpdatedAt),
+	}
+	for name, value := range map[string]*float64{
+		"heart_rate_avg":        summary.HeartRateAvg,
+		"calories_accum":        summary.CaloriesAccum,
+		"power_avg":             summary.PowerAvg,
+		"distance_accum":        summary.DistanceAccum,
+		"cadence_avg":           summary.CadenceAvg,
+		"ascent_accum":          summary.AscentAccum,
+		"duration_active_accum": summary.DurationActiveAccum,
+		"duration_paused_accum": summary.DurationPausedAccum,
+		"duration_total_accum":  summary.DurationTotalAccum,
+		"power_bike_np_last":    summary.PowerBikeNpLast,
+		"power_bike_tss_last":   summary.PowerBikeTssLast,
+		"speed_avg":             summary.SpeedAvg,
+		"work_accum":            summary.WorkAccum,
+	} {
+		if value != nil {
+			summaryValues[name] = strconv.FormatFloat(*value, 'f', -1, 64)
+		} else {
+			summaryValues[name] = nil
+		}
+	}
+	if summary.File != nil {
+		summaryValues["file"] = map[string]interface{}{"url": summary.File.URL}
+	}
+	if workout != nil {
+		summaryValues["workout"] = map[string]interface{}{
+			"id":              workout.ID,
+			"starts":          wahooTimeValue(workout.Starts),
+			"minutes":         workout.Minutes,
+			"name":            workout.Name,
+			"plan_id":         workout.PlanID,
+			"workout_token":   workout.WorkoutToken,
+			"workout_type_id": workout.WorkoutTypeID,
+			"created_at":      wahooTimeValue(&workout.CreatedAt),
+			"updated_at":      wahooTimeValue(&workout.UpdatedAt),
+		}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"event_type":      WebhookEventWorkoutSummary,
+		"webhook_token":   webhookToken,
+		"user":            map[string]interface{}{"id": userID},
+		"workout_summary": summaryValues,
+	})
+}
+
+//wahooTimeValue - the time in the Wahoo format, nil when it is not set
+func wahooTimeValue(value *time.Time) interface{} {
+	if value == nil || value.IsZero() {
+		return nil
+	}
+	return value.UTC().Format(wahooDateString)
+}
+
+//resignWebhook - replaces the webhook_token in a payload and leaves everything else as it was
+func resignWebhook(payload []byte, webhookToken string) ([]byte, error) {
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(payload, &values); err != nil {
+		return nil, err
+	}
+	token, err := json.Marshal(webhookToken)
+	if err != nil {
+		return nil, err
+	}
+	values["webhook_token"] = token
+	return json.Marshal(values)
+}
+
+/*
+WebhookSimulator - sends webhooks to a local endpoint so a consumer can be tested without a Wahoo account
+
+Payloads are signed with the simulator's webhook token, including replayed ones that were recorded with another token.
+*/
+type WebhookSimulator struct {
+	endpoint     string
+	webhookToken string
+	httpClient   *http.Client
+}
+
+//ConstructWebhookSimulator - constructor for the simulator.  endpoint is the full URL of the webhook handler
+func ConstructWebhookSimulator(endpoint, webhookToken string) (*WebhookSimulator, error) {
+	if endpoint == "" || webhookToken == "" {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	return &WebhookSimulator{
+		endpoint:     endpoint,
+		webhookToken: webhookToken,
+		httpClient:   &http.Client{},
+	}, nil
+}
+
+//SetHTTPClient - use your own http.Client (e.g. the client of an httptest.Server)
+func (v *WebhookSimulator) SetHTTPClient(httpClient *http.Client) {
+	if httpClient != nil {
+		v.httpClient = httpClient
+	}
+}
+
+//SendWorkoutSummary - builds a workout_summary webhook (see BuildWorkoutSummaryWebhook) and sends it
+func (v *WebhookSimulator) SendWorkoutSummary(ctx context.Context, userID int, workout *Workout, summary *WorkoutSummary) (int, error) {
+	payload, err := BuildWorkoutSummaryWebhook(v.webhookToken, userID, workout, summary)
+	if err != nil {
+		return 0, err
+	}
+	return v.Send(ctx, payload)
+}
+
+//Send - signs the payload with the simulator's token and POSTs it.  It returns the status code of the response
+func (v *WebhookSimulator) Send(ctx context.Context, payload []byte) (int, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	signed, err := resignWebhook(payload, v.webhookToken)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest("POST", v.endpoint, bytes.NewReader(signed))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	//Do the http request
+	res, err := v.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	return res.StatusCode, nil
+}
+
+/*
+Replay - sends the webhooks recorded by RecordWebhooks in the order they were received
+
+With keepTiming the gaps between the webhooks are kept, otherwise they are sent back to back.  It stops at the first
+error or response above 299 and returns how many were accepted.
+*/
+func (v *WebhookSimulator) Replay(ctx context.Context, recording io.Reader, keepTiming bool) (int, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	sent := 0
+	var previous time.Time
+	scanner := bufio.NewScanner(recording)
+	scanner.Buffer(make([]byte, 64*1024), defaultMaxWebhookSize*2)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		recorded := &RecordedWebhook{}
+		if err := json.Unmarshal(scanner.Bytes(), recorded); err != nil {
+			return sent, err
+		}
+		if keepTiming && !previous.IsZero() && recorded.ReceivedAt.After(previous) {
+			timer := time.NewTimer(recorded.ReceivedAt.Sub(previous))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return sent, ctx.Err()
+			case <-timer.C:
+			}
+		}
+		previous = recorded.ReceivedAt
+
+		status, err := v.Send(ctx, recorded.Payload)
+		if err != nil {
+			return sent, err
+		}
+		if status >= 300 {
+			return sent, constructWahooErrorFromResponse(status)
+		}
+		sent++
+	}
+	return sent, scanner.Err()
+}
+
+//RecordedWebhook - one line of a recording
+type RecordedWebhook struct {
+	ReceivedAt time.Time       `json:"received_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+/*
+RecordWebhooks - wraps a webhook handler and writes every JSON body it receives to recording, one per line
+
+Put it in front of the receiver in production (or staging) to capture real webhooks and replay them locally with
+WebhookSimulator.Replay.  Bodies that are not JSON are passed on but not recorded.  The recording holds the webhook
+tokens so keep it private.
+*/
+func RecordWebhooks(next http.Handler, recording io.Writer) http.Handler {
+	var mutex sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.Body != nil {
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, defaultMaxWebhookSize+1))
+			if err == nil && json.Valid(body) {
+				line, err := json.Marshal(RecordedWebhook{ReceivedAt: time.Now().UTC(), Payload: body})
+				if err == nil {
+					mutex.Lock()
+					_, _ = recording.Write(append(line, '\n'))
+					mutex.Unlock()
+				}
+			}
+			r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,373 @@
+package wahoo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+/*
+Zone boundaries
+
+Each ZoneN value is where zone N starts, so zone N runs from ZoneN up to (but not including) the next zone and the
+top zone runs up to the maximum.  Heart rates below Zone1 are below zone 1 (between resting and Zone1).
+*/
+
+//HeartRateZonePercentages - where zones 1 to 5 start as a fraction of max HR (percent of max) or heart rate reserve (Karvonen)
+var HeartRateZonePercentages = [5]float64{0.50, 0.60, 0.70, 0.80, 0.90}
+
+/*
+FrielHeartRateZonePercentages - where zones 1 to 5 start as a fraction of lactate threshold heart rate
+
+These are Joe Friel's cycling zones (2 at 81%, 3 at 90%, 4 at 94% and 5a at 100%) with 5a, 5b and 5c as zone 5.
+Friel's zone 1 has no floor so it starts at 65% of LTHR.
+*/
+var FrielHeartRateZonePercentages = [5]float64{0.65, 0.81, 0.90, 0.94, 1.00}
+
+//errInvalidHeartRates - the heart rates given to a calculator can not make zones
+var errInvalidHeartRates = errors.New("heart rates must be positive and resting must be below the maximum")
+
+/*
+CalculateHeartRateZonesFromMax - zones as a percentage of maximum heart rate (see HeartRateZonePercentages)
+
+resting is optional (pass 0), when it is set it is included so the zone can be sent as is.
+*/
+func CalculateHeartRateZonesFromMax(maximum, resting int) (*HeartRateZone, error) {
+	if maximum <= 0 || resting < 0 || resting >= maximum {
+		return nil, errInvalidHeartRates
+	}
+	zones := heartRateZonesFromPercentages(HeartRateZonePercentages, 0, float64(maximum))
+	zones.Maximum = &maximum
+	if resting > 0 {
+		zones.Resting = &resting
+	}
+	return zones, nil
+}
+
+//CalculateHeartRateZonesKarvonen - zones as a percentage of heart rate reserve (maximum - resting) added to resting
+func CalculateHeartRateZonesKarvonen(maximum, resting int) (*HeartRateZone, error) {
+	if maximum <= 0 || resting <= 0 || resting >= maximum {
+		return nil, errInvalidHeartRates
+	}
+	zones := heartRateZonesFromPercentages(HeartRateZonePercentages, float64(resting), float64(maximum-resting))
+	zones.Maximum = &maximum
+	zones.Resting = &resting
+	return zones, nil
+}
+
+/*
+CalculateHeartRateZonesFriel - zones from lactate threshold heart rate (see FrielHeartRateZonePercentages)
+
+resting and maximum are optional (pass 0) and are included when they are set.
+*/
+func CalculateHeartRateZonesFriel(lthr, resting, maximum int) (*HeartRateZone, error) {
+	if lthr <= 0 || resting < 0 || maximum < 0 || (maximum > 0 && maximum <= lthr) || resting >= lthr {
+		return nil, errInvalidHeartRates
+	}
+	zones := heartRateZonesFromPercentages(FrielHeartRateZonePercentages, 0, float64(lthr))
+	if resting > 0 {
+		zones.Resting = &resting
+	}
+	if maximum > 0 {
+		zones.Maximum = &maximum
+	}
+	return zones, nil
+}
+
+//heartRateZonesFromPercentages - offset + percentage * reference for each zone, rounded to the nearest beat
+func heartRateZonesFromPercentages(percentages [5]float64, offset, reference float64) *HeartRateZone {
+	bounds := make([]*int, len(percentages))
+	for i, percentage := range percentages {
+		bound := int(math.Round(offset + percentage*reference))
+		bounds[i] = &bound
+	}
+	return &HeartRateZone{Zone1: bounds[0], Zone2: bounds[1], Zone3: bounds[2], Zone4: bounds[3], Zone5: bounds[4]}
+}
+
+//PowerZoneModel - where each zone starts as a fraction of FTP.  It has one entry per zone, between 1 and 7
+type PowerZoneModel []float64
+
+//The Coggan power zones.  The 6 and 5 zone models are the 7 zone model with the top zones merged
+var (
+	PowerZonesCoggan7 = PowerZoneModel{0, 0.56, 0.76, 0.91, 1.06, 1.21, 1.51}
+	PowerZonesCoggan6 = PowerZoneModel{0, 0.56, 0.76, 0.91, 1.06, 1.21}
+	PowerZonesCoggan5 = PowerZoneModel{0, 0.56, 0.76, 0.91, 1.06}
+)
+
+/*
+CalculatePowerZones - power zones from FTP using a model (one of the Coggan models or your own percentages)
+
+ZoneCount is set to the number of zones in the model and the zones above it are left empty.
+*/
+func CalculatePowerZones(ftp int, model PowerZoneModel) (*PowerZone, error) {
+	if ftp <= 0 {
+		return nil, errors.New("ftp must be positive")
+	}
+	if len(model) == 0 || len(model) > 7 {
+		return nil, errors.New("a power zone model needs between 1 and 7 zones")
+	}
+	bounds := make([]*int, 7)
+	for i, percentage := range model {
+		if percentage < 0 || (i > 0 && percentage <= model[i-1]) {
+			return nil, errors.New("power zone percentages must be positive and ascending")
+		}
+		bound := int(math.Round(percentage * float64(ftp)))
+		bounds[i] = &bound
+	}
+	zoneCount := len(model)
+	return &PowerZone{
+		Zone1:     bounds[0],
+		Zone2:     bounds[1],
+		Zone3:     bounds[2],
+		Zone4:     bounds[3],
+		Zone5:     bounds[4],
+		Zone6:     bounds[5],
+		Zone7:     bounds[6],
+		Ftp:       &ftp,
+		ZoneCount: &zoneCount,
+	}, nil
+}
+
+//ZoneFieldError - a problem with one field of a zone.  Field is the API name of the field (e.g. zone_2)
+type ZoneFieldError struct {
+	Field   string
+	Problem string
+}
+
+func (e *ZoneFieldError) Error() string {
+	return e.Field + ": " + e.Problem
+}
+
+//ZoneValidationError - everything wrong with a HeartRateZone or PowerZone
+type ZoneValidationError struct {
+	Fields []*ZoneFieldError
+}
+
+func (e *ZoneValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		problems[i] = field.Error()
+	}
+	return "invalid zones: " + strings.Join(problems, "; ")
+}
+
+//Field - the problem with a field, nil when the field is fine
+func (e *ZoneValidationError) Field(name string) *ZoneFieldError {
+	for _, field := range e.Fields {
+		if field.Field == name {
+			return field
+		}
+	}
+	return nil
+}
+
+func (e *ZoneValidationError) add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, &ZoneFieldError{Field: field, Problem: fmt.Sprintf(format, args...)})
+}
+
+func (e *ZoneValidationError) errorOrNil() error {
+	if len(e.Fields) > 0 {
+		return e
+	}
+	return nil
+}
+
+//zoneValue - a named zone field so the checks can walk them in order
+type zoneValue struct {
+	field string
+	value *int
+}
+
+//checkAscending - every field that is set has to be above the last one that was set before it
+func (e *ZoneValidationError) checkAscending(values []zoneValue) {
+	var previous *zoneValue
+	for i := range values {
+		if values[i].value == nil {
+			continue
+		}
+		if previous != nil && *values[i].value <= *previous.value {
+			e.add(values[i].field, "%d must be above %s (%d)", *values[i].value, previous.field, *previous.value)
+		}
+		previous = &values[i]
+	}
+}
+
+/*
+Validate - checks the zones before they are sent with UpdateHeartRateZone
+
+Only the fields that are set are checked, so a partial update is fine.  Every value has to be positive and the ones
+that are set have to go up: resting < zone_1 < zone_2 < ... < zone_5 < maximum.  The error is a *ZoneValidationError.
+*/
+func (v *HeartRateZone) Validate() error {
+	problems := &ZoneValidationError{}
+	values := []zoneValue{
+		{"resting", v.Resting},
+		{"zone_1", v.Zone1},
+		{"zone_2", v.Zone2},
+		{"zone_3", v.Zone3},
+		{"zone_4", v.Zone4},
+		{"zone_5", v.Zone5},
+		{"maximum", v.Maximum},
+	}
+	for _, value := range values {
+		if value.value != nil && *value.value <= 0 {
+			problems.add(value.field, "%d must be positive", *value.value)
+		}
+	}
+	problems.checkAscending(values)
+	return problems.errorOrNil()
+}
+
+/*
+Validate - checks the zones before they are sent with UpdatePowerZones
+
+Only the fields that are set are checked, so a partial update is fine.  Zones can not be negative, the ones that are
+set have to go up and ftp has to be positive.  When zone_count is set it has to be between 1 and 7, no zone above it
+can be set and, if any zones are being sent, every zone up to it has to be.  The error is a *ZoneValidationError.
+*/
+func (v *PowerZone) Validate() error {
+	problems := &ZoneValidationError{}
+	zones := []zoneValue{
+		{"zone_1", v.Zone1},
+		{"zone_2", v.Zone2},
+		{"zone_3", v.Zone3},
+		{"zone_4", v.Zone4},
+		{"zone_5", v.Zone5},
+		{"zone_6", v.Zone6},
+		{"zone_7", v.Zone7},
+	}
+	anySet := false
+	for _, zone := range zones {
+		if zone.value == nil {
+			continue
+		}
+		anySet = true
+		if *zone.value < 0 {
+			problems.add(zone.field, "%d can not be negative", *zone.value)
+		}
+	}
+	problems.checkAscending(zones)
+	if v.Ftp != nil && *v.Ftp <= 0 {
+		problems.add("ftp", "%d must be positive", *v.Ftp)
+	}
+
+	if v.ZoneCount != nil {
+		if *v.ZoneCount < 1 || *v.ZoneCount > len(zones) {
+			problems.add("zone_count", "%d must be between 1 and %d", *v.ZoneCount, len(zones))
+		} else {
+			for i, zone := range zones {
+				if i >= *v.ZoneCount && zone.value != nil {
+					problems.add(zone.field, "is set but zone_count is %d", *v.ZoneCount)
+				} else if i < *v.ZoneCount && zone.value == nil && anySet {
+					problems.add(zone.field, "is missing but zone_count is %d", *v.ZoneCount)
+				}
+			}
+		}
+	}
+	return problems.errorOrNil()
+}
+
+//zoneFor - the highest zone whose start the value has reached, 0 when it is below every zone that is set
+func zoneFor(value float64, bounds []*int) int {
+	zone := 0
+	for i, bound := range bounds {
+		if bound != nil && value >= float64(*bound) {
+			zone = i + 1
+		}
+	}
+	return zone
+}
+
+//heartRateBounds - where each heart rate zone starts
+func (v *HeartRateZone) heartRateBounds() []*int {
+	return []*int{v.Zone1, v.Zone2, v.Zone3, v.Zone4, v.Zone5}
+}
+
+//powerBounds - where each power zone starts.  Only the zones up to ZoneCount (when it is set) are included
+func (v *PowerZone) powerBounds() []*int {
+	bounds := []*int{v.Zone1, v.Zone2, v.Zone3, v.Zone4, v.Zone5, v.Zone6, v.Zone7}
+	if v.ZoneCount != nil && *v.ZoneCount >= 0 && *v.ZoneCount < len(bounds) {
+		bounds = bounds[:*v.ZoneCount]
+	}
+	//Drop the empty zones from the top so the count is the highest zone that is set
+	for len(bounds) > 0 && bounds[len(bounds)-1] == nil {
+		bounds = bounds[:len(bounds)-1]
+	}
+	return bounds
+}
+
+//Zone - the zone (1 to 5) a heart rate is in, 0 when it is below zone 1
+func (v *HeartRateZone) Zone(heartRate float64) int {
+	return zoneFor(heartRate, v.heartRateBounds())
+}
+
+//Zone - the zone (1 to ZoneCount) a power is in, 0 when it is below zone 1
+func (v *PowerZone) Zone(power float64) int {
+	return zoneFor(power, v.powerBounds())
+}
+
+//TimeInZones - how long a workout spent in each zone
+type TimeInZones struct {
+	//Zones - index 0 is the time below zone 1 and index N is the time in zone N
+	Zones []time.Duration
+	//NoData - active time without a value (e.g. the heart rate strap dropped out)
+	NoData time.Duration
+}
+
+//Total - the active time with a value, which is what the zone percentages are a share of
+func (t *TimeInZones) Total() time.Duration {
+	var total time.Duration
+	for _, duration := range t.Zones {
+		total += duration
+	}
+	return total
+}
+
+//Percent - the share (0 to 100) of Total spent in a zone
+func (t *TimeInZones) Percent(zone int) float64 {
+	total := t.Total()
+	if zone < 0 || zone >= len(t.Zones) || total == 0 {
+		return 0
+	}
+	return float64(t.Zones[zone]) / float64(total) * 100
+}
+
+/*
+TimeInZones - the time spent in each heart rate zone over a series of samples (e.g. from SamplesFromFitFile)
+
+Time is counted the same way as SummaryCalculator.Calculate with the default settings: every sample holds its value
+until the next one and paused time or gaps longer than DefaultMaxSampleGap are left out.
+*/
+func (v *HeartRateZone) TimeInZones(samples []Sample) (*TimeInZones, error) {
+	return timeInZones(samples, v.heartRateBounds(), func(sample Sample) *float64 { return sample.HeartRate })
+}
+
+//TimeInZones - the time spent in each power zone over a series of samples (see HeartRateZone.TimeInZones)
+func (v *PowerZone) TimeInZones(samples []Sample) (*TimeInZones, error) {
+	return timeInZones(samples, v.powerBounds(), func(sample Sample) *float64 { return sample.Power })
+}
+
+func timeInZones(samples []Sample, bounds []*int, value func(Sample) *float64) (*TimeInZones, error) {
+	if len(bounds) == 0 {
+		return nil, errors.New("Missing Mandatory Value")
+	}
+	result := &TimeInZones{Zones: make([]time.Duration, len(bounds)+1)}
+	for i := 0; i < len(samples)-1; i++ {
+		sample := samples[i]
+		gap := samples[i+1].Time.Sub(sample.Time)
+		if gap < 0 {
+			return nil, errors.New("Samples Must Be In Time Order")
+		}
+		if sample.Paused || gap > DefaultMaxSampleGap {
+			continue
+		}
+		if sampleValue := value(sample); sampleValue != nil {
+			result.Zones[zoneFor(*sampleValue, bounds)] += gap
+		} else {
+			result.NoData += gap
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package wahoo
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/boltqueue"
+)
+
+func TestBoltWebhookQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.db")
+	queue, err := boltqueue.Open(path, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	testQueueSemantics(t, queue)
+	failed, err := queue.Failed()
+	if err != nil || len(failed) != 1 || failed[0].ID != "b" {
+		t.Error("Expected b to have failed")
+	}
+
+	//A webhook that was being processed when the process stopped is pending again after a restart
+	now := time.Now()
+	_, _ = queue.Enqueue(&wahoo.QueuedWebhook{ID: "c", Payload: []byte(`{"x":1}`), ReceivedAt: now, NextAttempt: now})
+	if dequeued, _ := queue.Dequeue(now); dequeued == nil {
+		t.Fatal("Expected c")
+	}
+	if err := queue.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	reopened, err := boltqueue.Open(path, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer reopened.Close()
+	if count, err := reopened.Len(); err != nil || count != 1 {
+		t.Fatalf("Expected c to be pending, got %d", count)
+	}
+	restored, _ := reopened.Dequeue(now)
+	if restored == nil || restored.ID != "c" || string(restored.Payload) != `{"x":1}` {
+		t.Fatal("Expected c to be restored")
+	}
+	//The completed webhook from before the restart is still deduplicated
+	if added, _ := reopened.Enqueue(&wahoo.QueuedWebhook{ID: "a"}); added {
+		t.Error("Expected the dedup to survive a restart")
+	}
+}
@@ -0,0 +1,91 @@
+package wahoo
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/export"
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+//compareGolden - compares the output to testdata/name (or rewrites it with -update)
+func compareGolden(t *testing.T, name string, output []byte) {
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := ioutil.WriteFile(path, output, 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(expected, output) {
+		t.Errorf("%s does not match the golden file.  Got:\n%s", name, output)
+	}
+}
+
+//exportTestWorkout - a short outdoor ride with a pause half way through
+func exportTestWorkout(t *testing.T) (*wahoo.Workout, *fit.File) {
+	file, err := fit.DecodeBytes(buildTestWorkoutFitFile(6))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	name := "Lunch Ride"
+	workoutType := int(wahoo.BikingRoad)
+	workout := &wahoo.Workout{ID: 1, Name: &name, Starts: &testWorkoutStart, WorkoutTypeID: &workoutType}
+	return workout, file
+}
+
+func TestExportGPX_Golden(t *testing.T) {
+	workout, file := exportTestWorkout(t)
+	buffer := &bytes.Buffer{}
+	if err := export.WriteGPX(buffer, workout, file); err != nil {
+		t.Fatal(err.Error())
+	}
+	compareGolden(t, "workout.gpx", buffer.Bytes())
+}
+
+func TestExportTCX_Golden(t *testing.T) {
+	workout, file := exportTestWorkout(t)
+	buffer := &bytes.Buffer{}
+	if err := export.WriteTCX(buffer, workout, file); err != nil {
+		t.Fatal(err.Error())
+	}
+	compareGolden(t, "workout.tcx", buffer.Bytes())
+}
+
+func TestExportTCX_IndoorFromSummary(t *testing.T) {
+	//An indoor ride has no positions or laps so the lap comes from the summary
+	file := &fit.File{}
+	duration := 3600.0
+	power := 201.6
+	workoutType := int(wahoo.BikingIndoorTrainer)
+	workout := &wahoo.Workout{
+		Starts:         &testWorkoutStart,
+		WorkoutTypeID:  &workoutType,
+		WorkoutSummary: &wahoo.WorkoutSummary{DurationActiveAccum: &duration, PowerAvg: &power},
+	}
+	buffer := &bytes.Buffer{}
+	if err := export.WriteTCX(buffer, workout, file); err != nil {
+		t.Fatal(err.Error())
+	}
+	output := buffer.String()
+	if !bytes.Contains([]byte(output), []byte("<TotalTimeSeconds>3600.0</TotalTimeSeconds>")) || !bytes.Contains([]byte(output), []byte("<ns3:AvgWatts>202</ns3:AvgWatts>")) {
+		t.Error("Expected the lap totals from the summary.  Got:\n" + output)
+	}
+
+	buffer.Reset()
+	if err := export.WriteGPX(buffer, workout, file); err != nil {
+		t.Fatal(err.Error())
+	}
+	if bytes.Contains(buffer.Bytes(), []byte("<trkseg>")) {
+		t.Error("Expected no track segments without positions")
+	}
+}
@@ -0,0 +1,261 @@
+package wahoo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
+)
+
+//fitBuilder - writes small FIT files so the decoder can be tested without fixtures from a device
+type fitBuilder struct {
+	data bytes.Buffer
+}
+
+//define - writes a definition message.  Each field is {field number, size, base type}
+func (b *fitBuilder) define(local uint8, global uint16, fields [][3]byte, devFields [][3]byte) {
+	header := byte(0x40) | local
+	if len(devFields) > 0 {
+		header |= 0x20
+	}
+	b.data.WriteByte(header)
+	b.data.Write([]byte{0, 0})
+	_ = binary.Write(&b.data, binary.LittleEndian, global)
+	b.data.WriteByte(byte(len(fields)))
+	for _, field := range fields {
+		b.data.Write(field[:])
+	}
+	if len(devFields) > 0 {
+		b.data.WriteByte(byte(len(devFields)))
+		for _, field := range devFields {
+			b.data.Write(field[:])
+		}
+	}
+}
+
+//write - writes a data message with a normal header
+func (b *fitBuilder) write(local uint8, values ...interface{}) {
+	b.data.WriteByte(local)
+	b.values(values...)
+}
+
+//writeCompressed - writes a data message with a compressed timestamp header
+func (b *fitBuilder) writeCompressed(local uint8, offset uint8, values ...interface{}) {
+	b.data.WriteByte(0x80 | (local&0x03)<<5 | offset&0x1F)
+	b.values(values...)
+}
+
+func (b *fitBuilder) values(values ...interface{}) {
+	for _, value := range values {
+		if raw, ok := value.([]byte); ok {
+			b.data.Write(raw)
+			continue
+		}
+		_ = binary.Write(&b.data, binary.LittleEndian, value)
+	}
+}
+
+//bytes - the finished file with a 14 byte header and both crcs
+func (b *fitBuilder) bytes() []byte {
+	header := make([]byte, 14)
+	header[0] = 14
+	header[1] = 0x20
+	binary.LittleEndian.PutUint16(header[2:4], 2132)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(b.data.Len()))
+	copy(header[8:12], ".FIT")
+	binary.LittleEndian.PutUint16(header[12:14], fit.CRC16(header[:12]))
+
+	file := append(header, b.data.Bytes()...)
+	crc := make([]byte, 2)
+	binary.LittleEndian.PutUint16(crc, fit.CRC16(file))
+	return append(file, crc...)
+}
+
+//testWorkoutStart - the start of the generated test workout
+var testWorkoutStart = time.Date(2020, time.June, 1, 7, 30, 0, 0, time.UTC)
+
+//testWorkoutPower - power for each second of the generated test workout
+func testWorkoutPower(second int) uint16 {
+	if second%60 < 30 {
+		return 300
+	}
+	return 150
+}
+
+/*
+buildTestWorkoutFitFile - a ride of the given length with one record per second
+
+Power alternates 300W/150W every 30 seconds, heart rate climbs from 120, cadence is 90, speed is 10 m/s and
+the position moves north from 40,-105 while climbing 0.1m per second.  There is a 10 second pause in the middle
+and a device_info message plus a developer field to make sure unknown data is skipped.
+*/
+func buildTestWorkoutFitFile(seconds int) []byte {
+	builder := &fitBuilder{}
+	start := fit.FromTime(testWorkoutStart)
+
+	//file_id
+	builder.define(0, 0, [][3]byte{{0, 1, 0x00}, {1, 2, 0x84}, {2, 2, 0x84}, {3, 4, 0x8C}, {4, 4, 0x86}}, nil)
+	builder.write(0, uint8(4), uint16(32), uint16(31), uint32(12345), start)
+
+	//device_info - not decoded
+	builder.define(1, 23, [][3]byte{{fieldTimestampNum, 4, 0x86}, {2, 2, 0x84}}, nil)
+	builder.write(1, start, uint16(32))
+
+	//event
+	builder.define(2, 21, [][3]byte{{fieldTimestampNum, 4, 0x86}, {0, 1, 0x00}, {1, 1, 0x00}}, nil)
+	builder.write(2, start, uint8(fit.EventTimer), uint8(fit.EventTypeStart))
+
+	//record with a developer field on the end.  Local 3 has no timestamp so it can be used with compressed headers
+	recordFields := [][3]byte{
+		{0, 4, 0x85},
+		{1, 4, 0x85},
+		{2, 2, 0x84},
+		{3, 1, 0x02},
+		{4, 1, 0x02},
+		{5, 4, 0x86},
+		{6, 2, 0x84},
+		{7, 2, 0x84},
+	}
+	builder.define(3, 20, recordFields, [][3]byte{{0, 2, 0}})
+	builder.define(4, 20, append([][3]byte{{fieldTimestampNum, 4, 0x86}}, recordFields...), [][3]byte{{0, 2, 0}})
+
+	pause := seconds / 2
+	elapsed := uint32(0)
+	for second := 0; second < seconds; second++ {
+		if second == pause {
+			builder.write(2, start+elapsed, uint8(fit.EventTimer), uint8(fit.EventTypeStopAll))
+			elapsed += 10
+			builder.write(2, start+elapsed, uint8(fit.EventTimer), uint8(fit.EventTypeStart))
+		}
+		timestamp := start + elapsed
+		values := []interface{}{
+			fit.DegreesToSemicircles(40 + float64(second)*0.0001),
+			fit.DegreesToSemicircles(-105),
+			uint16((1600 + 0.1*float64(second) + 500) * 5),
+			uint8(120 + second%60),
+			uint8(90),
+			uint32(second * 1000),
+			uint16(10000),
+			testWorkoutPower(second),
+			[]byte{0xAA, 0xBB},
+		}
+		//Use a compressed timestamp every so often
+		if second%10 == 5 {
+			builder.writeCompressed(3, uint8(timestamp&0x1F), values...)
+		} else {
+			builder.write(4, append([]interface{}{timestamp}, values...)...)
+		}
+		elapsed++
+	}
+	end := start + elapsed - 1
+	builder.write(2, end, uint8(fit.EventTimer), uint8(fit.EventTypeStopAll))
+
+	//lap and session share the same layout in this file
+	summaryFields := [][3]byte{{fieldTimestampNum, 4, 0x86}, {2, 4, 0x86}, {7, 4, 0x86}, {8, 4, 0x86}, {9, 4, 0x86}}
+	builder.define(5, 19, summaryFields, nil)
+	builder.write(5, end, start, (elapsed-1)*1000, uint32(seconds-1)*1000, uint32((seconds-1)*1000))
+	builder.define(6, 18, append(summaryFields, [3]byte{5, 1, 0x00}, [3]byte{6, 1, 0x00}), nil)
+	builder.write(6, end, start, (elapsed-1)*1000, uint32(seconds-1)*1000, uint32((seconds-1)*1000), uint8(2), uint8(6))
+
+	return builder.bytes()
+}
+
+const fieldTimestampNum = 253
+
+func TestFitDecode_Workout(t *testing.T) {
+	file, err := fit.DecodeBytes(buildTestWorkoutFitFile(120))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if file.FileID == nil || *file.FileID.Manufacturer != 32 || *file.FileID.SerialNumber != 12345 {
+		t.Error("Wrong file id")
+	}
+	if len(file.Records) != 120 {
+		t.Fatalf("Expected 120 records, got %d", len(file.Records))
+	}
+	if file.SkippedMessages != 1 {
+		t.Error("Expected the device_info message to be skipped")
+	}
+	if len(file.Events) != 4 || len(file.Laps) != 1 || len(file.Sessions) != 1 {
+		t.Error("Wrong number of events, laps or sessions")
+	}
+
+	first := file.Records[0]
+	if !first.Timestamp.Equal(testWorkoutStart) {
+		t.Error("Wrong first timestamp: " + first.Timestamp.String())
+	}
+	if *first.Power != 300 || *first.HeartRate != 120 || *first.Cadence != 90 || *first.Speed != 10 {
+		t.Error("Wrong first record values")
+	}
+	if *first.Lat < 39.9999 || *first.Lat > 40.0001 || *first.Long > -104.9999 || *first.Long < -105.0001 {
+		t.Error("Wrong position")
+	}
+	if *first.Altitude != 1600 {
+		t.Errorf("Wrong altitude %f", *first.Altitude)
+	}
+
+	//Records 5, 15 etc. use compressed timestamps
+	for i, record := range file.Records {
+		expected := testWorkoutStart.Add(time.Duration(i) * time.Second)
+		if i >= 60 {
+			expected = expected.Add(10 * time.Second)
+		}
+		if !record.Timestamp.Equal(expected) {
+			t.Fatalf("Record %d has timestamp %s expected %s", i, record.Timestamp, expected)
+		}
+	}
+
+	session := file.Sessions[0]
+	if *session.Sport != 2 || *session.SubSport != 6 || *session.TotalTimerTime != 119 || *session.TotalDistance != 1190 {
+		t.Error("Wrong session values")
+	}
+}
+
+func TestFitDecode_BadFiles(t *testing.T) {
+	valid := buildTestWorkoutFitFile(10)
+
+	if _, err := fit.DecodeBytes([]byte("not a fit file")); err != fit.ErrNotFitFile {
+		t.Error("Expected ErrNotFitFile")
+	}
+	if _, err := fit.DecodeBytes(valid[:len(valid)-10]); err != fit.ErrTruncated {
+		t.Error("Expected ErrTruncated")
+	}
+	corrupt := append([]byte{}, valid...)
+	corrupt[40] ^= 0xFF
+	if _, err := fit.DecodeBytes(corrupt); err != fit.ErrBadCRC {
+		t.Error("Expected ErrBadCRC")
+	}
+}
+
+func TestFitDecode_Chained(t *testing.T) {
+	data := append(buildTestWorkoutFitFile(10), buildTestWorkoutFitFile(20)...)
+	file, err := fit.DecodeBytes(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(file.Records) != 30 {
+		t.Errorf("Expected 30 records, got %d", len(file.Records))
+	}
+}
+
+func FuzzFitDecode(f *testing.F) {
+	f.Add(buildTestWorkoutFitFile(5))
+	f.Add(buildTestWorkoutFitFile(70))
+	f.Add([]byte{14, 0x20, 0, 0, 0, 0, 0, 0, '.', 'F', 'I', 'T', 0, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		//Skip the crc so the fuzzer can reach the message parsing
+		if len(data) >= 14 && data[0] == 14 {
+			binary.LittleEndian.PutUint16(data[12:14], 0)
+			size := int(binary.LittleEndian.Uint32(data[4:8]))
+			if 14+size+2 <= len(data) {
+				binary.LittleEndian.PutUint16(data[14+size:], fit.CRC16(data[:14+size]))
+			}
+		}
+		file, err := fit.DecodeBytes(data)
+		if err == nil && file == nil {
+			t.Error("No error and no file")
+		}
+	})
+}
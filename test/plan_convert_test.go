@@ -0,0 +1,146 @@
+package wahoo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/plan"
+)
+
+const testZWO = `<workout_file>
+    <author>Coach</author>
+    <name>Over Unders</name>
+    <description>Warm up then over unders</description>
+    <sportType>bike</sportType>
+    <workout>
+        <Warmup Duration="180" PowerLow="0.40" PowerHigh="0.70"/>
+        <SteadyState Duration="300" Power="0.88" Cadence="90">
+            <textevent timeoffset="10" message="Settle in"/>
+        </SteadyState>
+        <IntervalsT Repeat="3" OnDuration="60" OffDuration="120" OnPower="1.05" OffPower="0.5"/>
+        <FreeRide Duration="120" FlatRoad="1"/>
+        <MaxEffort Duration="20"/>
+        <Cooldown Duration="120" PowerLow="0.6" PowerHigh="0.6"/>
+        <Mystery Duration="10"/>
+    </workout>
+</workout_file>`
+
+func TestImportZWO(t *testing.T) {
+	file, report, err := plan.ImportZWO(strings.NewReader(testZWO), 250)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if file.Header.Name != "Over Unders" || *file.Header.FTP != 250 {
+		t.Error("Wrong header")
+	}
+	//3 warm up steps, steady, repeat, free ride, max effort, cool down
+	if len(file.Intervals) != 8 {
+		t.Fatalf("Expected 8 intervals, got %d", len(file.Intervals))
+	}
+	if *file.Intervals[0].Targets[0].Low != 0.45 || *file.Intervals[2].Targets[0].Low != 0.65 {
+		t.Error("Wrong warm up steps")
+	}
+	steady := file.Intervals[3]
+	if steady.ExitTriggerValue != 300 || *steady.Targets[0].Low != 0.88 || steady.Targets[1].Type != plan.TargetCadence {
+		t.Error("Wrong steady state")
+	}
+	repeat := file.Intervals[4]
+	if repeat.ExitTriggerType != plan.TriggerRepeat || repeat.ExitTriggerValue != 3 || len(repeat.Intervals) != 2 {
+		t.Error("Wrong repeat")
+	}
+	if len(file.Intervals[5].Targets) != 0 || file.Intervals[6].Targets[0].Type != plan.TargetRPE {
+		t.Error("Wrong free ride or max effort")
+	}
+	if file.Duration() != 180+300+3*180+120+20+120 {
+		t.Errorf("Wrong duration %v", file.Duration())
+	}
+
+	expected := []string{
+		"segment 0 (Warmup): ramp from 0.4 to 0.7 split into 3 steps",
+		"segment 1 (SteadyState): text events were dropped",
+		"segment 3 (FreeRide): FlatRoad has no equivalent and was dropped",
+		"segment 6 (Mystery): unknown segment type, skipped",
+	}
+	if !reflect.DeepEqual(report.Warnings, expected) {
+		t.Errorf("Wrong report:\n%s", strings.Join(report.Warnings, "\n"))
+	}
+
+	if _, _, err := plan.ImportZWO(strings.NewReader(testZWO), 0); err == nil {
+		t.Error("Expected an error without an FTP")
+	}
+}
+
+const testERG = `[COURSE HEADER]
+VERSION = 2
+UNITS = ENGLISH
+DESCRIPTION = Threshold
+FILE NAME = Threshold 2x10
+MINUTES WATTS
+[END COURSE HEADER]
+[COURSE DATA]
+0.00	100
+5.00	200
+5.00	250
+15.00	250
+15.00	125
+20.00	125
+20.00	250
+30.00	250
+[END COURSE DATA]
+[COURSE TEXT]
+60	Go!	10
+[END COURSE TEXT]`
+
+func TestImportERG(t *testing.T) {
+	file, report, err := plan.ImportERG(strings.NewReader(testERG), 250)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	//5 ramp steps then 250, 125, 250
+	if len(file.Intervals) != 8 || file.Header.Name != "Threshold 2x10" || *file.Header.FTP != 250 {
+		t.Fatalf("Wrong plan: %d intervals", len(file.Intervals))
+	}
+	last := file.Intervals[7]
+	if last.Targets[0].Type != plan.TargetWatts || *last.Targets[0].Low != 250 || last.ExitTriggerValue != 600 || last.IntensityType != plan.IntensityLT {
+		t.Error("Wrong last interval")
+	}
+	if file.Duration() != 30*60 {
+		t.Errorf("Wrong duration %v", file.Duration())
+	}
+	if len(report.Warnings) != 2 || !strings.Contains(report.Warnings[0], "course text") {
+		t.Errorf("Wrong report:\n%s", strings.Join(report.Warnings, "\n"))
+	}
+
+	if _, _, err := plan.ImportMRC(strings.NewReader(testERG), 250); err == nil {
+		t.Error("Expected an error importing watts as an mrc")
+	}
+}
+
+const testMRC = `[COURSE HEADER]
+VERSION = 2
+FTP = 300
+MINUTES PERCENT
+[END COURSE HEADER]
+[COURSE DATA]
+0	50
+10	50
+10	95
+20	95
+20	95
+25	95
+[END COURSE DATA]`
+
+func TestImportMRC(t *testing.T) {
+	file, report, err := plan.ImportMRC(strings.NewReader(testMRC), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if *file.Header.FTP != 300 || !report.Exact() {
+		t.Error("Expected the FTP from the file and an exact conversion")
+	}
+	//The two 95% blocks are merged
+	if len(file.Intervals) != 2 || file.Intervals[1].ExitTriggerValue != 900 || *file.Intervals[1].Targets[0].Low != 0.95 {
+		t.Error("Wrong intervals")
+	}
+}
@@ -0,0 +1,140 @@
+package wahoo
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/plan"
+)
+
+func TestExportZWO(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	report, err := plan.ExportZWO(buffer, sweetSpotPlan(t), nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	compareGolden(t, "plan.zwo", buffer.Bytes())
+	if report.Exact() {
+		t.Error("Expected the warm up range to be reported")
+	}
+
+	//The exported file imports back to the same duration
+	imported, _, err := plan.ImportZWO(bytes.NewReader(buffer.Bytes()), 250)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if imported.Duration() != sweetSpotPlan(t).Duration() {
+		t.Errorf("Wrong duration after round trip %v", imported.Duration())
+	}
+}
+
+func TestExportERG(t *testing.T) {
+	ftp := 200
+	buffer := &bytes.Buffer{}
+	report, err := plan.ExportERG(buffer, sweetSpotPlan(t), &wahoo.PowerZone{Ftp: &ftp})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	compareGolden(t, "plan.erg", buffer.Bytes())
+
+	expected := []string{
+		"intervals[0]: ftp range 0.5-0.65 written as 0.575",
+		"intervals[1].intervals[0]: ftp range 0.88-0.93 written as 0.905",
+		"intervals[1].intervals[1]: ftp range 0.5-0.55 written as 0.525",
+	}
+	for _, warning := range expected {
+		if !containsString(report.Warnings, warning) {
+			t.Errorf("Missing warning %q in %v", warning, report.Warnings)
+		}
+	}
+	if !containsString(report.Warnings, "intervals[2]: no power target, held at 0.5 of FTP") {
+		t.Errorf("Missing free ride warning in %v", report.Warnings)
+	}
+
+	//Without a power zone or an FTP in the header there is nothing to resolve the percentages against
+	file := sweetSpotPlan(t)
+	file.Header.FTP = nil
+	if _, err := plan.ExportERG(&bytes.Buffer{}, file, nil); err == nil {
+		t.Error("Expected an error without an FTP")
+	}
+}
+
+func TestExportMRC(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	if _, err := plan.ExportMRC(buffer, sweetSpotPlan(t), nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	compareGolden(t, "plan.mrc", buffer.Bytes())
+
+	imported, _, err := plan.ImportMRC(bytes.NewReader(buffer.Bytes()), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if imported.Duration() != sweetSpotPlan(t).Duration() || *imported.Header.FTP != 250 {
+		t.Error("Wrong plan after round trip")
+	}
+}
+
+func TestExportERG_WattsAndKJ(t *testing.T) {
+	file, err := plan.NewBuilder("Watts").
+		Steady("Block", 2*time.Minute, plan.Watts(300, 300)).
+		Work("Work", plan.IntensityLT, 90, plan.Watts(300, 300)).
+		Distance("Road", plan.IntensityActive, 1000).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ftp := 300
+	buffer := &bytes.Buffer{}
+	report, err := plan.ExportERG(buffer, file, &wahoo.PowerZone{Ftp: &ftp})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	//90kJ at 300W is 5 minutes
+	if !strings.Contains(buffer.String(), "2.00\t300\n7.00\t300\n") {
+		t.Error("Wrong course data:\n" + buffer.String())
+	}
+	if !containsString(report.Warnings, "intervals[1]: kJ interval converted to 300 seconds") ||
+		!containsString(report.Warnings, "intervals[2]: distance intervals can not be timed and were skipped") {
+		t.Errorf("Wrong warnings %v", report.Warnings)
+	}
+}
+
+func TestPlanFetch(t *testing.T) {
+	planJSON, err := sweetSpotPlan(t).Encode()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(planJSON)
+	}))
+	defer server.Close()
+
+	client := constructOfflineClient(t)
+	file, err := plan.Fetch(context.Background(), client, &wahoo.Plan{File: &wahoo.File{URL: server.URL}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if file.Header.Name != "Sweet Spot 3x10" || len(file.Intervals) != 3 {
+		t.Error("Wrong plan fetched")
+	}
+	if _, err := plan.Fetch(context.Background(), client, &wahoo.Plan{}); err == nil {
+		t.Error("Expected an error without a file url")
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
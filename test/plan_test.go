@@ -0,0 +1,94 @@
+package wahoo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/plan"
+)
+
+//sweetSpotPlan - 10 minute warm up, 3x(10 on / 5 off) and a 10 minute cool down
+func sweetSpotPlan(t *testing.T) *plan.File {
+	file, err := plan.NewBuilder("Sweet Spot 3x10").
+		Description("Three blocks of sweet spot").
+		WorkoutType(0, 1).
+		FTP(250).
+		Warmup(10*time.Minute, plan.PercentFTP(0.5, 0.65)).
+		Repeat(3, func(r *plan.Builder) {
+			r.Steady("Sweet Spot", 10*time.Minute, plan.PercentFTP(0.88, 0.93), plan.Cadence(85, 95))
+			r.Recover(5*time.Minute, plan.PercentFTP(0.5, 0.55))
+		}).
+		Cooldown(10*time.Minute, plan.RPE(2)).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return file
+}
+
+func TestPlanBuilder_RoundTrip(t *testing.T) {
+	file := sweetSpotPlan(t)
+	if file.Duration() != 65*60 {
+		t.Errorf("Wrong duration %v", file.Duration())
+	}
+
+	data, err := file.Encode()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(data), `"exit_trigger_type":"repeat","exit_trigger_value":3`) || !strings.Contains(string(data), `"type":"ftp","low":0.88,"high":0.93`) {
+		t.Error("Unexpected plan json: " + string(data))
+	}
+
+	parsed, err := plan.Parse(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(parsed, file) {
+		t.Error("Plan did not survive the round trip")
+	}
+
+	wahooPlan, err := parsed.WahooPlan("coach-42")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(wahooPlan.PlanFile) != string(data) || *wahooPlan.ExternalID != "coach-42" || *wahooPlan.Name != "Sweet Spot 3x10" {
+		t.Error("Wrong wahoo plan")
+	}
+}
+
+func TestPlanValidate(t *testing.T) {
+	//No FTP in the header, a bad repeat and a backwards target
+	low, high := 0.9, 0.8
+	file := &plan.File{
+		Intervals: []*plan.Interval{
+			{ExitTriggerType: plan.TriggerTime, ExitTriggerValue: 60, Targets: []plan.Target{plan.PercentFTP(0.5, 0.6)}},
+			{ExitTriggerType: plan.TriggerRepeat, ExitTriggerValue: 2},
+			{ExitTriggerType: plan.TriggerTime, ExitTriggerValue: 0, Targets: []plan.Target{{Type: plan.TargetWatts, Low: &low, High: &high}}},
+			{ExitTriggerType: "laps", ExitTriggerValue: 1, Targets: []plan.Target{plan.RPE(11)}},
+		},
+	}
+	err := file.Validate()
+	validationErr, ok := err.(*plan.ValidationError)
+	if !ok {
+		t.Fatal("Expected a ValidationError")
+	}
+	expected := []string{
+		"header: name is required",
+		"intervals[0].targets[0]: ftp targets need header.ftp",
+		"intervals[1]: repeat has nothing to repeat",
+		"intervals[2]: exit trigger value must be positive",
+		"intervals[2].targets[0]: low is above high",
+		`intervals[3]: unknown exit trigger type "laps"`,
+		"intervals[3].targets[0]: rpe must be between 1 and 10",
+	}
+	if !reflect.DeepEqual(validationErr.Problems, expected) {
+		t.Errorf("Wrong problems:\n%s", strings.Join(validationErr.Problems, "\n"))
+	}
+
+	if _, err := file.Encode(); err == nil {
+		t.Error("Encode should validate")
+	}
+}
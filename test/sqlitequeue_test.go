@@ -0,0 +1,59 @@
+package wahoo
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/sqlitequeue"
+)
+
+func TestSQLiteWebhookQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	queue, err := sqlitequeue.Open(db, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	testQueueSemantics(t, queue)
+	failed, err := queue.Failed()
+	if err != nil || len(failed) != 1 || failed[0].ID != "b" {
+		t.Error("Expected b to have failed")
+	}
+
+	//A webhook that was being processed when the process stopped is pending again after a restart
+	now := time.Now()
+	_, _ = queue.Enqueue(&wahoo.QueuedWebhook{ID: "c", Payload: []byte(`{"x":1}`), ReceivedAt: now, NextAttempt: now})
+	if dequeued, _ := queue.Dequeue(now); dequeued == nil {
+		t.Fatal("Expected c")
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	db, err = sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer db.Close()
+	reopened, err := sqlitequeue.Open(db, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if count, err := reopened.Len(); err != nil || count != 1 {
+		t.Fatalf("Expected c to be pending, got %d", count)
+	}
+	restored, _ := reopened.Dequeue(now)
+	if restored == nil || restored.ID != "c" || string(restored.Payload) != `{"x":1}` || !restored.ReceivedAt.Equal(now) {
+		t.Fatal("Expected c to be restored")
+	}
+	//The completed webhook from before the restart is still deduplicated
+	if added, _ := reopened.Enqueue(&wahoo.QueuedWebhook{ID: "a"}); added {
+		t.Error("Expected the dedup to survive a restart")
+	}
+}
@@ -0,0 +1,246 @@
+package wahoo
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+func TestWorkoutType_StringAndParse(t *testing.T) {
+	all := wahoo.AllWorkoutTypes()
+	if len(all) != 55 || all[0] != wahoo.Biking || all[len(all)-1] != wahoo.BikingIndoorTrainer {
+		t.Fatalf("Wrong workout types %v", all)
+	}
+	seen := map[string]bool{}
+	for _, workoutType := range all {
+		name := workoutType.String()
+		if seen[name] || !workoutType.Known() {
+			t.Errorf("Duplicate or unknown name %s", name)
+		}
+		seen[name] = true
+		parsed, err := wahoo.ParseWorkoutType(name)
+		if err != nil || parsed != workoutType {
+			t.Errorf("%s did not round trip", name)
+		}
+	}
+
+	if wahoo.RunnintTreadmill.String() != "running_treadmill" || wahoo.BikingIndoorCylingClass.String() != "biking_indoor_cycling_class" {
+		t.Error("Expected the names to be spelled correctly")
+	}
+	for value, expected := range map[string]wahoo.WorkoutType{"BIKING_ROAD": wahoo.BikingRoad, " Biking Road ": wahoo.BikingRoad, "fe-bike": wahoo.FeBike, "15": wahoo.BikingRoad, "99": wahoo.WorkoutType(99)} {
+		if parsed, err := wahoo.ParseWorkoutType(value); err != nil || parsed != expected {
+			t.Errorf("Expected %q to parse to %v", value, expected)
+		}
+	}
+	if _, err := wahoo.ParseWorkoutType("unicycling"); err == nil {
+		t.Error("Expected an error for an unknown name")
+	}
+
+	unknown := wahoo.WorkoutType(99)
+	if unknown.Known() || unknown.String() != "99" {
+		t.Error("Expected an unknown type to be its ID")
+	}
+}
+
+func TestWorkoutType_JSON(t *testing.T) {
+	type record struct {
+		Type  wahoo.WorkoutType  `json:"type"`
+		Other *wahoo.WorkoutType `json:"other"`
+	}
+	data, err := json.Marshal(record{Type: wahoo.BikingIndoorTrainer})
+	if err != nil || string(data) != `{"type":"biking_indoor_trainer","other":null}` {
+		t.Fatalf("Wrong JSON %s %v", data, err)
+	}
+
+	decoded := record{}
+	if err := json.Unmarshal([]byte(`{"type":"running_trail","other":62}`), &decoded); err != nil {
+		t.Fatal(err.Error())
+	}
+	if decoded.Type != wahoo.RunningTrail || decoded.Other == nil || *decoded.Other != 62 {
+		t.Error("Expected a name and an unknown ID to decode")
+	}
+	again, _ := json.Marshal(decoded)
+	if string(again) != `{"type":"running_trail","other":"62"}` {
+		t.Error("Expected the unknown ID to be kept: " + string(again))
+	}
+	if err := json.Unmarshal([]byte(`{"type":"unicycling"}`), &decoded); err == nil {
+		t.Error("Expected an error for an unknown name")
+	}
+}
+
+func TestWorkout_Type(t *testing.T) {
+	workout := &wahoo.Workout{}
+	if _, ok := workout.Type(); ok {
+		t.Error("Expected no type")
+	}
+	workout.SetType(wahoo.FeRower)
+	if workoutType, ok := workout.Type(); !ok || workoutType != wahoo.FeRower || *workout.WorkoutTypeID != 22 {
+		t.Error("Expected the type to be set")
+	}
+
+	//The API ID is kept even when this client does not know it
+	decoded := &wahoo.Workout{}
+	if err := json.Unmarshal([]byte(`{"id":1,"workout_type_id":66}`), decoded); err != nil {
+		t.Fatal(err.Error())
+	}
+	if workoutType, ok := decoded.Type(); !ok || workoutType != 66 || workoutType.Known() {
+		t.Error("Expected the unknown type to be kept")
+	}
+}
+
+func TestWorkoutType_Families(t *testing.T) {
+	families := map[wahoo.WorkoutFamily]bool{}
+	for _, workoutType := range wahoo.AllWorkoutTypes() {
+		families[workoutType.Family()] = true
+		if workoutType.IsIndoor() && (workoutType.IsOutdoor() || workoutType.ExpectsGPS()) {
+			t.Errorf("%s is indoor and expects to be outdoors", workoutType)
+		}
+	}
+	if len(families) != 8 {
+		t.Errorf("Expected all 8 families to be used, got %v", families)
+	}
+
+	cycling := 0
+	for _, workoutType := range wahoo.AllWorkoutTypes() {
+		if workoutType.IsCycling() {
+			cycling++
+		}
+	}
+	if cycling != 10 || len(wahoo.WorkoutTypesInFamily(wahoo.WorkoutFamilyBike)) != 9 {
+		t.Errorf("Expected 9 bikes and the fitness equipment bike to be cycling, got %d", cycling)
+	}
+	if wahoo.BikingMotorcycling.IsCycling() || wahoo.BikingMotorcycling.ExpectsPower() {
+		t.Error("Motorcycling is not cycling")
+	}
+
+	tests := []struct {
+		workoutType                           wahoo.WorkoutType
+		family                                wahoo.WorkoutFamily
+		indoor, outdoor, distance, gps, power bool
+		cycling, running, walking, swimming   bool
+	}{
+		{wahoo.BikingRoad, wahoo.WorkoutFamilyBike, false, true, true, true, true, true, false, false, false},
+		{wahoo.BikingIndoorTrainer, wahoo.WorkoutFamilyBike, true, false, true, false, true, true, false, false, false},
+		{wahoo.FeTreadmill, wahoo.WorkoutFamilyFitnessEquipment, true, false, true, false, false, false, true, false, false},
+		{wahoo.RunningTrail, wahoo.WorkoutFamilyRun, false, true, true, true, false, false, true, false, false},
+		{wahoo.Hiking, wahoo.WorkoutFamilyWalk, false, true, true, true, false, false, false, true, false},
+		{wahoo.Swimming, wahoo.WorkoutFamilySwim, false, false, true, false, false, false, false, false, true},
+		{wahoo.SkiingCrossCountry, wahoo.WorkoutFamilyWinter, false, true, true, true, false, false, false, false, false},
+		{wahoo.Kayaking, wahoo.WorkoutFamilyWater, false, true, true, true, false, false, false, false, false},
+		{wahoo.WorkoutType(99), wahoo.WorkoutFamilyOther, false, false, false, false, false, false, false, false, false},
+	}
+	for _, test := range tests {
+		workoutType := test.workoutType
+		if workoutType.Family() != test.family || workoutType.IsIndoor() != test.indoor || workoutType.IsOutdoor() != test.outdoor ||
+			workoutType.ExpectsDistance() != test.distance || workoutType.ExpectsGPS() != test.gps || workoutType.ExpectsPower() != test.power ||
+			workoutType.IsCycling() != test.cycling || workoutType.IsRunning() != test.running ||
+			workoutType.IsWalking() != test.walking || workoutType.IsSwimming() != test.swimming {
+			t.Errorf("Wrong metadata for %s", workoutType)
+		}
+	}
+}
+
+//workoutTypeNames - the names declared in a const or var block of a file in pkg, or the keys of a map literal
+func workoutTypeNames(t *testing.T, file, variable string) map[string]bool {
+	parsed, err := parser.ParseFile(token.NewFileSet(), filepath.Join("..", "pkg", file), nil, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	names := map[string]bool{}
+	ast.Inspect(parsed, func(node ast.Node) bool {
+		spec, ok := node.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		if variable == "" {
+			if ident, ok := spec.Type.(*ast.Ident); ok && ident.Name == "WorkoutType" {
+				names[spec.Names[0].Name] = true
+			}
+			return false
+		}
+		if spec.Names[0].Name != variable {
+			return false
+		}
+		for _, element := range spec.Values[0].(*ast.CompositeLit).Elts {
+			names[element.(*ast.KeyValueExpr).Key.(*ast.Ident).Name] = true
+		}
+		return false
+	})
+	return names
+}
+
+func TestWorkoutType_MappingsCoverEveryConstant(t *testing.T) {
+	constants := workoutTypeNames(t, "wahoo_enum.go", "")
+	mapped := workoutTypeNames(t, "wahoo_enum_mapping.go", "workoutTypeMappings")
+	if len(constants) != len(wahoo.AllWorkoutTypes()) {
+		t.Errorf("AllWorkoutTypes has %d types but there are %d constants", len(wahoo.AllWorkoutTypes()), len(constants))
+	}
+	if !reflect.DeepEqual(constants, mapped) {
+		for name := range constants {
+			if !mapped[name] {
+				t.Errorf("%s has no mapping", name)
+			}
+		}
+		t.Errorf("Expected the mappings to match the constants")
+	}
+}
+
+func TestWorkoutType_Mappings(t *testing.T) {
+	for _, workoutType := range wahoo.AllWorkoutTypes() {
+		sport, subSport := workoutType.FITSport()
+		fromFIT := wahoo.WorkoutTypeFromFIT(sport, subSport)
+		fromStrava := wahoo.WorkoutTypeFromStrava(workoutType.StravaType())
+		fromTrainingPeaks := wahoo.WorkoutTypeFromTrainingPeaks(workoutType.TrainingPeaksType())
+		for platform, back := range map[string]wahoo.WorkoutType{"FIT": fromFIT, "Strava": fromStrava, "TrainingPeaks": fromTrainingPeaks} {
+			if !back.Known() || back.IsCycling() != workoutType.IsCycling() || back.IsRunning() != workoutType.IsRunning() ||
+				back.IsSwimming() != workoutType.IsSwimming() {
+				t.Errorf("%s came back from %s as %s", workoutType, platform, back)
+			}
+		}
+		//FIT keeps the family as well
+		if fromFIT.Family() != workoutType.Family() {
+			t.Errorf("%s came back from FIT as %s", workoutType, fromFIT)
+		}
+	}
+
+	//The most common types survive a round trip
+	for _, workoutType := range []wahoo.WorkoutType{wahoo.Biking, wahoo.BikingRoad, wahoo.BikingMountain, wahoo.BikingIndoorTrainer, wahoo.Running, wahoo.RunningTrail, wahoo.Swimming, wahoo.SwimmingLap, wahoo.Hiking, wahoo.FeRower} {
+		if sport, subSport := workoutType.FITSport(); wahoo.WorkoutTypeFromFIT(sport, subSport) != workoutType {
+			t.Errorf("%s did not round trip through FIT", workoutType)
+		}
+	}
+	if wahoo.WorkoutTypeFromStrava(wahoo.BikingMountain.StravaType()) != wahoo.BikingMountain || wahoo.WorkoutTypeFromStrava(wahoo.RunningTrail.StravaType()) != wahoo.RunningTrail {
+		t.Error("Expected mountain biking and trail running to round trip through Strava")
+	}
+
+	tests := []struct {
+		got, expected wahoo.WorkoutType
+	}{
+		//The session in buildTestWorkoutFitFile is cycling/indoor_cycling
+		{wahoo.WorkoutTypeFromFIT(2, 6), wahoo.BikingIndoorTrainer},
+		//An unknown sub_sport falls back to the sport and an unknown sport to Other
+		{wahoo.WorkoutTypeFromFIT(2, 200), wahoo.Biking},
+		{wahoo.WorkoutTypeFromFIT(200, 0), wahoo.Other},
+		{wahoo.WorkoutTypeFromStrava("gravelride"), wahoo.BikingCylocross},
+		{wahoo.WorkoutTypeFromStrava("Surfing"), wahoo.Other},
+		{wahoo.WorkoutTypeFromTrainingPeaks("Mountain Bike"), wahoo.BikingMountain},
+		{wahoo.WorkoutTypeFromTrainingPeaks("XC-Ski"), wahoo.SkiingCrossCountry},
+		{wahoo.WorkoutTypeFromTrainingPeaks("Brick"), wahoo.Other},
+	}
+	for i, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("%d: expected %s, got %s", i, test.expected, test.got)
+		}
+	}
+
+	unknown := wahoo.WorkoutType(99)
+	if sport, subSport := unknown.FITSport(); sport != 0 || subSport != 0 || unknown.StravaType() != "Workout" || unknown.TrainingPeaksType() != "Other" {
+		t.Error("Expected an unknown type to map to the generic values")
+	}
+}
@@ -0,0 +1,181 @@
+package wahoo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+var fitFileContents = []byte(strings.Repeat("FIT-DATA-", 1000))
+
+//serveFitFile - serves fitFileContents and honours range requests the same way a CDN would
+func serveFitFile(w http.ResponseWriter, r *http.Request) {
+	http.ServeContent(w, r, "workout.fit", time.Time{}, bytes.NewReader(fitFileContents))
+}
+
+func constructOfflineClient(t *testing.T) *wahoo.Client {
+	client, err := wahoo.ConstructClient("secret", "id", "", false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	client.SetRetryPolicy(2, time.Millisecond)
+	return client
+}
+
+func summaryForURL(url string) *wahoo.WorkoutSummary {
+	return &wahoo.WorkoutSummary{File: &wahoo.File{URL: url}}
+}
+
+func TestDownloadWorkoutFile_Full(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(serveFitFile))
+	defer server.Close()
+
+	client := constructOfflineClient(t)
+	buffer := &bytes.Buffer{}
+	result, err := client.DownloadWorkoutFile(context.Background(), summaryForURL(server.URL), buffer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(buffer.Bytes(), fitFileContents) {
+		t.Error("Downloaded contents do not match")
+	}
+	sum := sha256.Sum256(fitFileContents)
+	if result.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Error("Wrong checksum: " + result.SHA256)
+	}
+	if result.TotalSize != int64(len(fitFileContents)) || result.BytesWritten != result.TotalSize {
+		t.Error("Wrong sizes reported")
+	}
+}
+
+func TestDownloadWorkoutFile_Resume(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(serveFitFile))
+	defer server.Close()
+
+	client := constructOfflineClient(t)
+	offset := int64(1234)
+	buffer := bytes.NewBuffer(append([]byte{}, fitFileContents[:offset]...))
+	result, err := client.ResumeWorkoutFileDownload(context.Background(), summaryForURL(server.URL), buffer, offset)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(buffer.Bytes(), fitFileContents) {
+		t.Error("Resumed contents do not match")
+	}
+	if !result.Resumed || result.BytesWritten != int64(len(fitFileContents))-offset {
+		t.Error("Expected a resumed download of the remaining bytes")
+	}
+}
+
+func TestDownloadWorkoutFile_RetryResumesAfterDrop(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			//Promise the whole file but drop the connection half way through
+			w.Header().Set("Content-Length", strconv.Itoa(len(fitFileContents)))
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(fitFileContents[:len(fitFileContents)/2])
+			return
+		}
+		if r.Header.Get("Range") == "" {
+			t.Error("Expected the retry to use a range request")
+		}
+		serveFitFile(w, r)
+	}))
+	defer server.Close()
+
+	client := constructOfflineClient(t)
+	buffer := &bytes.Buffer{}
+	result, err := client.DownloadWorkoutFile(context.Background(), summaryForURL(server.URL), buffer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(buffer.Bytes(), fitFileContents) {
+		t.Error("Contents do not match after retry")
+	}
+	if !result.Resumed || attempts != 2 {
+		t.Error("Expected exactly one resumed retry")
+	}
+}
+
+func TestDownloadWorkoutFile_TooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(serveFitFile))
+	defer server.Close()
+
+	client := constructOfflineClient(t)
+	client.SetMaxFileSize(100)
+	_, err := client.DownloadWorkoutFile(context.Background(), summaryForURL(server.URL), &bytes.Buffer{})
+	if err != wahoo.ErrFileTooLarge {
+		t.Error("Expected ErrFileTooLarge")
+	}
+}
+
+func TestDownloadWorkoutFile_ServerError(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := constructOfflineClient(t)
+	_, err := client.DownloadWorkoutFile(context.Background(), summaryForURL(server.URL), &bytes.Buffer{})
+	wahooErr, ok := err.(wahoo.ErrorResponse)
+	if !ok || wahooErr.Code != http.StatusServiceUnavailable {
+		t.Error("Expected a 503 ErrorResponse")
+	}
+	if attempts != 3 {
+		t.Error("Expected the request to be retried twice. Attempts: " + strconv.Itoa(attempts))
+	}
+}
+
+func TestDownloadWorkoutFile_ResumeRangeMismatch(t *testing.T) {
+	//A server that answers a range request with a different part of the file
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", "bytes 0-99/"+strconv.Itoa(len(fitFileContents)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(fitFileContents[:100])
+	}))
+	defer server.Close()
+
+	client := constructOfflineClient(t)
+	offset := int64(1234)
+	buffer := bytes.NewBuffer(append([]byte{}, fitFileContents[:offset]...))
+	_, err := client.ResumeWorkoutFileDownload(context.Background(), summaryForURL(server.URL), buffer, offset)
+	if err != wahoo.ErrRangeMismatch {
+		t.Errorf("Expected ErrRangeMismatch, got %v", err)
+	}
+	if int64(buffer.Len()) != offset {
+		t.Error("Expected nothing to be appended")
+	}
+}
+
+func TestDownloadWorkoutFile_ResumeAlreadyComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(serveFitFile))
+	defer server.Close()
+	client := constructOfflineClient(t)
+
+	//The whole file is already there so the 416 is a success
+	complete := int64(len(fitFileContents))
+	buffer := bytes.NewBuffer(append([]byte{}, fitFileContents...))
+	result, err := client.ResumeWorkoutFileDownload(context.Background(), summaryForURL(server.URL), buffer, complete)
+	if err != nil || result.BytesWritten != 0 || result.TotalSize != complete {
+		t.Errorf("Expected an already complete download, got %v %+v", err, result)
+	}
+
+	//Past the end of the file means it is not the file that was partly downloaded
+	_, err = client.ResumeWorkoutFileDownload(context.Background(), summaryForURL(server.URL), &bytes.Buffer{}, complete+10)
+	if err != wahoo.ErrRangeMismatch {
+		t.Errorf("Expected ErrRangeMismatch, got %v", err)
+	}
+}
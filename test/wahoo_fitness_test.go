@@ -0,0 +1,242 @@
+package wahoo
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+//powerSamples - one sample per second with the power from the function and one to mark the end
+func powerSamples(seconds int, power func(second int) float64) []wahoo.Sample {
+	start := time.Date(2020, time.June, 1, 7, 30, 0, 0, time.UTC)
+	samples := make([]wahoo.Sample, 0, seconds+1)
+	for second := 0; second < seconds; second++ {
+		samples = append(samples, wahoo.Sample{Time: start.Add(time.Duration(second) * time.Second), Power: floatPointer(power(second))})
+	}
+	return append(samples, wahoo.Sample{Time: start.Add(time.Duration(seconds) * time.Second)})
+}
+
+func TestEstimateFromSamples_20Minutes(t *testing.T) {
+	estimate := wahoo.EstimateFromSamples(powerSamples(30*60, func(second int) float64 {
+		if second < 10*60 {
+			return 150
+		}
+		return 300
+	}))
+	if !closeTo(estimate.Best20MinutePower, 300) || !closeTo(estimate.FTP, 285) || estimate.FTPMethod != wahoo.FTPFrom20Minutes {
+		t.Errorf("Wrong estimate %+v", estimate)
+	}
+	if estimate.RampTest || estimate.MaxHeartRate != nil {
+		t.Error("Expected no ramp test and no heart rate")
+	}
+
+	short := wahoo.EstimateFromSamples(powerSamples(10*60, func(second int) float64 { return 300 }))
+	if short.FTP != nil || short.Best20MinutePower != nil || !closeTo(short.Best1MinutePower, 300) {
+		t.Error("Expected no FTP from a short ride")
+	}
+}
+
+func TestEstimateFromSamples_RampTest(t *testing.T) {
+	//5 minutes at 100W, then 150W going up 20W a minute for 12 minutes and a cool down
+	ramp := func(second int) float64 {
+		switch {
+		case second < 5*60:
+			return 100
+		case second < 17*60:
+			return 150 + float64((second-5*60)/60)*20
+		}
+		return 80
+	}
+	estimate := wahoo.EstimateFromSamples(powerSamples(20*60, ramp))
+	if !estimate.RampTest || estimate.FTPMethod != wahoo.FTPFromRampTest || !closeTo(estimate.FTP, 370*0.75) {
+		t.Errorf("Expected a ramp test, got %+v", estimate)
+	}
+
+	//The same ramp without stopping at the top is a progressive ride
+	steady := wahoo.EstimateFromSamples(powerSamples(20*60, func(second int) float64 {
+		if second >= 17*60 {
+			return 370
+		}
+		return ramp(second)
+	}))
+	if steady.RampTest {
+		t.Error("Expected no ramp test when the effort carries on")
+	}
+}
+
+func TestEstimateFromSamples_SubSecondSamples(t *testing.T) {
+	//20 minutes recorded at 4Hz
+	start := time.Date(2020, time.June, 1, 7, 30, 0, 0, time.UTC)
+	samples := []wahoo.Sample{}
+	for i := 0; i <= 4*20*60; i++ {
+		samples = append(samples, wahoo.Sample{Time: start.Add(time.Duration(i) * 250 * time.Millisecond), Power: floatPointer(300)})
+	}
+	estimate := wahoo.EstimateFromSamples(samples)
+	if !closeTo(estimate.Best20MinutePower, 300) || !closeTo(estimate.FTP, 285) {
+		t.Errorf("Wrong estimate %+v", estimate)
+	}
+}
+
+func TestEstimateFitness_ProposeZones(t *testing.T) {
+	longRide := buildTestWorkoutFitFile(1500)
+	shortRide := buildTestWorkoutFitFile(120)
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fileURL := "https://" + server.Listener.Addr().String() + "/files/"
+		switch r.URL.Path {
+		case "/v1/workouts":
+			starts := time.Date(2020, time.June, 1, 7, 30, 0, 0, time.UTC)
+			old := starts.AddDate(-1, 0, 0)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"workouts": []interface{}{
+				map[string]interface{}{"id": 1, "starts": starts, "workout_summary": map[string]interface{}{"id": 11, "file": map[string]string{"url": fileURL + "long.fit"}}},
+				map[string]interface{}{"id": 2, "starts": starts, "workout_summary": map[string]interface{}{"id": 12, "file": map[string]string{"url": fileURL + "short.fit"}}},
+				map[string]interface{}{"id": 3, "starts": starts},
+				map[string]interface{}{"id": 4, "starts": old, "workout_summary": map[string]interface{}{"id": 14, "file": map[string]string{"url": fileURL + "missing.fit"}}},
+			}})
+		case "/files/long.fit":
+			_, _ = w.Write(longRide)
+		case "/files/short.fit":
+			_, _ = w.Write(shortRide)
+		case "/v1/power_zone":
+			_, _ = w.Write([]byte(`{"id":1,"zone_1":0,"zone_2":112,"zone_3":152,"zone_4":182,"zone_5":212,"zone_6":242,"zone_7":302,"ftp":200,"zone_count":7}`))
+		case "/v1/heart_rate_zone":
+			_, _ = w.Write([]byte(`{"id":1,"zone_1":118,"zone_2":129,"zone_3":141,"zone_4":152,"zone_5":164,"resting":60,"maximum":175}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := constructTestServerClient(t, server)
+
+	since := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	proposal, err := client.ProposeZones(context.Background(), "token", since)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	estimate := proposal.Estimate
+	if len(estimate.Workouts) != 2 || estimate.Skipped != 1 {
+		t.Fatalf("Expected 2 workouts and 1 skipped, got %d and %d", len(estimate.Workouts), estimate.Skipped)
+	}
+	//Power alternates 300W and 150W so the best 20 minutes is about 225W
+	if math.Round(*estimate.FTP) != 214 || estimate.FTPWorkout.Workout.ID != 1 || *estimate.MaxHeartRate != 179 {
+		t.Errorf("Wrong estimate FTP %v max heart rate %v", *estimate.FTP, *estimate.MaxHeartRate)
+	}
+
+	//The current zones are scaled by 214 / 200
+	if !reflect.DeepEqual(powerBounds(proposal.PowerZone), []int{0, 120, 163, 195, 227, 259, 323}) ||
+		*proposal.PowerZone.Ftp != 214 || *proposal.PowerZone.ZoneCount != 7 {
+		t.Errorf("Wrong power zones %v", powerBounds(proposal.PowerZone))
+	}
+	//The heart rate reserve goes from 115 to 119 beats above resting
+	if !reflect.DeepEqual(heartRateBounds(proposal.HeartRateZone), []int{120, 131, 144, 155, 168}) ||
+		*proposal.HeartRateZone.Maximum != 179 || *proposal.HeartRateZone.Resting != 60 {
+		t.Errorf("Wrong heart rate zones %v", heartRateBounds(proposal.HeartRateZone))
+	}
+	if len(proposal.Changes) != 13 {
+		t.Errorf("Expected 13 changes, got %d", len(proposal.Changes))
+	}
+	first := proposal.Changes[0]
+	if first.Field != "power_zone[zone_2]" || *first.Current != 112 || *first.Proposed != 120 {
+		t.Errorf("Wrong first change %s %d %d", first.Field, *first.Current, *first.Proposed)
+	}
+}
+
+func TestEstimateFitness_SkipsFailedDownloadsAndStopsPaging(t *testing.T) {
+	ride := buildTestWorkoutFitFile(1500)
+	since := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	pages := []int{}
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fileURL := "https://" + server.Listener.Addr().String() + "/files/"
+		switch r.URL.Path {
+		case "/v1/workouts":
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			pages = append(pages, page)
+			//Full pages so only the dates can stop the paging.  Page 1 is recent, page 2 is all older than since
+			starts := since.AddDate(0, 1, 0)
+			if page > 1 {
+				starts = since.AddDate(0, -page, 0)
+			}
+			workouts := []interface{}{}
+			for i := 0; i < 50; i++ {
+				file := "expired.fit"
+				if i == 0 {
+					file = "ride.fit"
+				}
+				workouts = append(workouts, map[string]interface{}{"id": page*100 + i, "starts": starts,
+					"workout_summary": map[string]interface{}{"id": page*100 + i, "file": map[string]string{"url": fileURL + file}}})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"workouts": workouts})
+		case "/files/ride.fit":
+			_, _ = w.Write(ride)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := constructTestServerClient(t, server)
+
+	estimate, err := client.EstimateFitness(context.Background(), "token", since)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(estimate.Workouts) != 1 || estimate.Skipped != 49 || estimate.FTP == nil {
+		t.Errorf("Expected 1 workout and 49 skipped, got %d and %d", len(estimate.Workouts), estimate.Skipped)
+	}
+	if !reflect.DeepEqual(pages, []int{1, 2}) {
+		t.Errorf("Expected paging to stop after the first page older than since, got pages %v", pages)
+	}
+}
+
+func TestFitnessEstimate_ProposeZones(t *testing.T) {
+	estimate := &wahoo.FitnessEstimate{}
+	estimate.Add(&wahoo.WorkoutEstimate{FTP: floatPointer(250), MaxHeartRate: floatPointer(180)})
+	estimate.Add(&wahoo.WorkoutEstimate{FTP: floatPointer(240), MaxHeartRate: floatPointer(185)})
+	estimate.Add(&wahoo.WorkoutEstimate{FTP: floatPointer(260)})
+
+	//Without current zones the calculators are used
+	proposal := estimate.ProposeZones(nil, nil)
+	if !reflect.DeepEqual(powerBounds(proposal.PowerZone), []int{0, 140, 190, 228, 265, 303, 378}) {
+		t.Errorf("Expected the Coggan zones, got %v", powerBounds(proposal.PowerZone))
+	}
+	if *proposal.HeartRateZone.Maximum != 185 || *proposal.HeartRateZone.Zone1 != 93 {
+		t.Error("Expected percent of max heart rate zones")
+	}
+
+	//A max heart rate below the current one and the same FTP change nothing
+	current, _ := wahoo.CalculatePowerZones(250, wahoo.PowerZonesCoggan5)
+	proposal = estimate.ProposeZones(current, &wahoo.HeartRateZone{Maximum: intPointer(190)})
+	if proposal.PowerZone != nil || proposal.HeartRateZone != nil || len(proposal.Changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", proposal.Changes)
+	}
+}
+
+func TestFitnessEstimate_FTPIgnoresOutliers(t *testing.T) {
+	estimate := &wahoo.FitnessEstimate{}
+	estimate.Add(&wahoo.WorkoutEstimate{FTP: floatPointer(250)})
+	if *estimate.FTP != 250 {
+		t.Errorf("Expected the only estimate, got %v", *estimate.FTP)
+	}
+	//A power meter spike on its own is not trusted
+	spike := &wahoo.WorkoutEstimate{FTP: floatPointer(600)}
+	estimate.Add(spike)
+	if *estimate.FTP != 250 || estimate.FTPWorkout == spike {
+		t.Errorf("Expected the lower of two estimates, got %v", *estimate.FTP)
+	}
+	median := &wahoo.WorkoutEstimate{FTP: floatPointer(255)}
+	estimate.Add(median)
+	estimate.Add(&wahoo.WorkoutEstimate{FTP: floatPointer(245)})
+	estimate.Add(&wahoo.WorkoutEstimate{})
+	//The best three are 600, 255 and 250
+	if *estimate.FTP != 255 || estimate.FTPWorkout != median {
+		t.Errorf("Expected the median of the best three, got %v", *estimate.FTP)
+	}
+}
@@ -0,0 +1,342 @@
+package wahoo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+//formReadOnly - fields the API sets, these are never sent
+var formReadOnly = map[string]bool{
+	"id":              true,
+	"created_at":      true,
+	"updated_at":      true,
+	"heart_rate_zone": true,
+	"power_zone":      true,
+}
+
+//Midnight so it survives being sent as a date (birth) as well as a timestamp (starts)
+var formTestTime = time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+//formCase - one writable field: where it lives in the struct and the form key it should be sent under
+type formCase struct {
+	key   string
+	path  []int
+	value reflect.Value
+}
+
+/*
+writableFormFields - walks the json tags of a struct and returns a case for every writable field with a test value
+set.  Nested structs (the workout summary and its file) become nested keys e.g. workout[workout_summary][file][url]
+*/
+func writableFormFields(structType reflect.Type, prefix string, path []int) []formCase {
+	cases := []formCase{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" || formReadOnly[tag] {
+			continue
+		}
+		key := prefix + "[" + tag + "]"
+		fieldPath := append(append([]int{}, path...), i)
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		switch {
+		case fieldType == reflect.TypeOf(time.Time{}):
+			cases = append(cases, formCase{key, fieldPath, reflect.ValueOf(formTestTime)})
+		case fieldType.Kind() == reflect.Struct:
+			cases = append(cases, writableFormFields(fieldType, key, fieldPath)...)
+		case fieldType.Kind() == reflect.Float64:
+			cases = append(cases, formCase{key, fieldPath, reflect.ValueOf(float64(i) + 0.5)})
+		case fieldType.Kind() == reflect.Int:
+			//Small enough to be a valid zone count and still a valid zone on its own
+			cases = append(cases, formCase{key, fieldPath, reflect.ValueOf(3)})
+		case fieldType.Kind() == reflect.String:
+			cases = append(cases, formCase{key, fieldPath, reflect.ValueOf("value of " + tag)})
+		}
+	}
+	return cases
+}
+
+//setFormField - sets only the field at path (allocating the structs and pointers on the way)
+func setFormField(target reflect.Value, path []int, value reflect.Value) {
+	for _, index := range path {
+		if target.Kind() == reflect.Ptr {
+			if target.IsNil() {
+				target.Set(reflect.New(target.Type().Elem()))
+			}
+			target = target.Elem()
+		}
+		target = target.Field(index)
+	}
+	if target.Kind() == reflect.Ptr {
+		pointer := reflect.New(target.Type().Elem())
+		pointer.Elem().Set(value)
+		target.Set(pointer)
+		return
+	}
+	target.Set(value)
+}
+
+//decodeFormValue - decodes a sent value back into the type of the test value
+func decodeFormValue(sent string, like reflect.Value) (reflect.Value, error) {
+	switch like.Interface().(type) {
+	case float64:
+		value, err := strconv.ParseFloat(sent, 64)
+		return reflect.ValueOf(value), err
+	case int:
+		value, err := strconv.Atoi(sent)
+		return reflect.ValueOf(value), err
+	case time.Time:
+		value, err := time.Parse("2006-01-02T15:04:05.000Z", sent)
+		if err != nil {
+			value, err = time.Parse("2006-01-02", sent)
+		}
+		return reflect.ValueOf(value), err
+	}
+	return reflect.ValueOf(sent), nil
+}
+
+/*
+runFormRoundTrip - sends each writable field on its own to a fake server, which decodes the form.  Exactly one key has
+to come back, it has to be the key for that field and its value has to decode to what was set.
+*/
+func runFormRoundTrip(t *testing.T, newValue func() interface{}, prefix string, update func(*wahoo.Client, interface{}) error) {
+	var received map[string][]string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = nil
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = r.MultipartForm.Value
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client := constructTestServerClient(t, server)
+
+	cases := writableFormFields(reflect.TypeOf(newValue()).Elem(), prefix, nil)
+	if len(cases) == 0 {
+		t.Fatalf("no writable fields found for %s", prefix)
+	}
+	for _, c := range cases {
+		value := newValue()
+		setFormField(reflect.ValueOf(value).Elem(), c.path, c.value)
+		if err := update(client, value); err != nil {
+			t.Errorf("%s: %s", c.key, err.Error())
+			continue
+		}
+		if len(received) != 1 || len(received[c.key]) != 1 {
+			t.Errorf("%s: expected only that key to be sent, got %v", c.key, received)
+			continue
+		}
+		decoded, err := decodeFormValue(received[c.key][0], c.value)
+		if err != nil {
+			t.Errorf("%s: %s", c.key, err.Error())
+			continue
+		}
+		if decodedTime, ok := decoded.Interface().(time.Time); ok {
+			if !decodedTime.Equal(c.value.Interface().(time.Time)) {
+				t.Errorf("%s: sent %v, expected %v", c.key, decodedTime, c.value)
+			}
+		} else if decoded.Interface() != c.value.Interface() {
+			t.Errorf("%s: sent %v, expected %v", c.key, decoded, c.value)
+		}
+	}
+}
+
+func TestUserFormRoundTrip(t *testing.T) {
+	runFormRoundTrip(t, func() interface{} { return &wahoo.User{} }, "user", func(client *wahoo.Client, value interface{}) error {
+		return client.UpdateUserData("token", value.(*wahoo.User))
+	})
+}
+
+func TestWorkoutFormRoundTrip(t *testing.T) {
+	runFormRoundTrip(t, func() interface{} { return &wahoo.Workout{ID: 1} }, "workout", func(client *wahoo.Client, value interface{}) error {
+		return client.UpdateSpecificWorkout("token", value.(*wahoo.Workout))
+	})
+}
+
+func TestHeartRateZoneFormRoundTrip(t *testing.T) {
+	runFormRoundTrip(t, func() interface{} { return &wahoo.HeartRateZone{} }, "heart_rate_zone", func(client *wahoo.Client, value interface{}) error {
+		return client.UpdateHeartRateZone("token", value.(*wahoo.HeartRateZone))
+	})
+}
+
+func TestPowerZoneFormRoundTrip(t *testing.T) {
+	runFormRoundTrip(t, func() interface{} { return &wahoo.PowerZone{} }, "power_zone", func(client *wahoo.Client, value interface{}) error {
+		return client.UpdatePowerZones("token", value.(*wahoo.PowerZone))
+	})
+}
+
+func TestWorkoutFormStartsInUTC(t *testing.T) {
+	var starts string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseMultipartForm(1 << 20)
+		starts = r.FormValue("workout[starts]")
+	}))
+	defer server.Close()
+	client := constructTestServerClient(t, server)
+
+	local := formTestTime.In(time.FixedZone("UTC-5", -5*60*60))
+	err := client.UpdateSpecificWorkout("token", &wahoo.Workout{ID: 1, Starts: &local})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if starts != "2020-06-01T00:00:00.000Z" {
+		t.Errorf("expected the start in UTC, got %s", starts)
+	}
+}
+
+/*
+runFormNullRoundTrip - clears each optional field of an update payload on its own.  The field has to be sent empty
+under the same key as the matching field of the base type (found by name), and nothing else can be sent.
+*/
+func runFormNullRoundTrip(t *testing.T, newUpdate func() interface{}, base reflect.Type, prefix string, update func(*wahoo.Client, interface{}) error) {
+	var received map[string][]string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = nil
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = r.MultipartForm.Value
+	}))
+	defer server.Close()
+	client := constructTestServerClient(t, server)
+
+	nulls := map[reflect.Type]reflect.Value{
+		reflect.TypeOf(wahoo.OptionalString{}): reflect.ValueOf(wahoo.NullString()),
+		reflect.TypeOf(wahoo.OptionalInt{}):    reflect.ValueOf(wahoo.NullInt()),
+		reflect.TypeOf(wahoo.OptionalFloat{}):  reflect.ValueOf(wahoo.NullFloat()),
+		reflect.TypeOf(wahoo.OptionalTime{}):   reflect.ValueOf(wahoo.NullTime()),
+	}
+	updateType := reflect.TypeOf(newUpdate()).Elem()
+	cleared := 0
+	for i := 0; i < updateType.NumField(); i++ {
+		field := updateType.Field(i)
+		null, ok := nulls[field.Type]
+		if !ok {
+			continue
+		}
+		baseField, ok := base.FieldByName(field.Name)
+		if !ok {
+			t.Errorf("%s has no matching field on %s", field.Name, base.Name())
+			continue
+		}
+		key := prefix + "[" + strings.Split(baseField.Tag.Get("json"), ",")[0] + "]"
+
+		value := newUpdate()
+		reflect.ValueOf(value).Elem().Field(i).Set(null)
+		if err := update(client, value); err != nil {
+			t.Errorf("%s: %s", key, err.Error())
+			continue
+		}
+		if len(received) != 1 || len(received[key]) != 1 || received[key][0] != "" {
+			t.Errorf("%s: expected only that key to be sent empty, got %v", key, received)
+		}
+		cleared++
+	}
+	if cleared == 0 {
+		t.Fatalf("no optional fields found on %s", updateType.Name())
+	}
+}
+
+func TestUserUpdateNullRoundTrip(t *testing.T) {
+	runFormNullRoundTrip(t, func() interface{} { return &wahoo.UserUpdate{} }, reflect.TypeOf(wahoo.User{}), "user", func(client *wahoo.Client, value interface{}) error {
+		return client.UpdateUserFields("token", value.(*wahoo.UserUpdate))
+	})
+}
+
+func TestWorkoutUpdateNullRoundTrip(t *testing.T) {
+	runFormNullRoundTrip(t, func() interface{} { return &wahoo.WorkoutUpdate{ID: 1} }, reflect.TypeOf(wahoo.Workout{}), "workout", func(client *wahoo.Client, value interface{}) error {
+		return client.UpdateWorkoutFields("token", value.(*wahoo.WorkoutUpdate))
+	})
+}
+
+func TestHeartRateZoneUpdateNullRoundTrip(t *testing.T) {
+	runFormNullRoundTrip(t, func() interface{} { return &wahoo.HeartRateZoneUpdate{} }, reflect.TypeOf(wahoo.HeartRateZone{}), "heart_rate_zone", func(client *wahoo.Client, value interface{}) error {
+		return client.UpdateHeartRateZoneFields("token", value.(*wahoo.HeartRateZoneUpdate))
+	})
+}
+
+func TestPowerZoneUpdateNullRoundTrip(t *testing.T) {
+	runFormNullRoundTrip(t, func() interface{} { return &wahoo.PowerZoneUpdate{} }, reflect.TypeOf(wahoo.PowerZone{}), "power_zone", func(client *wahoo.Client, value interface{}) error {
+		return client.UpdatePowerZoneFields("token", value.(*wahoo.PowerZoneUpdate))
+	})
+}
+
+func TestUpdateClearsAndSetsFields(t *testing.T) {
+	var received map[string][]string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseMultipartForm(1 << 20)
+		received = r.MultipartForm.Value
+	}))
+	defer server.Close()
+	client := constructTestServerClient(t, server)
+
+	err := client.UpdateWorkoutFields("token", &wahoo.WorkoutUpdate{ID: 1, PlanID: wahoo.NullString(), Name: wahoo.SetString("Ride")})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(received) != 2 || received["workout[plan_id]"][0] != "" || received["workout[name]"][0] != "Ride" {
+		t.Errorf("expected plan_id cleared and name set, got %v", received)
+	}
+
+	if err := client.UpdateWorkoutFields("token", &wahoo.WorkoutUpdate{}); err == nil {
+		t.Error("expected an error without a workout ID")
+	}
+}
+
+func TestOptionalStates(t *testing.T) {
+	var unset wahoo.OptionalInt
+	if unset.IsSet() || unset.IsNull() {
+		t.Error("the zero value should be unset")
+	}
+	if _, ok := unset.Value(); ok {
+		t.Error("unset should not have a value")
+	}
+
+	null := wahoo.NullInt()
+	if !null.IsSet() || !null.IsNull() {
+		t.Error("null should be set and null")
+	}
+	if _, ok := null.Value(); ok {
+		t.Error("null should not have a value")
+	}
+
+	value := wahoo.SetInt(250)
+	if got, ok := value.Value(); !ok || got != 250 || value.IsNull() {
+		t.Errorf("expected 250, got %d %v", got, ok)
+	}
+
+	if wahoo.SetTime(time.Time{}).IsSet() {
+		t.Error("a zero time should be unset")
+	}
+}
+
+func TestZoneUpdateValidate(t *testing.T) {
+	//Clearing a zone is not checked, the values that are set still are
+	update := &wahoo.PowerZoneUpdate{Zone7: wahoo.NullInt(), Ftp: wahoo.SetInt(250)}
+	if err := update.Validate(); err != nil {
+		t.Errorf("expected a valid update, got %s", err.Error())
+	}
+	update.Ftp = wahoo.SetInt(0)
+	if err := update.Validate(); err == nil {
+		t.Error("expected an error for a zero ftp")
+	}
+
+	heartRate := &wahoo.HeartRateZoneUpdate{Zone1: wahoo.SetInt(150), Zone2: wahoo.SetInt(140), Maximum: wahoo.NullInt()}
+	if err := heartRate.Validate(); err == nil {
+		t.Error("expected an error for zones that go down")
+	}
+}
@@ -0,0 +1,128 @@
+package wahoo
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+const testPlanResponse = `{"id":12,"user_id":3,"name":"Sweet Spot","description":"3x10","file":{"url":"https://cdn.wahooligan.com/plans/12.json"},"workout_type_family_id":0,"workout_type_location_id":1,"external_id":"coach-42","provider_updated_at":"2020-06-01T07:30:00.000Z","deleted":false,"created_at":"2020-06-01T07:30:00.000Z","updated_at":"2020-06-01T07:30:00.000Z"}`
+
+func TestPlans_Offline(t *testing.T) {
+	planJSON := []byte(`{"header":{"name":"Sweet Spot"},"intervals":[]}`)
+	deleted := false
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v1/plans":
+			if r.URL.Query().Get("external_id") != "coach-42" {
+				t.Error("Expected the external id filter")
+			}
+			_, _ = w.Write([]byte("[" + testPlanResponse + "]"))
+		case r.Method == "POST" && r.URL.Path == "/v1/plans":
+			_ = r.ParseMultipartForm(1 << 20)
+			file := r.FormValue("plan[file]")
+			if !strings.HasPrefix(file, "data:application/json;base64,") {
+				t.Error("Expected a base64 data uri")
+			}
+			decoded, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(file, "data:application/json;base64,"))
+			if string(decoded) != string(planJSON) {
+				t.Error("Plan file does not match")
+			}
+			if r.FormValue("plan[external_id]") != "coach-42" || r.FormValue("plan[provider_updated_at]") != "2020-06-01T07:30:00.000Z" {
+				t.Error("Missing sync fields")
+			}
+			if r.FormValue("plan[filename]") != "plan.json" {
+				t.Error("Expected the default file name")
+			}
+			_, _ = w.Write([]byte(testPlanResponse))
+		case r.Method == "GET" && r.URL.Path == "/v1/plans/12":
+			_, _ = w.Write([]byte(testPlanResponse))
+		case r.Method == "PUT" && r.URL.Path == "/v1/plans/12":
+			_ = r.ParseMultipartForm(1 << 20)
+			if r.FormValue("plan[file]") != "" || r.FormValue("plan[external_id]") != "coach-43" {
+				t.Error("Expected only the external id to be sent")
+			}
+			_, _ = w.Write([]byte(testPlanResponse))
+		case r.Method == "DELETE" && r.URL.Path == "/v1/plans/12":
+			deleted = true
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := constructTestServerClient(t, server)
+
+	externalID := "coach-42"
+	updatedAt := time.Date(2020, time.June, 1, 7, 30, 0, 0, time.UTC)
+	created, err := client.CreatePlan("token", &wahoo.Plan{PlanFile: planJSON, ExternalID: &externalID, ProviderUpdatedAt: &updatedAt})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if created.ID != 12 || *created.Name != "Sweet Spot" || !created.ProviderUpdatedAt.Equal(updatedAt) || created.File.URL == "" {
+		t.Error("Created plan not decoded")
+	}
+
+	plans, err := client.ListPlans("token", "coach-42")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(plans) != 1 || *plans[0].ExternalID != "coach-42" {
+		t.Error("Expected one plan")
+	}
+
+	plan, err := client.GetPlan("token", 12)
+	if err != nil || plan.ID != 12 {
+		t.Fatal("Expected plan 12")
+	}
+
+	newExternalID := "coach-43"
+	if _, err := client.UpdatePlan("token", &wahoo.Plan{ID: 12, ExternalID: &newExternalID}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := client.DeletePlan("token", 12); err != nil || !deleted {
+		t.Error("Expected the plan to be deleted")
+	}
+
+	if _, err := client.GetPlan("token", 13); err == nil {
+		t.Error("Expected a 404 error")
+	}
+}
+
+func TestUpdatePlan_NameAndDescription(t *testing.T) {
+	var form map[string][]string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseMultipartForm(1 << 20)
+		form = r.MultipartForm.Value
+		_, _ = w.Write([]byte(testPlanResponse))
+	}))
+	defer server.Close()
+	client := constructTestServerClient(t, server)
+
+	name := "Sweet Spot 4x10"
+	description := "Four blocks at 90%"
+	if _, err := client.UpdatePlan("token", &wahoo.Plan{ID: 12, Name: &name, Description: &description}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(form) != 2 || form["plan[name]"][0] != name || form["plan[description]"][0] != description {
+		t.Errorf("Expected only the name and description, got %v", form)
+	}
+
+	if _, err := client.UpdatePlan("token", &wahoo.Plan{ID: 12, Name: &name}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, sent := form["plan[description]"]; sent || form["plan[name]"][0] != name {
+		t.Errorf("Expected the description to be left out when it is not set, got %v", form)
+	}
+}
@@ -0,0 +1,260 @@
+package wahoo
+
+import (
+	"encoding/base64"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+const testRouteResponse = `{"id":7,"user_id":3,"name":"Hill Loop","description":"Saturday loop","file":{"url":"https://cdn.wahooligan.com/routes/7.fit"},"workout_type_family_id":0,"external_id":"loop-1","provider_updated_at":"2020-06-01T07:30:00.000Z","start_lat":45.5,"start_lng":-122.6,"distance":42195.5,"ascent":650,"descent":648,"deleted":false,"created_at":"2020-06-01T07:30:00.000Z","updated_at":"2020-06-01T07:30:00.000Z"}`
+
+const testRouteGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test"><trk><trkseg><trkpt lat="45.5" lon="-122.6"/></trkseg></trk></gpx>`
+
+func TestRoutes_Offline(t *testing.T) {
+	fitRoute := buildTestWorkoutFitFile(60)
+	deleted := false
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v1/routes":
+			if r.URL.Query().Get("external_id") != "loop-1" {
+				t.Error("Expected the external id filter")
+			}
+			_, _ = w.Write([]byte("[" + testRouteResponse + "]"))
+		case r.Method == "POST" && r.URL.Path == "/v1/routes":
+			_ = r.ParseMultipartForm(1 << 20)
+			file := r.FormValue("route[file]")
+			if !strings.HasPrefix(file, "data:application/vnd.fit;base64,") {
+				t.Error("Expected a fit data uri")
+			}
+			decoded, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(file, "data:application/vnd.fit;base64,"))
+			if string(decoded) != string(fitRoute) {
+				t.Error("Route file does not match")
+			}
+			if r.FormValue("route[filename]") != "route.fit" || r.FormValue("route[name]") != "Hill Loop" {
+				t.Error("Wrong file name or name")
+			}
+			if r.FormValue("route[start_lat]") != "45.500000" || r.FormValue("route[distance]") != "42195.500000" ||
+				r.FormValue("route[ascent]") != "650.000000" || r.FormValue("route[descent]") != "0.000000" {
+				t.Error("Wrong route metadata")
+			}
+			_, _ = w.Write([]byte(testRouteResponse))
+		case r.Method == "GET" && r.URL.Path == "/v1/routes/7":
+			_, _ = w.Write([]byte(testRouteResponse))
+		case r.Method == "PUT" && r.URL.Path == "/v1/routes/7":
+			_ = r.ParseMultipartForm(1 << 20)
+			if !strings.HasPrefix(r.FormValue("route[file]"), "data:application/gpx+xml;base64,") ||
+				r.FormValue("route[filename]") != "loop.gpx" || r.FormValue("route[name]") != "" {
+				t.Error("Expected only the gpx file to be sent")
+			}
+			_, _ = w.Write([]byte(testRouteResponse))
+		case r.Method == "DELETE" && r.URL.Path == "/v1/routes/7":
+			deleted = true
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := constructTestServerClient(t, server)
+
+	name := "Hill Loop"
+	route := &wahoo.Route{
+		RouteFile: fitRoute,
+		Name:      &name,
+		StartLat:  floatPointer(45.5),
+		StartLng:  floatPointer(-122.6),
+		Distance:  floatPointer(42195.5),
+		Ascent:    floatPointer(650),
+	}
+	created, err := client.CreateRoute("token", route)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if created.ID != 7 || *created.Distance != 42195.5 || *created.Descent != 648 || created.File.URL == "" {
+		t.Error("Created route not decoded")
+	}
+
+	routes, err := client.ListRoutes("token", "loop-1")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(routes) != 1 || *routes[0].StartLng != -122.6 {
+		t.Error("Expected one route")
+	}
+
+	got, err := client.GetRoute("token", 7)
+	if err != nil || got.ID != 7 {
+		t.Fatal("Expected route 7")
+	}
+
+	fileName := "loop.gpx"
+	if _, err := client.UpdateRoute("token", &wahoo.Route{ID: 7, RouteFile: []byte(testRouteGPX), FileName: &fileName}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := client.DeleteRoute("token", 7); err != nil || !deleted {
+		t.Error("Expected the route to be deleted")
+	}
+
+	if _, err := client.GetRoute("token", 8); err == nil {
+		t.Error("Expected a 404 error")
+	}
+}
+
+func TestCreateRoute_Validation(t *testing.T) {
+	client := constructOfflineClient(t)
+	name := "Hill Loop"
+	if _, err := client.CreateRoute("token", &wahoo.Route{RouteFile: []byte(testRouteGPX)}); err == nil {
+		t.Error("Expected the missing name to be rejected")
+	}
+	route := &wahoo.Route{
+		RouteFile: []byte("not a route"),
+		Name:      &name,
+		StartLat:  floatPointer(45.5),
+		StartLng:  floatPointer(-122.6),
+		Distance:  floatPointer(1000),
+		Ascent:    floatPointer(10),
+	}
+	if _, err := client.CreateRoute("token", route); err != wahoo.ErrUnknownRouteFile {
+		t.Errorf("Expected ErrUnknownRouteFile, got %v", err)
+	}
+}
+
+//testAnalyzerGPX - ten points 0.001 degrees apart along the equator that climb 5m and drop 5m
+const testAnalyzerGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <metadata><name>Equator Hill</name></metadata>
+  <trk><name>Track</name><trkseg>
+    <trkpt lat="0" lon="0"><ele>100</ele></trkpt>
+    <trkpt lat="0" lon="0.001"><ele>100.4</ele></trkpt>
+    <trkpt lat="0" lon="0.002"><ele>100.8</ele></trkpt>
+    <trkpt lat="0" lon="0.003"><ele>103</ele></trkpt>
+    <trkpt lat="0.001" lon="0.004"><ele>105</ele></trkpt>
+  </trkseg><trkseg>
+    <trkpt lat="0" lon="0.005"><ele>105.2</ele></trkpt>
+    <trkpt lat="0" lon="0.006"><ele>104</ele></trkpt>
+    <trkpt lat="0" lon="0.007"><ele>102</ele></trkpt>
+    <trkpt lat="0" lon="0.008"><ele>101.5</ele></trkpt>
+    <trkpt lat="0" lon="0.009"><ele>100</ele></trkpt>
+  </trkseg></trk>
+</gpx>`
+
+func TestRouteAnalyzer_GPX(t *testing.T) {
+	analyzer := &wahoo.RouteAnalyzer{SmoothingDistance: -1}
+	analysis, err := analyzer.Analyze([]byte(testAnalyzerGPX))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if analysis.Name != "Equator Hill" || analysis.Points != 10 || !analysis.HasElevation {
+		t.Error("Wrong name or points")
+	}
+	//7 steps of 111.195m along the equator and two diagonal steps of 157.253m
+	if !closeTo(&analysis.Distance, 7*111.1951+2*157.2534) {
+		t.Errorf("Wrong distance %v", analysis.Distance)
+	}
+	if !closeTo(&analysis.Ascent, 5) || !closeTo(&analysis.Descent, 5) {
+		t.Errorf("Wrong ascent %v or descent %v", analysis.Ascent, analysis.Descent)
+	}
+	expectedBounds := wahoo.RouteBounds{MinLat: 0, MinLng: 0, MaxLat: 0.001, MaxLng: 0.009}
+	if analysis.Bounds != expectedBounds || analysis.StartLat != 0 || analysis.EndLng != 0.009 {
+		t.Errorf("Wrong bounds %+v", analysis.Bounds)
+	}
+}
+
+func TestRouteAnalyzer_Smoothing(t *testing.T) {
+	//A flat route with half a meter of noise on every point
+	points := []wahoo.RoutePoint{}
+	for i := 0; i < 100; i++ {
+		elevation := 50.0
+		if i%2 == 1 {
+			elevation += 0.5
+		}
+		points = append(points, wahoo.RoutePoint{Lat: 0, Lng: float64(i) * 0.0001, Elevation: floatPointer(elevation)})
+	}
+	analysis, err := (&wahoo.RouteAnalyzer{}).AnalyzePoints(points)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if analysis.Ascent != 0 || analysis.Descent != 0 {
+		t.Errorf("Expected the noise to be smoothed out, got %v up and %v down", analysis.Ascent, analysis.Descent)
+	}
+
+	if _, err := (&wahoo.RouteAnalyzer{}).AnalyzePoints(nil); err != wahoo.ErrEmptyRoute {
+		t.Error("Expected ErrEmptyRoute")
+	}
+}
+
+func TestRouteAnalyzer_RoutePointsAndFit(t *testing.T) {
+	gpx := `<gpx><rte><name>Planned</name><rtept lat="1" lon="2"/><rtept lat="1.001" lon="2"/></rte></gpx>`
+	analysis, err := wahoo.AnalyzeRouteFile([]byte(gpx))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if analysis.Name != "Planned" || analysis.Points != 2 || analysis.HasElevation || !closeTo(&analysis.Distance, 111.1951) {
+		t.Errorf("Wrong route point analysis %+v", analysis)
+	}
+
+	//The test ride moves north 0.0001 degrees a second and climbs 0.1m a second
+	analysis, err = wahoo.AnalyzeRouteFile(buildTestWorkoutFitFile(60))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if analysis.Points != 60 || !closeTo(&analysis.StartLat, 40) || !closeTo(&analysis.StartLng, -105) {
+		t.Errorf("Wrong fit analysis %+v", analysis)
+	}
+	if math.Abs(analysis.Distance-59*11.1195) > 0.1 || analysis.Ascent < 4 || analysis.Descent != 0 {
+		t.Errorf("Wrong fit distance %v or ascent %v", analysis.Distance, analysis.Ascent)
+	}
+
+	//Apply only fills in what is missing
+	name := "Mine"
+	route := &wahoo.Route{Name: &name, Ascent: floatPointer(1)}
+	analysis.Apply(route)
+	if *route.Name != "Mine" || *route.Ascent != 1 || *route.Distance != analysis.Distance || *route.StartLat != analysis.StartLat {
+		t.Error("Wrong values applied")
+	}
+}
+
+func TestUpdateRoute_NewFileReplacesFetchedMetadata(t *testing.T) {
+	var form map[string][]string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			_ = r.ParseMultipartForm(1 << 20)
+			form = r.MultipartForm.Value
+		}
+		_, _ = w.Write([]byte(testRouteResponse))
+	}))
+	defer server.Close()
+	client := constructTestServerClient(t, server)
+
+	route, err := client.GetRoute("token", 7)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	//The new file is a single point so it has no distance, ascent or descent.  The ascent is changed by the caller
+	route.RouteFile = []byte(testRouteGPX)
+	route.Ascent = floatPointer(12)
+	if _, err := client.UpdateRoute("token", route); err != nil {
+		t.Fatal(err.Error())
+	}
+	if form["route[distance]"][0] != "0.000000" || form["route[descent]"][0] != "0.000000" {
+		t.Errorf("Expected the distance and descent from the new file, got %v %v", form["route[distance]"], form["route[descent]"])
+	}
+	if form["route[ascent]"][0] != "12.000000" {
+		t.Errorf("Expected the ascent the caller set, got %v", form["route[ascent]"])
+	}
+	if *route.Distance != 42195.5 {
+		t.Error("Expected the route passed in not to change")
+	}
+}
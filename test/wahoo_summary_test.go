@@ -0,0 +1,138 @@
+package wahoo
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
+)
+
+func floatPointer(value float64) *float64 {
+	return &value
+}
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func closeTo(actual *float64, expected float64) bool {
+	return actual != nil && math.Abs(*actual-expected) < 0.01
+}
+
+//steadySamples - one sample per second at a constant power for the given number of seconds
+func steadySamples(start time.Time, seconds int, power float64) []wahoo.Sample {
+	samples := []wahoo.Sample{}
+	for i := 0; i <= seconds; i++ {
+		samples = append(samples, wahoo.Sample{
+			Time:     start.Add(time.Duration(i) * time.Second),
+			Power:    floatPointer(power),
+			Distance: floatPointer(float64(i) * 10),
+		})
+	}
+	return samples
+}
+
+func TestCalculateWorkoutSummary_SteadyHour(t *testing.T) {
+	samples := steadySamples(testWorkoutStart, 3600, 250)
+	summary, err := wahoo.CalculateWorkoutSummary(samples, &wahoo.PowerZone{Ftp: intPointer(250)})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	//An hour at FTP is 100 TSS by definition
+	if !closeTo(summary.PowerBikeNpLast, 250) || !closeTo(summary.PowerBikeTssLast, 100) {
+		t.Errorf("Wrong NP or TSS: %v %v", *summary.PowerBikeNpLast, *summary.PowerBikeTssLast)
+	}
+	if !closeTo(summary.WorkAccum, 900000) || !closeTo(summary.DistanceAccum, 36000) || !closeTo(summary.SpeedAvg, 10) {
+		t.Error("Wrong work, distance or speed")
+	}
+	if !closeTo(summary.DurationActiveAccum, 3600) || !closeTo(summary.DurationPausedAccum, 0) {
+		t.Error("Wrong durations")
+	}
+	if summary.HeartRateAvg != nil || summary.AscentAccum != nil {
+		t.Error("Expected no heart rate or ascent without data")
+	}
+}
+
+func TestCalculateWorkoutSummary_SubSecondSamples(t *testing.T) {
+	//An hour at 4Hz, half of each second at 300W and half at 200W
+	samples := []wahoo.Sample{}
+	for i := 0; i <= 4*3600; i++ {
+		power := 300.0
+		if i%4 >= 2 {
+			power = 200
+		}
+		samples = append(samples, wahoo.Sample{Time: testWorkoutStart.Add(time.Duration(i) * 250 * time.Millisecond), Power: floatPointer(power)})
+	}
+	summary, err := wahoo.CalculateWorkoutSummary(samples, &wahoo.PowerZone{Ftp: intPointer(250)})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	//Each second averages to 250W so it is the same as an hour at FTP
+	if !closeTo(summary.PowerBikeNpLast, 250) || !closeTo(summary.PowerBikeTssLast, 100) {
+		t.Errorf("Wrong NP or TSS: %v %v", summary.PowerBikeNpLast, summary.PowerBikeTssLast)
+	}
+}
+
+func TestCalculateWorkoutSummary_GapsArePaused(t *testing.T) {
+	samples := steadySamples(testWorkoutStart, 10, 200)
+	later := steadySamples(testWorkoutStart.Add(70*time.Second), 10, 100)
+	samples = append(samples, later...)
+	summary, err := wahoo.CalculateWorkoutSummary(samples, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !closeTo(summary.DurationTotalAccum, 80) || !closeTo(summary.DurationActiveAccum, 20) || !closeTo(summary.DurationPausedAccum, 60) {
+		t.Errorf("Wrong durations %v %v %v", *summary.DurationTotalAccum, *summary.DurationActiveAccum, *summary.DurationPausedAccum)
+	}
+	if !closeTo(summary.PowerAvg, 150) || summary.PowerBikeNpLast != nil || summary.PowerBikeTssLast != nil {
+		t.Error("Expected the average power and no NP for a 20 second workout")
+	}
+}
+
+func TestCalculateWorkoutSummary_Ascent(t *testing.T) {
+	altitudes := []float64{100, 101, 100.5, 103, 102, 110, 105, 106}
+	samples := []wahoo.Sample{}
+	for i, altitude := range altitudes {
+		samples = append(samples, wahoo.Sample{Time: testWorkoutStart.Add(time.Duration(i) * time.Second), Altitude: floatPointer(altitude)})
+	}
+	summary, err := wahoo.CalculateWorkoutSummary(samples, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	//100 -> 103 and 102 -> 110.  The 1m wobbles are ignored
+	if !closeTo(summary.AscentAccum, 11) {
+		t.Errorf("Wrong ascent %v", *summary.AscentAccum)
+	}
+}
+
+func TestCalculateWorkoutSummary_FromFitFile(t *testing.T) {
+	file, err := fit.DecodeBytes(buildTestWorkoutFitFile(120))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	summary, err := wahoo.CalculateWorkoutSummary(wahoo.SamplesFromFitFile(file), &wahoo.PowerZone{Ftp: intPointer(300)})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !closeTo(summary.DurationActiveAccum, 118) || !closeTo(summary.DurationPausedAccum, 11) {
+		t.Errorf("Wrong durations %v %v", *summary.DurationActiveAccum, *summary.DurationPausedAccum)
+	}
+	if !closeTo(summary.WorkAccum, 26700) || !closeTo(summary.CadenceAvg, 90) || !closeTo(summary.DistanceAccum, 1190) {
+		t.Error("Wrong work, cadence or distance")
+	}
+	if summary.PowerBikeNpLast == nil || *summary.PowerBikeNpLast <= *summary.PowerAvg || summary.PowerBikeTssLast == nil {
+		t.Error("Expected NP above the average power for a variable ride")
+	}
+}
+
+func TestCalculateWorkoutSummary_BadSamples(t *testing.T) {
+	if _, err := wahoo.CalculateWorkoutSummary(nil, nil); err == nil {
+		t.Error("Expected an error for no samples")
+	}
+	samples := []wahoo.Sample{{Time: testWorkoutStart.Add(time.Second)}, {Time: testWorkoutStart}}
+	if _, err := wahoo.CalculateWorkoutSummary(samples, nil); err == nil {
+		t.Error("Expected an error for samples out of order")
+	}
+}
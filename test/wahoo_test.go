@@ -153,7 +153,7 @@ func TestSetHeartRateZone(t *testing.T) {
 	zoneFour := 125
 	zoneFive := 150
 	maximum := 180
-	resting := 70
+	resting := 60
 
 	heartRateZone := &wahoo.HeartRateZone{}
 	heartRateZone.Zone1 = &zoneOne
@@ -0,0 +1,96 @@
+package wahoo
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+func TestUnitConversions(t *testing.T) {
+	tests := []struct {
+		got, expected float64
+	}{
+		{wahoo.Length(1609.344).Miles(), 1},
+		{wahoo.Length(0.3048).Feet(), 1},
+		{wahoo.Length(42195).Kilometers(), 42.195},
+		{wahoo.Mass(0.45359237).Pounds(), 1},
+		{wahoo.Speed(10).KilometersPerHour(), 36},
+		{wahoo.Speed(10).MilesPerHour(), 22.369362920544024},
+		{wahoo.Energy(612000).Kilojoules(), 612},
+		//And back again
+		{wahoo.LengthFromMiles(1).Meters(), 1609.344},
+		{wahoo.LengthFromKilometers(42.195).Meters(), 42195},
+		{(wahoo.LengthFromFeet(5) + wahoo.LengthFromInches(11)).Meters(), 1.8034},
+		{wahoo.MassFromPounds(1).Kilograms(), 0.45359237},
+		{wahoo.SpeedFromKilometersPerHour(36).MetersPerSecond(), 10},
+		{wahoo.SpeedFromMilesPerHour(22.369362920544024).MetersPerSecond(), 10},
+		{wahoo.EnergyFromKilojoules(612).Joules(), 612000},
+	}
+	for i, test := range tests {
+		if math.Abs(test.got-test.expected) > 1e-9 {
+			t.Errorf("%d: expected %v, got %v", i, test.expected, test.got)
+		}
+	}
+
+	formats := map[string]string{
+		wahoo.Length(42195).Long(wahoo.UnitSystemMetric).Format(1):   "42.2 km",
+		wahoo.Length(42195).Long(wahoo.UnitSystemImperial).Format(2): "26.22 mi",
+		wahoo.Length(1.8).Short(wahoo.UnitSystemMetric).Format(2):    "1.80 m",
+		wahoo.Length(650).Short(wahoo.UnitSystemImperial).Format(0):  "2133 ft",
+		wahoo.Mass(70).In(wahoo.UnitSystemImperial).Format(1):        "154.3 lb",
+		wahoo.Speed(10).In(wahoo.UnitSystemMetric).Format(1):         "36.0 km/h",
+		wahoo.Energy(612000).Quantity().Format(0):                    "612 kJ",
+	}
+	for got, expected := range formats {
+		if got != expected {
+			t.Errorf("Expected %s, got %s", expected, got)
+		}
+	}
+}
+
+func TestUnitSystem(t *testing.T) {
+	var preference struct {
+		Units wahoo.UnitSystem `json:"units"`
+	}
+	if err := json.Unmarshal([]byte(`{"units":"Imperial"}`), &preference); err != nil || preference.Units != wahoo.UnitSystemImperial {
+		t.Fatal("Expected imperial")
+	}
+	data, _ := json.Marshal(preference)
+	if string(data) != `{"units":"imperial"}` {
+		t.Error("Wrong JSON " + string(data))
+	}
+	if _, err := wahoo.ParseUnitSystem("furlongs"); err == nil {
+		t.Error("Expected an error for an unknown unit system")
+	}
+}
+
+func TestUnitAccessors(t *testing.T) {
+	user := &wahoo.User{}
+	if user.HeightLength() != nil || user.WeightMass() != nil {
+		t.Error("Expected nothing when height and weight are not set")
+	}
+	user.SetHeight(wahoo.Length(6 * 0.3048))
+	user.SetWeight(wahoo.Mass(160 * 0.45359237))
+	if !closeTo(user.Height, 1.8288) || math.Abs(user.HeightLength().Feet()-6) > 1e-9 || math.Abs(user.WeightMass().Pounds()-160) > 1e-9 {
+		t.Error("Expected height and weight to be stored in meters and kilograms")
+	}
+
+	summary := &wahoo.WorkoutSummary{
+		DistanceAccum:       floatPointer(25000),
+		AscentAccum:         floatPointer(300),
+		SpeedAvg:            floatPointer(8.5),
+		WorkAccum:           floatPointer(750000),
+		DurationActiveAccum: floatPointer(3600.5),
+		DurationTotalAccum:  floatPointer(3700),
+	}
+	if summary.Distance().Kilometers() != 25 || summary.Ascent().Meters() != 300 || summary.Speed().KilometersPerHour() != 30.6 ||
+		summary.Work().Kilojoules() != 750 {
+		t.Error("Wrong summary quantities")
+	}
+	if *summary.ActiveDuration() != time.Hour+500*time.Millisecond || *summary.TotalDuration() != 3700*time.Second || summary.PausedDuration() != nil {
+		t.Error("Wrong durations")
+	}
+}
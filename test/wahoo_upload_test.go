@@ -0,0 +1,168 @@
+package wahoo
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+//constructTestServerClient - a client that talks to the https test server instead of Wahoo
+func constructTestServerClient(t *testing.T, server *httptest.Server) *wahoo.Client {
+	client := constructOfflineClient(t)
+	client.SetHTTPClient(server.Client())
+	client.SetBaseURL(server.Listener.Addr().String())
+	return client
+}
+
+func TestCreateWorkoutFromFile_Offline(t *testing.T) {
+	fitFile := buildTestWorkoutFitFile(120)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Method != "POST" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/workouts":
+			if r.FormValue("workout[name]") != "Morning Ride" || r.FormValue("workout[workout_token]") != "abc-123" {
+				t.Error("Missing workout metadata")
+			}
+			//Taken from the FIT session
+			if r.FormValue("workout[starts]") != "2020-06-01T07:30:00.000Z" || r.FormValue("workout[minutes]") != "3" {
+				t.Error("Expected starts and minutes from the file. Got " + r.FormValue("workout[starts]") + " " + r.FormValue("workout[minutes]"))
+			}
+			_, _ = w.Write([]byte(`{"id":77,"name":"Morning Ride","workout_token":"abc-123","workout_type_id":0}`))
+		case "/v1/workouts/77/workout_summary":
+			file, _, err := r.FormFile("workout_summary[file]")
+			if err != nil {
+				t.Error(err.Error())
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			uploaded, _ := ioutil.ReadAll(file)
+			if !bytes.Equal(uploaded, fitFile) {
+				t.Error("Uploaded file does not match")
+			}
+			_, _ = w.Write([]byte(`{"id":9,"power_avg":"225.0","file":{"url":"https://cdn.wahooligan.com/77.fit"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := constructTestServerClient(t, server)
+
+	name := "Morning Ride"
+	token := "abc-123"
+	workoutType := int(wahoo.Biking)
+	workout, err := client.CreateWorkoutFromFile("token", &wahoo.Workout{Name: &name, WorkoutToken: &token, WorkoutTypeID: &workoutType}, bytes.NewReader(fitFile))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if workout.ID != 77 || workout.WorkoutSummary == nil || workout.WorkoutSummary.ID != 9 {
+		t.Fatal("Expected the created workout with its summary")
+	}
+	if *workout.WorkoutSummary.PowerAvg != 225 || workout.WorkoutSummary.File.URL == "" {
+		t.Error("Summary not decoded")
+	}
+}
+
+func TestCreateWorkoutFromFile_NotFitFile(t *testing.T) {
+	client := constructOfflineClient(t)
+	token := "abc-123"
+	workoutType := int(wahoo.Biking)
+	_, err := client.CreateWorkoutFromFile("token", &wahoo.Workout{WorkoutToken: &token, WorkoutTypeID: &workoutType}, bytes.NewReader([]byte("gpx")))
+	if err == nil {
+		t.Error("Expected an error for a file that is not FIT")
+	}
+	_, err = client.CreateWorkoutFromFile("token", &wahoo.Workout{}, bytes.NewReader(buildTestWorkoutFitFile(10)))
+	if err == nil {
+		t.Error("Expected an error for missing metadata")
+	}
+}
+
+func TestCreateWorkoutFromFile_StartsAndMinutesSet(t *testing.T) {
+	//The file is not decoded when nothing has to be taken from it, so one the decoder can not read is still uploaded
+	fileData := []byte("a file the decoder can not read")
+	var uploaded []byte
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseMultipartForm(1 << 20)
+		switch r.URL.Path {
+		case "/v1/workouts":
+			_, _ = w.Write([]byte(`{"id":77}`))
+		case "/v1/workouts/77/workout_summary":
+			file, _, err := r.FormFile("workout_summary[file]")
+			if err == nil {
+				uploaded, _ = ioutil.ReadAll(file)
+			}
+			_, _ = w.Write([]byte(`{"id":9}`))
+		}
+	}))
+	defer server.Close()
+	client := constructTestServerClient(t, server)
+
+	token := "abc-123"
+	workoutType := int(wahoo.Biking)
+	starts := time.Date(2020, 6, 1, 7, 30, 0, 0, time.UTC)
+	minutes := 60
+	workout := &wahoo.Workout{WorkoutToken: &token, WorkoutTypeID: &workoutType, Starts: &starts, Minutes: &minutes}
+	if _, err := client.CreateWorkoutFromFile("token", workout, bytes.NewReader(fileData)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(uploaded, fileData) {
+		t.Error("Expected the file to be uploaded as it is")
+	}
+}
+
+func TestCreateWorkoutFromFile_UploadFails(t *testing.T) {
+	deleteStatus := http.StatusNoContent
+	deleted := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/workouts":
+			_, _ = w.Write([]byte(`{"id":77}`))
+		case r.Method == "POST" && r.URL.Path == "/v1/workouts/77/workout_summary":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		case r.Method == "DELETE" && r.URL.Path == "/v1/workouts/77":
+			deleted++
+			w.WriteHeader(deleteStatus)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := constructTestServerClient(t, server)
+
+	token := "abc-123"
+	workoutType := int(wahoo.Biking)
+	newWorkout := func() *wahoo.Workout {
+		return &wahoo.Workout{WorkoutToken: &token, WorkoutTypeID: &workoutType}
+	}
+
+	workout, err := client.CreateWorkoutFromFile("token", newWorkout(), bytes.NewReader(buildTestWorkoutFitFile(10)))
+	var fileErr *wahoo.WorkoutFileError
+	if workout != nil || !errors.As(err, &fileErr) || !fileErr.Deleted || deleted != 1 {
+		t.Fatalf("Expected the workout to be deleted after the upload failed: %v", err)
+	}
+	var wahooErr wahoo.ErrorResponse
+	if !errors.As(err, &wahooErr) || wahooErr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected the upload error to be wrapped: %v", err)
+	}
+
+	//When the delete fails too the caller gets the workout to clean up
+	deleteStatus = http.StatusInternalServerError
+	_, err = client.CreateWorkoutFromFile("token", newWorkout(), bytes.NewReader(buildTestWorkoutFitFile(10)))
+	if !errors.As(err, &fileErr) || fileErr.Deleted || fileErr.DeleteErr == nil || fileErr.Workout == nil || fileErr.Workout.ID != 77 {
+		t.Errorf("Expected the workout left behind in the error: %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+package wahoo
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+func TestWebhookEventID(t *testing.T) {
+	first, _ := wahoo.ParseWebhookEvent([]byte(testWebhookPayload))
+	again, _ := wahoo.ParseWebhookEvent([]byte(strings.Replace(testWebhookPayload, `"612.0"`, `"613.0"`, 1)))
+	updated, _ := wahoo.ParseWebhookEvent([]byte(strings.Replace(testWebhookPayload, "2020-06-01T08:32:00.000Z", "2020-06-01T09:00:00.000Z", 1)))
+	if first.ID() != "workout_summary:workout_summary:2011:2020-06-01T08:32:00Z" {
+		t.Error("Wrong ID: " + first.ID())
+	}
+	if first.ID() != again.ID() || first.ID() == updated.ID() {
+		t.Error("Expected the ID to come from the summary ID and UpdatedAt")
+	}
+
+	user, _ := wahoo.ParseWebhookEvent([]byte(`{"event_type":"user","user":{"id":1}}`))
+	other, _ := wahoo.ParseWebhookEvent([]byte(`{"event_type":"user","user":{"id":2}}`))
+	if !strings.HasPrefix(user.ID(), "user:payload:") || user.ID() == other.ID() {
+		t.Error("Expected a payload hash without an UpdatedAt: " + user.ID())
+	}
+}
+
+//testQueueSemantics - the behaviour every WebhookQueue has to have
+func testQueueSemantics(t *testing.T, queue wahoo.WebhookQueue) {
+	now := time.Now()
+	item := &wahoo.QueuedWebhook{ID: "a", Payload: []byte("{}"), ReceivedAt: now, NextAttempt: now}
+	if added, err := queue.Enqueue(item); !added || err != nil {
+		t.Fatal("Expected the webhook to be added")
+	}
+	if added, _ := queue.Enqueue(item); added {
+		t.Error("Expected a pending duplicate to be dropped")
+	}
+	_, _ = queue.Enqueue(&wahoo.QueuedWebhook{ID: "b", ReceivedAt: now.Add(time.Second), NextAttempt: now})
+
+	dequeued, err := queue.Dequeue(now)
+	if err != nil || dequeued == nil || dequeued.ID != "a" {
+		t.Fatal("Expected a to be dequeued first")
+	}
+	second, _ := queue.Dequeue(now)
+	if second == nil || second.ID != "b" {
+		t.Fatal("Expected b to be dequeued next")
+	}
+	if none, _ := queue.Dequeue(now); none != nil {
+		t.Error("Expected nothing while both are being processed")
+	}
+
+	dequeued.Attempts = 1
+	dequeued.NextAttempt = now.Add(time.Minute)
+	if err := queue.Retry(dequeued); err != nil {
+		t.Fatal(err.Error())
+	}
+	if none, _ := queue.Dequeue(now); none != nil {
+		t.Error("Expected the retry to wait for its next attempt")
+	}
+	retried, _ := queue.Dequeue(now.Add(2 * time.Minute))
+	if retried == nil || retried.Attempts != 1 {
+		t.Fatal("Expected the retry once it is due")
+	}
+	if err := queue.Complete(retried); err != nil {
+		t.Fatal(err.Error())
+	}
+	if added, _ := queue.Enqueue(item); added {
+		t.Error("Expected a completed duplicate to be dropped")
+	}
+	if err := queue.Fail(second); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := queue.Complete(second); err == nil {
+		t.Error("Expected an error completing a webhook that was not dequeued")
+	}
+}
+
+func TestMemoryWebhookQueue(t *testing.T) {
+	queue := wahoo.ConstructMemoryWebhookQueue(0)
+	testQueueSemantics(t, queue)
+	if queue.Len() != 0 || len(queue.Failed()) != 1 || queue.Failed()[0].ID != "b" {
+		t.Error("Expected b to have failed")
+	}
+}
+
+func TestFileWebhookQueue(t *testing.T) {
+	directory := t.TempDir()
+	queue, err := wahoo.ConstructFileWebhookQueue(directory, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	testQueueSemantics(t, queue)
+	failed, err := queue.Failed()
+	if err != nil || len(failed) != 1 || failed[0].ID != "b" {
+		t.Error("Expected b to have failed")
+	}
+
+	//A webhook that was being processed when the process stopped is pending again after a restart
+	now := time.Now()
+	_, _ = queue.Enqueue(&wahoo.QueuedWebhook{ID: "c", Payload: []byte(`{"x":1}`), ReceivedAt: now, NextAttempt: now})
+	if dequeued, _ := queue.Dequeue(now); dequeued == nil {
+		t.Fatal("Expected c")
+	}
+	reopened, err := wahoo.ConstructFileWebhookQueue(directory, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if reopened.Len() != 1 {
+		t.Fatalf("Expected c to be pending, got %d", reopened.Len())
+	}
+	restored, _ := reopened.Dequeue(now)
+	if restored == nil || restored.ID != "c" || string(restored.Payload) != `{"x":1}` {
+		t.Fatal("Expected c to be restored")
+	}
+	//The completed webhook from before the restart is still deduplicated
+	if added, _ := reopened.Enqueue(&wahoo.QueuedWebhook{ID: "a"}); added {
+		t.Error("Expected the dedup to survive a restart")
+	}
+}
+
+func TestWebhookProcessor(t *testing.T) {
+	receiver, _ := wahoo.ConstructWebhookReceiver(testWebhookToken)
+	var mutex sync.Mutex
+	calls := 0
+	release := make(chan struct{})
+	done := make(chan struct{}, 10)
+	receiver.Handle(wahoo.WebhookEventWorkoutSummary, func(ctx context.Context, event *wahoo.WebhookEvent) error {
+		<-release
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls++
+		done <- struct{}{}
+		if calls < 3 {
+			return errors.New("try again")
+		}
+		return nil
+	})
+
+	queue := wahoo.ConstructMemoryWebhookQueue(0)
+	processor, err := wahoo.ConstructWebhookProcessor(receiver, queue)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	processor.SetWorkers(2)
+	processor.SetRetryPolicy(3, time.Millisecond)
+	processor.SetPollInterval(time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- processor.Run(ctx) }()
+
+	send := func(body string) int {
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest("POST", "/webhook", strings.NewReader(body)))
+		return recorder.Code
+	}
+
+	//Acknowledged while the handler is still blocked, and the redelivery is dropped
+	if code := send(testWebhookPayload); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if code := send(testWebhookPayload); code != http.StatusOK || queue.Len() != 1 {
+		t.Errorf("Expected the redelivery to be acknowledged and dropped, got %d", code)
+	}
+	if code := send(strings.Replace(testWebhookPayload, testWebhookToken, "forged", 1)); code != http.StatusUnauthorized {
+		t.Errorf("Expected a forged token to be rejected, got %d", code)
+	}
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the retries")
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for queue.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if queue.Len() != 0 || len(queue.Failed()) != 0 {
+		t.Error("Expected the webhook to complete on the third attempt")
+	}
+	if code := send(testWebhookPayload); code != http.StatusOK || queue.Len() != 0 {
+		t.Error("Expected a redelivery after processing to be dropped")
+	}
+
+	cancel()
+	if err := <-stopped; err != context.Canceled {
+		t.Errorf("Expected Run to stop with the context, got %v", err)
+	}
+}
+
+func TestWebhookProcessor_Fail(t *testing.T) {
+	receiver, _ := wahoo.ConstructWebhookReceiver(testWebhookToken)
+	receiver.Handle(wahoo.WebhookEventWorkoutSummary, func(ctx context.Context, event *wahoo.WebhookEvent) error {
+		return errors.New("always broken")
+	})
+	queue := wahoo.ConstructMemoryWebhookQueue(0)
+	processor, _ := wahoo.ConstructWebhookProcessor(receiver, queue)
+	processor.SetRetryPolicy(2, 0)
+	processor.SetPollInterval(time.Millisecond)
+
+	event, _ := wahoo.ParseWebhookEvent([]byte(testWebhookPayload))
+	if added, err := processor.Enqueue(event); !added || err != nil {
+		t.Fatal("Expected the event to be queued")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+	for len(queue.Failed()) == 0 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	failed := queue.Failed()
+	if len(failed) != 1 || failed[0].Attempts != 2 || failed[0].LastError != "always broken" {
+		t.Errorf("Expected the webhook to fail after 2 attempts: %+v", failed)
+	}
+}
+
+func TestFileWebhookQueue_CrashRecovery(t *testing.T) {
+	directory := t.TempDir()
+	queue, err := wahoo.ConstructFileWebhookQueue(directory, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	now := time.Now()
+	_, _ = queue.Enqueue(&wahoo.QueuedWebhook{ID: "done", ReceivedAt: now, NextAttempt: now})
+	pendingFiles, _ := filepath.Glob(filepath.Join(directory, "pending", "*.json"))
+	if len(pendingFiles) != 1 {
+		t.Fatalf("Expected one pending file, got %d", len(pendingFiles))
+	}
+	saved, _ := ioutil.ReadFile(pendingFiles[0])
+	savedInfo, _ := os.Stat(pendingFiles[0])
+
+	dequeued, _ := queue.Dequeue(now)
+	if err := queue.Complete(dequeued); err != nil {
+		t.Fatal(err.Error())
+	}
+	//A crash after the completed marker was written but before the pending file was removed
+	_ = ioutil.WriteFile(pendingFiles[0], saved, 0600)
+	_ = os.Chtimes(pendingFiles[0], savedInfo.ModTime(), savedInfo.ModTime())
+	//And a write that never got renamed
+	_ = ioutil.WriteFile(filepath.Join(directory, "pending", ".tmp-123"), []byte("{"), 0600)
+
+	reopened, err := wahoo.ConstructFileWebhookQueue(directory, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if reopened.Len() != 0 {
+		t.Errorf("Expected the completed webhook not to be pending again, got %d", reopened.Len())
+	}
+	if remaining, _ := filepath.Glob(filepath.Join(directory, "pending", "*")); len(remaining) != 0 {
+		t.Errorf("Expected the pending directory to be cleaned up, got %v", remaining)
+	}
+}
+
+func TestFileWebhookQueue_Concurrent(t *testing.T) {
+	queue, err := wahoo.ConstructFileWebhookQueue(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	now := time.Now()
+	for i := 0; i < 20; i++ {
+		_, _ = queue.Enqueue(&wahoo.QueuedWebhook{ID: strconv.Itoa(i), ReceivedAt: now, NextAttempt: now})
+	}
+	//Workers claim and ack at the same time, each webhook has to be handed out exactly once
+	var mutex sync.Mutex
+	seen := map[string]int{}
+	var group sync.WaitGroup
+	for worker := 0; worker < 4; worker++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for {
+				item, err := queue.Dequeue(now)
+				if err != nil || item == nil {
+					return
+				}
+				mutex.Lock()
+				seen[item.ID]++
+				mutex.Unlock()
+				if err := queue.Complete(item); err != nil {
+					t.Error(err.Error())
+				}
+			}
+		}()
+	}
+	group.Wait()
+	if len(seen) != 20 || queue.Len() != 0 {
+		t.Fatalf("Expected all 20 to be processed, got %d with %d left", len(seen), queue.Len())
+	}
+	for id, count := range seen {
+		if count != 1 {
+			t.Errorf("%s was handed out %d times", id, count)
+		}
+	}
+}
+
+func TestWebhookProcessor_Shutdown(t *testing.T) {
+	receiver, _ := wahoo.ConstructWebhookReceiver(testWebhookToken)
+	started := make(chan struct{})
+	receiver.Handle(wahoo.WebhookEventWorkoutSummary, func(ctx context.Context, event *wahoo.WebhookEvent) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	queue := wahoo.ConstructMemoryWebhookQueue(0)
+	processor, _ := wahoo.ConstructWebhookProcessor(receiver, queue)
+	processor.SetRetryPolicy(1, time.Hour)
+	processor.SetPollInterval(time.Millisecond)
+
+	event, _ := wahoo.ParseWebhookEvent([]byte(testWebhookPayload))
+	if added, err := processor.Enqueue(event); !added || err != nil {
+		t.Fatal("Expected the event to be queued")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- processor.Run(ctx) }()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the handler")
+	}
+	cancel()
+	<-stopped
+
+	//With one attempt allowed a counted failure would have failed it, it has to be pending and due
+	if len(queue.Failed()) != 0 || queue.Len() != 1 {
+		t.Fatalf("Expected the webhook to be pending after a shutdown, %d failed", len(queue.Failed()))
+	}
+	item, _ := queue.Dequeue(time.Now())
+	if item == nil || item.Attempts != 0 {
+		t.Errorf("Expected the webhook to be due without an attempt used: %+v", item)
+	}
+}
@@ -0,0 +1,108 @@
+package wahoo
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+func testSimulatedWorkout() (*wahoo.Workout, *wahoo.WorkoutSummary) {
+	name := "Simulated Ride"
+	minutes := 45
+	starts := time.Date(2020, time.June, 1, 7, 30, 0, 0, time.UTC)
+	summary := &wahoo.WorkoutSummary{
+		ID:            77,
+		PowerAvg:      floatPointer(201.5),
+		DistanceAccum: floatPointer(25000),
+		UpdatedAt:     starts.Add(time.Hour),
+		File:          &wahoo.File{URL: "https://example.com/77.fit"},
+	}
+	workout := &wahoo.Workout{ID: 12, Name: &name, Minutes: &minutes, Starts: &starts, WorkoutTypeID: intPointer(0)}
+	return workout, summary
+}
+
+func TestBuildWorkoutSummaryWebhook(t *testing.T) {
+	workout, summary := testSimulatedWorkout()
+	payload, err := wahoo.BuildWorkoutSummaryWebhook(testWebhookToken, 5, workout, summary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(payload), `"power_avg":"201.5"`) || !strings.Contains(string(payload), `"updated_at":"2020-06-01T08:30:00.000Z"`) {
+		t.Error("Expected the summary in the Wahoo format: " + string(payload))
+	}
+
+	event, err := wahoo.ParseWebhookEvent(payload)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if event.User.ID != 5 || event.WebhookToken != testWebhookToken || event.WorkoutSummary.ID != 77 ||
+		!closeTo(event.WorkoutSummary.PowerAvg, 201.5) || event.WorkoutSummary.HeartRateAvg != nil ||
+		!event.WorkoutSummary.UpdatedAt.Equal(summary.UpdatedAt) || event.WorkoutSummary.File.URL != summary.File.URL {
+		t.Error("Summary did not round trip")
+	}
+	if event.Workout.ID != 12 || *event.Workout.Name != "Simulated Ride" || !event.Workout.Starts.Equal(*workout.Starts) {
+		t.Error("Workout did not round trip")
+	}
+
+	if _, err := wahoo.BuildWorkoutSummaryWebhook(testWebhookToken, 5, nil, nil); err == nil {
+		t.Error("Expected an error without a summary")
+	}
+}
+
+func TestWebhookSimulator_RecordAndReplay(t *testing.T) {
+	receiver, _ := wahoo.ConstructWebhookReceiver(testWebhookToken)
+	var mutex sync.Mutex
+	received := []*wahoo.WebhookEvent{}
+	receiver.Handle(wahoo.WebhookEventWorkoutSummary, func(ctx context.Context, event *wahoo.WebhookEvent) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		received = append(received, event)
+		return nil
+	})
+
+	recording := &bytes.Buffer{}
+	server := httptest.NewServer(wahoo.RecordWebhooks(receiver, recording))
+	defer server.Close()
+
+	simulator, err := wahoo.ConstructWebhookSimulator(server.URL, testWebhookToken)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	workout, summary := testSimulatedWorkout()
+	status, err := simulator.SendWorkoutSummary(context.Background(), 5, workout, summary)
+	if err != nil || status != 200 {
+		t.Fatalf("Expected the webhook to be accepted, got %d %v", status, err)
+	}
+	summary.ID = 78
+	if status, _ := simulator.SendWorkoutSummary(context.Background(), 5, workout, summary); status != 200 {
+		t.Fatal("Expected the second webhook to be accepted")
+	}
+	if len(received) != 2 || received[1].WorkoutSummary.ID != 78 {
+		t.Fatal("Expected both webhooks to be handled")
+	}
+	if strings.Count(recording.String(), "\n") != 2 {
+		t.Fatal("Expected two recorded webhooks:\n" + recording.String())
+	}
+
+	//A recording made with another token is signed again with the simulator's token when it is replayed
+	foreign := strings.Replace(recording.String(), testWebhookToken, "production-token", -1)
+	sent, err := simulator.Replay(context.Background(), strings.NewReader(foreign), false)
+	if err != nil || sent != 2 {
+		t.Fatalf("Expected 2 replayed, got %d %v", sent, err)
+	}
+	if len(received) != 4 || received[2].WorkoutSummary.ID != 77 || received[3].WorkoutSummary.ID != 78 {
+		t.Error("Expected the replay in the recorded order")
+	}
+
+	//A simulator with the wrong token is rejected and the replay stops
+	wrong, _ := wahoo.ConstructWebhookSimulator(server.URL, "wrong")
+	if sent, err := wrong.Replay(context.Background(), strings.NewReader(foreign), false); err == nil || sent != 0 {
+		t.Error("Expected the replay to stop at the 401")
+	}
+}
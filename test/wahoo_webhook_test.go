@@ -0,0 +1,187 @@
+package wahoo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+)
+
+const testWebhookToken = "97661c16-6359-4854-9498-a49c07b7fa46"
+
+//testWebhookPayload - a workout_summary webhook as Wahoo sends it (decimals as strings)
+const testWebhookPayload = `{
+  "event_type": "workout_summary",
+  "webhook_token": "97661c16-6359-4854-9498-a49c07b7fa46",
+  "user": {"id": 60462},
+  "workout_summary": {
+    "id": 2011,
+    "ascent_accum": "12.5",
+    "cadence_avg": "88.0",
+    "calories_accum": "612.0",
+    "distance_accum": "30124.4",
+    "duration_active_accum": "3540.0",
+    "duration_paused_accum": "60.0",
+    "duration_total_accum": "3600.0",
+    "heart_rate_avg": "141.0",
+    "power_avg": "212.0",
+    "power_bike_np_last": "225.0",
+    "power_bike_tss_last": "72.5",
+    "speed_avg": "8.5",
+    "work_accum": "750000.0",
+    "created_at": "2020-06-01T08:31:00.000Z",
+    "updated_at": "2020-06-01T08:32:00.000Z",
+    "file": {"url": "https://cdn.wahooligan.com/wahoo-cloud/production/uploads/workout_file/file/1/2011.fit"},
+    "workout": {
+      "id": 1401,
+      "starts": "2020-06-01T07:30:00.000Z",
+      "minutes": 60,
+      "name": "Morning Ride",
+      "workout_token": "ELEMNT:ABC",
+      "workout_type_id": 0,
+      "created_at": "2020-06-01T08:31:00.000Z",
+      "updated_at": "2020-06-01T08:31:00.000Z"
+    }
+  }
+}`
+
+func TestParseWebhookEvent(t *testing.T) {
+	event, err := wahoo.ParseWebhookEvent([]byte(testWebhookPayload))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if event.EventType != wahoo.WebhookEventWorkoutSummary || event.User.ID != 60462 {
+		t.Error("Wrong event type or user")
+	}
+	summary := event.WorkoutSummary
+	if summary.ID != 2011 || !closeTo(summary.PowerAvg, 212) || !closeTo(summary.WorkAccum, 750000) || summary.File.URL == "" {
+		t.Error("Workout summary not decoded")
+	}
+	if event.Workout == nil || event.Workout.ID != 1401 || *event.Workout.Name != "Morning Ride" || event.Workout.WorkoutSummary != summary {
+		t.Error("Expected the nested workout")
+	}
+
+	if _, err := wahoo.ParseWebhookEvent([]byte(`{"webhook_token":"x"}`)); err == nil {
+		t.Error("Expected an error without an event type")
+	}
+	//A value that is not a number only loses that value, not the whole event
+	event, err = wahoo.ParseWebhookEvent([]byte(`{"event_type":"workout_summary","workout_summary":{"power_avg":"fast","speed_avg":"8.5"}}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if event.WorkoutSummary.PowerAvg != nil || !closeTo(event.WorkoutSummary.SpeedAvg, 8.5) {
+		t.Error("Expected only the bad value to be dropped")
+	}
+	if _, err := wahoo.ParseWebhookEvent([]byte(`{"event_type":"workout_summary","workout_summary":{"power_avg":{"x":1}}}`)); err == nil {
+		t.Error("Expected an error for a badly typed summary")
+	}
+}
+
+func TestWorkoutSummaryUnmarshalNumbers(t *testing.T) {
+	//Wahoo sends the decimals as strings but numbers have to decode the same way
+	summary := &wahoo.WorkoutSummary{}
+	body := `{"id":9,"power_avg":212.5,"heart_rate_avg":141,"distance_accum":"30124.4","work_accum":750000}`
+	if err := json.Unmarshal([]byte(body), summary); err != nil {
+		t.Fatal(err.Error())
+	}
+	if summary.ID != 9 || !closeTo(summary.PowerAvg, 212.5) || !closeTo(summary.HeartRateAvg, 141) ||
+		!closeTo(summary.DistanceAccum, 30124.4) || !closeTo(summary.WorkAccum, 750000) {
+		t.Error("Expected the numeric values to be decoded")
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(`{"speed_avg":8.25}`))
+	decoder.UseNumber()
+	summary = &wahoo.WorkoutSummary{}
+	if err := decoder.Decode(summary); err != nil || !closeTo(summary.SpeedAvg, 8.25) {
+		t.Error("Expected a decoder using json.Number to work too")
+	}
+}
+
+func TestWorkoutSummaryUnmarshalBadTypes(t *testing.T) {
+	//The same decoding is used for GetWorkoutSummary so a bad value is an error there too rather than a panic
+	for _, body := range []string{`{"speed_avg":{"x":1}}`, `{"work_accum":true}`, `{"heart_rate_avg":[1]}`} {
+		summary := &wahoo.WorkoutSummary{}
+		if err := json.Unmarshal([]byte(body), summary); err == nil {
+			t.Errorf("Expected an error for %s", body)
+		}
+	}
+	summary := &wahoo.WorkoutSummary{}
+	if err := json.Unmarshal([]byte(`{"speed_avg":null,"power_avg":""}`), summary); err != nil || summary.SpeedAvg != nil || summary.PowerAvg != nil {
+		t.Error("Expected null and empty values to be left unset")
+	}
+}
+
+func TestVerifyWebhookToken(t *testing.T) {
+	if !wahoo.VerifyWebhookToken(testWebhookToken, testWebhookToken) {
+		t.Error("Expected the same token to verify")
+	}
+	if wahoo.VerifyWebhookToken(testWebhookToken, "wrong") || wahoo.VerifyWebhookToken("", "") {
+		t.Error("Expected the wrong or empty token to fail")
+	}
+}
+
+func TestWebhookReceiver(t *testing.T) {
+	if _, err := wahoo.ConstructWebhookReceiver(""); err == nil {
+		t.Error("Expected the token to be required")
+	}
+	receiver, err := wahoo.ConstructWebhookReceiver(testWebhookToken)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var summaries, defaults int
+	failing := false
+	receiver.Handle(wahoo.WebhookEventWorkoutSummary, func(ctx context.Context, event *wahoo.WebhookEvent) error {
+		if failing {
+			return errors.New("database down")
+		}
+		summaries++
+		return nil
+	})
+	receiver.HandleDefault(func(ctx context.Context, event *wahoo.WebhookEvent) error {
+		defaults++
+		return nil
+	})
+
+	send := func(method, body string) int {
+		recorder := httptest.NewRecorder()
+		receiver.ServeHTTP(recorder, httptest.NewRequest(method, "/webhook", strings.NewReader(body)))
+		return recorder.Code
+	}
+
+	if code := send("POST", testWebhookPayload); code != http.StatusOK || summaries != 1 {
+		t.Errorf("Expected the summary to be handled, got %d", code)
+	}
+	if code := send("POST", `{"event_type":"user","webhook_token":"`+testWebhookToken+`","user":{"id":1}}`); code != http.StatusOK || defaults != 1 {
+		t.Errorf("Expected the default handler, got %d", code)
+	}
+	if code := send("POST", strings.Replace(testWebhookPayload, testWebhookToken, "forged", 1)); code != http.StatusUnauthorized || summaries != 1 {
+		t.Errorf("Expected a forged token to be rejected, got %d", code)
+	}
+	if code := send("GET", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", code)
+	}
+	//The token is checked before the event is decoded so anything without a valid token is a 401
+	for _, body := range []string{"{not json", `{"webhook_token":1}`, `{"event_type":"workout_summary","workout_summary":{"power_avg":{}}}`} {
+		if code := send("POST", body); code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %s, got %d", body, code)
+		}
+	}
+	if code := send("POST", `{"webhook_token":"`+testWebhookToken+`"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a verified payload without an event type, got %d", code)
+	}
+	receiver.SetMaxBodySize(100)
+	if code := send("POST", testWebhookPayload); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d", code)
+	}
+	receiver.SetMaxBodySize(1 << 20)
+	failing = true
+	if code := send("POST", testWebhookPayload); code != http.StatusInternalServerError {
+		t.Errorf("Expected a handler error to be a 500 so Wahoo retries, got %d", code)
+	}
+}
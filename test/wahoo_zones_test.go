@@ -0,0 +1,291 @@
+package wahoo
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	wahoo "github.com/reddiyo-os/wahoo_cloud_client/pkg"
+	"github.com/reddiyo-os/wahoo_cloud_client/pkg/fit"
+)
+
+func heartRateBounds(zones *wahoo.HeartRateZone) []int {
+	bounds := []int{}
+	for _, bound := range []*int{zones.Zone1, zones.Zone2, zones.Zone3, zones.Zone4, zones.Zone5} {
+		if bound != nil {
+			bounds = append(bounds, *bound)
+		}
+	}
+	return bounds
+}
+
+func TestCalculateHeartRateZonesFromMax(t *testing.T) {
+	zones, err := wahoo.CalculateHeartRateZonesFromMax(190, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(heartRateBounds(zones), []int{95, 114, 133, 152, 171}) {
+		t.Errorf("Wrong zones %v", heartRateBounds(zones))
+	}
+	if *zones.Maximum != 190 || zones.Resting != nil {
+		t.Error("Expected only the maximum to be set")
+	}
+	if _, err := wahoo.CalculateHeartRateZonesFromMax(0, 0); err == nil {
+		t.Error("Expected an error without a maximum")
+	}
+}
+
+func TestCalculateHeartRateZonesKarvonen(t *testing.T) {
+	zones, err := wahoo.CalculateHeartRateZonesKarvonen(190, 60)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	//Reserve of 130 beats from 60
+	if !reflect.DeepEqual(heartRateBounds(zones), []int{125, 138, 151, 164, 177}) {
+		t.Errorf("Wrong zones %v", heartRateBounds(zones))
+	}
+	if *zones.Maximum != 190 || *zones.Resting != 60 {
+		t.Error("Expected resting and maximum to be set")
+	}
+	if _, err := wahoo.CalculateHeartRateZonesKarvonen(190, 0); err == nil {
+		t.Error("Expected an error without a resting heart rate")
+	}
+	if _, err := wahoo.CalculateHeartRateZonesKarvonen(60, 190); err == nil {
+		t.Error("Expected an error when resting is above the maximum")
+	}
+}
+
+func TestCalculateHeartRateZonesFriel(t *testing.T) {
+	zones, err := wahoo.CalculateHeartRateZonesFriel(170, 55, 188)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(heartRateBounds(zones), []int{111, 138, 153, 160, 170}) {
+		t.Errorf("Wrong zones %v", heartRateBounds(zones))
+	}
+	if *zones.Maximum != 188 || *zones.Resting != 55 {
+		t.Error("Expected resting and maximum to be set")
+	}
+	zones, _ = wahoo.CalculateHeartRateZonesFriel(170, 0, 0)
+	if zones.Maximum != nil || zones.Resting != nil {
+		t.Error("Expected resting and maximum to be left out")
+	}
+	if _, err := wahoo.CalculateHeartRateZonesFriel(170, 0, 160); err == nil {
+		t.Error("Expected an error when the maximum is below threshold")
+	}
+}
+
+func powerBounds(zones *wahoo.PowerZone) []int {
+	bounds := []int{}
+	for _, bound := range []*int{zones.Zone1, zones.Zone2, zones.Zone3, zones.Zone4, zones.Zone5, zones.Zone6, zones.Zone7} {
+		if bound != nil {
+			bounds = append(bounds, *bound)
+		}
+	}
+	return bounds
+}
+
+func TestCalculatePowerZones(t *testing.T) {
+	tests := []struct {
+		model    wahoo.PowerZoneModel
+		expected []int
+	}{
+		{wahoo.PowerZonesCoggan7, []int{0, 140, 190, 228, 265, 303, 378}},
+		{wahoo.PowerZonesCoggan6, []int{0, 140, 190, 228, 265, 303}},
+		{wahoo.PowerZonesCoggan5, []int{0, 140, 190, 228, 265}},
+		{wahoo.PowerZoneModel{0, 0.6, 0.8, 1.0}, []int{0, 150, 200, 250}},
+	}
+	for _, test := range tests {
+		zones, err := wahoo.CalculatePowerZones(250, test.model)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !reflect.DeepEqual(powerBounds(zones), test.expected) {
+			t.Errorf("Wrong zones %v, expected %v", powerBounds(zones), test.expected)
+		}
+		if *zones.ZoneCount != len(test.expected) || *zones.Ftp != 250 {
+			t.Error("Wrong zone count or FTP")
+		}
+	}
+
+	if _, err := wahoo.CalculatePowerZones(0, wahoo.PowerZonesCoggan7); err == nil {
+		t.Error("Expected an error without an FTP")
+	}
+	if _, err := wahoo.CalculatePowerZones(250, wahoo.PowerZoneModel{0, 0.8, 0.6}); err == nil {
+		t.Error("Expected an error for percentages that are not ascending")
+	}
+	if _, err := wahoo.CalculatePowerZones(250, make(wahoo.PowerZoneModel, 8)); err == nil {
+		t.Error("Expected an error for more than 7 zones")
+	}
+}
+
+func TestHeartRateZoneValidate(t *testing.T) {
+	zones, _ := wahoo.CalculateHeartRateZonesKarvonen(190, 60)
+	if err := zones.Validate(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := (&wahoo.HeartRateZone{Maximum: intPointer(185)}).Validate(); err != nil {
+		t.Error("Expected a partial update to be valid")
+	}
+
+	zones.Zone3 = intPointer(130)
+	zones.Resting = intPointer(130)
+	err := zones.Validate()
+	problems, ok := err.(*wahoo.ZoneValidationError)
+	if !ok {
+		t.Fatalf("Expected a ZoneValidationError, got %v", err)
+	}
+	if problems.Field("zone_1") == nil || problems.Field("zone_3") == nil || problems.Field("zone_4") != nil {
+		t.Error("Wrong fields: " + err.Error())
+	}
+	if problems.Field("zone_3").Problem != "130 must be above zone_2 (138)" {
+		t.Error("Wrong problem: " + problems.Field("zone_3").Problem)
+	}
+
+	if err := (&wahoo.HeartRateZone{Zone1: intPointer(0), Zone5: intPointer(200), Maximum: intPointer(190)}).Validate(); err == nil ||
+		err.(*wahoo.ZoneValidationError).Field("zone_1") == nil || err.(*wahoo.ZoneValidationError).Field("maximum") == nil {
+		t.Error("Expected zone_1 and maximum to be invalid")
+	}
+}
+
+func TestPowerZoneValidate(t *testing.T) {
+	zones, _ := wahoo.CalculatePowerZones(250, wahoo.PowerZonesCoggan5)
+	if err := zones.Validate(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := (&wahoo.PowerZone{Ftp: intPointer(260), ZoneCount: intPointer(7)}).Validate(); err != nil {
+		t.Error("Expected an update without zones to be valid")
+	}
+
+	tests := []struct {
+		zones  *wahoo.PowerZone
+		fields []string
+	}{
+		{&wahoo.PowerZone{Zone1: intPointer(0), Zone2: intPointer(150), Zone3: intPointer(150)}, []string{"zone_3"}},
+		{&wahoo.PowerZone{Zone1: intPointer(-1), Ftp: intPointer(0)}, []string{"zone_1", "ftp"}},
+		{&wahoo.PowerZone{Zone1: intPointer(0), Zone2: intPointer(150), Zone6: intPointer(300), ZoneCount: intPointer(3)}, []string{"zone_3", "zone_6"}},
+		{&wahoo.PowerZone{ZoneCount: intPointer(8)}, []string{"zone_count"}},
+	}
+	for _, test := range tests {
+		err := test.zones.Validate()
+		problems, ok := err.(*wahoo.ZoneValidationError)
+		if !ok || len(problems.Fields) != len(test.fields) {
+			t.Errorf("Expected problems with %v, got %v", test.fields, err)
+			continue
+		}
+		for _, field := range test.fields {
+			if problems.Field(field) == nil {
+				t.Errorf("Expected a problem with %s: %s", field, err.Error())
+			}
+		}
+	}
+}
+
+func TestUpdateZones_ValidatesBeforeSending(t *testing.T) {
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	client := constructTestServerClient(t, server)
+
+	heartRates := &wahoo.HeartRateZone{Zone1: intPointer(150), Zone2: intPointer(140)}
+	if _, ok := client.UpdateHeartRateZone("token", heartRates).(*wahoo.ZoneValidationError); !ok {
+		t.Error("Expected the heart rate zones to be rejected")
+	}
+	power := &wahoo.PowerZone{Zone1: intPointer(0), Zone2: intPointer(150), ZoneCount: intPointer(1)}
+	if _, ok := client.UpdatePowerZones("token", power).(*wahoo.ZoneValidationError); !ok {
+		t.Error("Expected the power zones to be rejected")
+	}
+	if requests != 0 {
+		t.Error("Expected nothing to be sent")
+	}
+
+	if err := client.UpdatePowerZones("token", &wahoo.PowerZone{Ftp: intPointer(260)}); err != nil || requests != 1 {
+		t.Errorf("Expected valid zones to be sent, got %v", err)
+	}
+}
+
+func TestZone(t *testing.T) {
+	heartRates, _ := wahoo.CalculateHeartRateZonesFromMax(200, 0)
+	for heartRate, expected := range map[float64]int{80: 0, 100: 1, 119.9: 1, 120: 2, 179: 4, 180: 5, 210: 5} {
+		if zone := heartRates.Zone(heartRate); zone != expected {
+			t.Errorf("Expected %v bpm in zone %d, got %d", heartRate, expected, zone)
+		}
+	}
+
+	power, _ := wahoo.CalculatePowerZones(250, wahoo.PowerZonesCoggan7)
+	for watts, expected := range map[float64]int{0: 1, 139: 1, 140: 2, 302: 5, 303: 6, 1000: 7} {
+		if zone := power.Zone(watts); zone != expected {
+			t.Errorf("Expected %vW in zone %d, got %d", watts, expected, zone)
+		}
+	}
+	//Zones above the zone count are ignored
+	power.ZoneCount = intPointer(5)
+	if zone := power.Zone(1000); zone != 5 {
+		t.Errorf("Expected the top zone to be 5, got %d", zone)
+	}
+}
+
+func TestTimeInZones_FromFitFile(t *testing.T) {
+	file, err := fit.DecodeBytes(buildTestWorkoutFitFile(120))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	samples := wahoo.SamplesFromFitFile(file)
+
+	//Power alternates 300W (zone 5) and 150W (zone 2).  The last second before the pause is not active
+	power, _ := wahoo.CalculatePowerZones(250, wahoo.PowerZonesCoggan7)
+	powerTime, err := power.TimeInZones(samples)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []time.Duration{0, 0, 58 * time.Second, 0, 0, 60 * time.Second, 0, 0}
+	if !reflect.DeepEqual(powerTime.Zones, expected) || powerTime.NoData != 0 {
+		t.Errorf("Wrong power time in zones %v", powerTime.Zones)
+	}
+	if powerTime.Total() != 118*time.Second || math.Abs(powerTime.Percent(5)-60.0/118*100) > 0.001 {
+		t.Error("Wrong total or percent")
+	}
+
+	//Heart rate climbs from 120 to 179 every minute
+	heartRates, _ := wahoo.CalculateHeartRateZonesFromMax(200, 0)
+	heartRateTime, err := heartRates.TimeInZones(samples)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected = []time.Duration{0, 0, 40 * time.Second, 40 * time.Second, 38 * time.Second, 0}
+	if !reflect.DeepEqual(heartRateTime.Zones, expected) {
+		t.Errorf("Wrong heart rate time in zones %v", heartRateTime.Zones)
+	}
+}
+
+func TestTimeInZones(t *testing.T) {
+	start := time.Date(2020, time.June, 1, 7, 30, 0, 0, time.UTC)
+	samples := []wahoo.Sample{
+		{Time: start, Power: floatPointer(100)},
+		{Time: start.Add(2 * time.Second)},
+		{Time: start.Add(3 * time.Second), Power: floatPointer(200)},
+		//A gap longer than DefaultMaxSampleGap is not counted
+		{Time: start.Add(60 * time.Second), Power: floatPointer(50)},
+		{Time: start.Add(61 * time.Second)},
+	}
+	zones := &wahoo.PowerZone{Zone1: intPointer(80), Zone2: intPointer(150), ZoneCount: intPointer(2)}
+	result, err := zones.TimeInZones(samples)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(result.Zones, []time.Duration{time.Second, 2 * time.Second, 0}) || result.NoData != time.Second {
+		t.Errorf("Wrong time in zones %v %v", result.Zones, result.NoData)
+	}
+
+	if _, err := (&wahoo.PowerZone{}).TimeInZones(samples); err == nil {
+		t.Error("Expected an error without zones")
+	}
+	if _, err := zones.TimeInZones([]wahoo.Sample{{Time: start.Add(time.Second)}, {Time: start}}); err == nil {
+		t.Error("Expected an error for samples out of order")
+	}
+}